@@ -1,3 +1,5 @@
+//go:build integration
+
 package query
 
 import (
@@ -22,7 +24,7 @@ type Region struct {
 func TestQueryRow(t *testing.T) {
 	db, err := common.InitCloudwave()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	var region Region
 	err = db.QueryRow("select `r_regionkey`, `r_name`, `r_comment` from tpch1.region;").Scan(&region.RRegionkey, &region.RName, &region.RComment)
@@ -42,12 +44,12 @@ func TestQueryRow(t *testing.T) {
 func TestQuery(t *testing.T) {
 	db, err := common.InitCloudwave()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	//db.Exec("use schema tpch1")
 	rows, err := db.Query("select `r_regionkey`, `r_name`, `r_comment` from tpch1.region where 1 = ?", 1)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	defer rows.Close()
 	var regions []Region
@@ -72,7 +74,7 @@ func TestQuery(t *testing.T) {
 func TestInitCloudwave(t *testing.T) {
 	db, err := common.InitCloudwave()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	t.Log(db)
 }
@@ -88,7 +90,7 @@ func TestInitCloudwave(t *testing.T) {
 func TestQueryRowMysql(t *testing.T) {
 	db, err := common.InitMysql()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	var name string
 	err = db.QueryRow("select name from exhibitor_production").Scan(&name)
@@ -109,7 +111,7 @@ func TestQueryRowMysql(t *testing.T) {
 func TestQueryRowMyCloudwave(t *testing.T) {
 	db, err := common.InitCloudwave()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	var name string
 	err = db.QueryRow("select name from exhibitor_production").Scan(&name)
@@ -0,0 +1,107 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// AdminCommand is the code of an administrative command of the server, such
+// as DATABASE_META_DATA_GET_TABLES. Passed as the first argument of Exec or
+// Query, it runs the command with the remaining arguments instead of the
+// query, which is ignored:
+//
+//	rows, err := db.Query("", cloudwave.AdminCommand(cloudwave.GET_SERVER_LIST))
+//
+// Exec returns the handle of the command result as RowsAffected, to be read
+// with PullData. The remaining arguments may be bool, int64, float64 (sent as
+// a 4-byte integer), string, []byte and json.RawMessage holding a JSON array
+// of strings.
+type AdminCommand int32
+
+// adminCommand returns the command of an admin call.
+func adminCommand(args []driver.Value) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	cmd, ok := args[0].(AdminCommand)
+	return int(cmd), ok
+}
+
+// writeAdminCommand sends the admin command cmd with args, the arguments
+// following the AdminCommand.
+func (mc *cwConn) writeAdminCommand(cmd int, args []driver.Value) error {
+	if len(args) == 0 {
+		return mc.writeCommandPacket(cmd)
+	}
+	data, err := mc.buf.takeCompleteBuffer()
+	if err != nil {
+		return ErrBusyBuffer
+	}
+	pos := 25
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case bool:
+			if v {
+				data[pos] = 1
+			} else {
+				data[pos] = 0
+			}
+			pos++
+		case float64:
+			binary.BigEndian.PutUint32(data[pos:pos+4], uint32(int32(v)))
+			pos += 4
+		case int64:
+			binary.BigEndian.PutUint64(data[pos:pos+8], uint64(v))
+			pos += 8
+		case []byte:
+			pos++
+			if v == nil {
+				data[pos-1] = 1
+				continue
+			}
+			data[pos-1] = 0
+			n := copy(data[pos+4:], v)
+			binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
+			pos += (4 + n)
+		case string:
+			n := copy(data[pos+4:], v)
+			binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
+			pos += (4 + n)
+		case json.RawMessage:
+			var ss []string
+			if err := json.Unmarshal(v, &ss); err != nil {
+				return fmt.Errorf("cloudwave: admin command argument %d: %w", i+2, err)
+			}
+			if len(ss) == 0 {
+				data[pos] = 1
+				pos++
+				continue
+			}
+			data[pos] = 0
+			pos++
+			for _, s := range ss {
+				data[pos] = 0
+				pos++
+				n := copy(data[pos+4:], s)
+				binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
+				pos += (n + 4)
+			}
+		default:
+			return fmt.Errorf("cloudwave: admin command argument %d has unsupported type %T", i+2, arg)
+		}
+	}
+	if err := mc.setCommandPacket(cmd, pos, data[0:25]); err != nil {
+		return err
+	}
+	return mc.writePacket(data[0:pos])
+}
@@ -0,0 +1,319 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Array binds a Go slice to an array column, or scans an array column into
+// one.
+//
+// When binding, V must be a []int64, []string, []float64 or []any. When
+// scanning, V must be a pointer to one of these; elements are converted to
+// the element type of the slice, and NULL elements can only be scanned into
+// a []any.
+type Array struct {
+	V interface{}
+}
+
+// Value implements the driver.Valuer interface.
+func (a Array) Value() (driver.Value, error) {
+	if a.V == nil || isNilSlice(a.V) {
+		return nil, nil
+	}
+	if !isArrayValue(a.V) {
+		return nil, fmt.Errorf("cloudwave: unsupported array type %T", a.V)
+	}
+	return a.V, nil
+}
+
+// isArrayValue reports whether v is one of the slice types accepted for
+// array parameters.
+func isArrayValue(v interface{}) bool {
+	switch v.(type) {
+	case []int64, []string, []float64, []interface{}:
+		return true
+	}
+	return false
+}
+
+func isNilSlice(v interface{}) bool {
+	switch v := v.(type) {
+	case []int64:
+		return v == nil
+	case []string:
+		return v == nil
+	case []float64:
+		return v == nil
+	case []interface{}:
+		return v == nil
+	}
+	return false
+}
+
+// Scan implements the sql.Scanner interface.
+func (a *Array) Scan(src interface{}) error {
+	var elems []interface{}
+	switch v := src.(type) {
+	case nil:
+	case []interface{}:
+		elems = v
+	default:
+		return fmt.Errorf("cloudwave: can't scan %T into Array", src)
+	}
+
+	switch dest := a.V.(type) {
+	case *[]interface{}:
+		if src == nil {
+			*dest = nil
+			return nil
+		}
+		*dest = append((*dest)[:0], elems...)
+	case *[]int64:
+		if src == nil {
+			*dest = nil
+			return nil
+		}
+		s := make([]int64, len(elems))
+		for i, e := range elems {
+			var err error
+			if s[i], err = arrayInt64(e); err != nil {
+				return fmt.Errorf("cloudwave: array element %d: %v", i, err)
+			}
+		}
+		*dest = s
+	case *[]float64:
+		if src == nil {
+			*dest = nil
+			return nil
+		}
+		s := make([]float64, len(elems))
+		for i, e := range elems {
+			var err error
+			if s[i], err = arrayFloat64(e); err != nil {
+				return fmt.Errorf("cloudwave: array element %d: %v", i, err)
+			}
+		}
+		*dest = s
+	case *[]string:
+		if src == nil {
+			*dest = nil
+			return nil
+		}
+		s := make([]string, len(elems))
+		for i, e := range elems {
+			var err error
+			if s[i], err = arrayString(e); err != nil {
+				return fmt.Errorf("cloudwave: array element %d: %v", i, err)
+			}
+		}
+		*dest = s
+	default:
+		return fmt.Errorf("cloudwave: can't scan Array into %T", a.V)
+	}
+	return nil
+}
+
+func arrayInt64(e interface{}) (int64, error) {
+	switch v := e.(type) {
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case nil:
+		return 0, fmt.Errorf("can't convert NULL to int64")
+	}
+	return 0, fmt.Errorf("can't convert %T to int64", e)
+}
+
+func arrayFloat64(e interface{}) (float64, error) {
+	switch v := e.(type) {
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case nil:
+		return 0, fmt.Errorf("can't convert NULL to float64")
+	}
+	return 0, fmt.Errorf("can't convert %T to float64", e)
+}
+
+func arrayString(e interface{}) (string, error) {
+	switch v := e.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case nil:
+		return "", fmt.Errorf("can't convert NULL to string")
+	}
+	return fmt.Sprint(e), nil
+}
+
+// readArray decodes a value of one of the array types into a []any.
+//
+// ARRAY is an int32 element count followed by the elements as written by
+// readObject, INTS is an int32 count followed by int32 values and X_BYTES is
+// an int32 count followed by length-prefixed byte strings.
+func (rows *textRows) readArray(b []byte, tp byte) ([]interface{}, int, error) {
+	r := NewPacketReader(b, getTypeName(tp)+" element count")
+	count := r.Count(1)
+	elems := make([]interface{}, count)
+	for i := range elems {
+		r.SetContext(fmt.Sprintf("%s element %d", getTypeName(tp), i))
+		switch tp {
+		case CLOUD_TYPE_INTS:
+			elems[i] = int64(r.Int32())
+		case CLOUD_TYPE_X_BYTES:
+			elems[i] = []byte(r.String())
+		default:
+			v, etp, _, n, err := rows.readObject(r.Rest())
+			r.advance(n, err)
+			// Character data is decoded as []byte, which can't be told
+			// from binary data once it is in the array.
+			if s, ok := v.([]byte); ok && isCharType(etp) {
+				v = string(s)
+			} else if b, ok := v.(byte); ok && etp == CLOUD_TYPE_BOOLEAN {
+				v = b != 0
+			}
+			elems[i] = v
+		}
+		if r.Err() != nil {
+			return nil, 0, r.Err()
+		}
+	}
+	return elems, r.Pos(), r.Err()
+}
+
+func isCharType(tp byte) bool {
+	switch tp {
+	case CLOUD_TYPE_CHAR, CLOUD_TYPE_VARCHAR, CLOUD_TYPE_SINGLE_CHAR, CLOUD_TYPE_LONGVARCHAR:
+		return true
+	}
+	return false
+}
+
+// arrayElements returns the elements of a bound array parameter.
+func arrayElements(arg driver.Value) ([]interface{}, error) {
+	switch v := arg.(type) {
+	case []interface{}:
+		return v, nil
+	case []int64:
+		elems := make([]interface{}, len(v))
+		for i := range v {
+			elems[i] = v[i]
+		}
+		return elems, nil
+	case []float64:
+		elems := make([]interface{}, len(v))
+		for i := range v {
+			elems[i] = v[i]
+		}
+		return elems, nil
+	case []string:
+		elems := make([]interface{}, len(v))
+		for i := range v {
+			elems[i] = v[i]
+		}
+		return elems, nil
+	}
+	return nil, fmt.Errorf("cloudwave: can't convert %T to an array", arg)
+}
+
+// arrayElementType returns the type an ARRAY element is sent as.
+func arrayElementType(e interface{}) (byte, error) {
+	switch e.(type) {
+	case nil, string:
+		return CLOUD_TYPE_VARCHAR, nil
+	case int64, uint64:
+		return CLOUD_TYPE_LONG, nil
+	case float64:
+		return CLOUD_TYPE_DOUBLE, nil
+	case bool:
+		return CLOUD_TYPE_BOOLEAN, nil
+	case []byte:
+		return CLOUD_TYPE_VARBINARY, nil
+	case time.Time:
+		return CLOUD_TYPE_TIMESTAMP, nil
+	}
+	return 0, fmt.Errorf("unsupported type %T", e)
+}
+
+// writeArray encodes arg for a column of one of the array types.
+func (stmt *cwStmt) writeArray(arg driver.Value, tp byte, scale int, data []byte) (int, error) {
+	elems, err := arrayElements(arg)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < 4 {
+		return 0, ErrPktTooLarge
+	}
+	binary.BigEndian.PutUint32(data, uint32(len(elems)))
+	pos := 4
+	for i, e := range elems {
+		switch tp {
+		case CLOUD_TYPE_INTS:
+			v, err := arrayInt64(e)
+			if err != nil {
+				return 0, fmt.Errorf("cloudwave: array element %d: %v", i, err)
+			}
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return 0, fmt.Errorf("cloudwave: array element %d: %d overflows INTS", i, v)
+			}
+			if len(data)-pos < 4 {
+				return 0, ErrPktTooLarge
+			}
+			binary.BigEndian.PutUint32(data[pos:], uint32(int32(v)))
+			pos += 4
+		case CLOUD_TYPE_X_BYTES:
+			var v []byte
+			switch e := e.(type) {
+			case []byte:
+				v = e
+			case string:
+				v = []byte(e)
+			default:
+				return 0, fmt.Errorf("cloudwave: array element %d: can't convert %T to bytes", i, e)
+			}
+			if len(data)-pos < 4+len(v) {
+				return 0, ErrPktTooLarge
+			}
+			binary.BigEndian.PutUint32(data[pos:], uint32(len(v)))
+			pos += 4
+			pos += copy(data[pos:], v)
+		default:
+			etp, err := arrayElementType(e)
+			if err != nil {
+				return 0, fmt.Errorf("cloudwave: array element %d: %v", i, err)
+			}
+			n, err := stmt.writeObject(e, etp, scale, data[pos:])
+			if err != nil {
+				return 0, fmt.Errorf("cloudwave: array element %d: %v", i, err)
+			}
+			pos += n
+		}
+	}
+	return pos, nil
+}
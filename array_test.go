@@ -0,0 +1,108 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var arrayTests = []struct {
+	tp    byte
+	arg   interface{}
+	wire  []byte
+	elems []interface{}
+}{
+	{
+		CLOUD_TYPE_ARRAY, []int64{1, -2},
+		[]byte{0, 0, 0, 2, 0, CLOUD_TYPE_LONG, 0, 0, 0, 0, 0, 0, 0, 1, 0, CLOUD_TYPE_LONG, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+		[]interface{}{int64(1), int64(-2)},
+	},
+	{
+		CLOUD_TYPE_ARRAY, []interface{}{"ab", nil, true},
+		[]byte{0, 0, 0, 3, 0, CLOUD_TYPE_VARCHAR, 0, 0, 0, 2, 0, 'a', 0, 'b', 1, 0, CLOUD_TYPE_BOOLEAN, 1},
+		[]interface{}{"ab", nil, true},
+	},
+	{
+		CLOUD_TYPE_INTS, []int64{7, -1},
+		[]byte{0, 0, 0, 2, 0, 0, 0, 7, 0xff, 0xff, 0xff, 0xff},
+		[]interface{}{int64(7), int64(-1)},
+	},
+	{
+		CLOUD_TYPE_X_BYTES, []string{"a", ""},
+		[]byte{0, 0, 0, 2, 0, 0, 0, 1, 'a', 0, 0, 0, 0},
+		[]interface{}{[]byte("a"), []byte{}},
+	},
+}
+
+func TestArrayRoundTrip(t *testing.T) {
+	stmt := &cwStmt{}
+	rows := &textRows{}
+	for i, tt := range arrayTests {
+		v, err := converter{}.ConvertValue(Array{tt.arg})
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		buf := make([]byte, 64)
+		n, err := stmt.writeArray(v, tt.tp, defaultDecimalScale, buf)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if !bytes.Equal(buf[:n], tt.wire) {
+			t.Errorf("%d: got %x, want %x", i, buf[:n], tt.wire)
+		}
+
+		elems, n, err := rows.readArray(tt.wire, tt.tp)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if n != len(tt.wire) || !reflect.DeepEqual(elems, tt.elems) {
+			t.Errorf("%d: got %#v (%d bytes), want %#v", i, elems, n, tt.elems)
+		}
+	}
+}
+
+func TestArrayScan(t *testing.T) {
+	src := []interface{}{int32(1), int64(2), "3"}
+
+	var ints []int64
+	if err := (&Array{&ints}).Scan(src); err != nil || !reflect.DeepEqual(ints, []int64{1, 2, 3}) {
+		t.Errorf("got %v, %v", ints, err)
+	}
+	var floats []float64
+	if err := (&Array{&floats}).Scan(src); err != nil || !reflect.DeepEqual(floats, []float64{1, 2, 3}) {
+		t.Errorf("got %v, %v", floats, err)
+	}
+	var strs []string
+	if err := (&Array{&strs}).Scan(src); err != nil || !reflect.DeepEqual(strs, []string{"1", "2", "3"}) {
+		t.Errorf("got %v, %v", strs, err)
+	}
+	var any []interface{}
+	if err := (&Array{&any}).Scan(src); err != nil || !reflect.DeepEqual(any, src) {
+		t.Errorf("got %v, %v", any, err)
+	}
+
+	if err := (&Array{&ints}).Scan([]interface{}{nil}); err == nil {
+		t.Error("expected error scanning NULL element into []int64")
+	}
+	if err := (&Array{&ints}).Scan(nil); err != nil || ints != nil {
+		t.Errorf("got %v, %v", ints, err)
+	}
+	if _, err := (Array{[]int32{1}}).Value(); err == nil {
+		t.Error("expected error for unsupported slice type")
+	}
+}
+
+func TestArrayIntsOverflow(t *testing.T) {
+	stmt := &cwStmt{}
+	if _, err := stmt.writeArray([]int64{1 << 40}, CLOUD_TYPE_INTS, 0, make([]byte, 64)); err == nil {
+		t.Error("expected overflow error")
+	}
+}
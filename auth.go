@@ -9,6 +9,7 @@
 package cloudwave
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
@@ -284,7 +285,7 @@ func (mc *cwConn) auth(authData []byte, plugin string) ([]byte, error) {
 		return enc, err
 
 	default:
-		errLog.Print("unknown auth plugin:", plugin)
+		mc.log(context.Background(), logLevelError, "unknown auth plugin", "plugin", plugin)
 		return nil, ErrUnknownPlugin
 	}
 }
@@ -15,6 +15,8 @@ func bytes2bigInt(b []byte) (big.Int, error) {
 		if (b[0] & 0x80) == 0 {
 			bi = new(big.Int).SetBytes(b)
 		} else { //bi.Neg().neg = false
+			// negate a copy, b may alias the connection buffer
+			b = append([]byte(nil), b...)
 			minus := true
 			for i := length - 1; i >= 0; i-- {
 				if minus {
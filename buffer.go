@@ -29,8 +29,9 @@ type buffer struct {
 	idx     int
 	length  int
 	timeout time.Duration
-	dbuf    [2][]byte // dbuf is an array with the two byte slices that back this buffer
-	flipcnt uint      // flipccnt is the current buffer counter for double-buffering
+	dbuf    [2][]byte   // dbuf is an array with the two byte slices that back this buffer
+	flipcnt uint        // flipccnt is the current buffer counter for double-buffering
+	counter func(n int) // called with the number of bytes read, if set
 }
 
 // newBuffer allocates and returns a new buffer.
@@ -89,6 +90,9 @@ func (b *buffer) fill(need int) error {
 
 		nn, err := b.nc.Read(b.buf[n:])
 		n += nn
+		if nn > 0 && b.counter != nil {
+			b.counter(nn)
+		}
 
 		switch err {
 		case nil:
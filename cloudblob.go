@@ -3,10 +3,16 @@ package cloudwave
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"os"
-	"reflect"
 )
 
+// CloudBlob is a handle on a BLOB value stored on the server. It streams the
+// value in chunks and implements io.Reader, io.ReaderAt, io.Seeker,
+// io.WriterTo and io.WriterAt, so large values never have to be held in
+// memory. A CloudBlob uses the connection it was read from and is not safe
+// for concurrent use.
 type CloudBlob struct {
 	connection  *cwConn
 	statementId uint32
@@ -14,6 +20,12 @@ type CloudBlob struct {
 	id          int64
 	owned       bool
 	writePos    int64
+
+	chunkSize int   // bytes per LOB_READ_BUFFER / LOB_WRITE_BUFFER request
+	off       int64 // offset of the Read / Seek cursor
+	size      int64 // cached length, valid if sizeKnown
+	sizeKnown bool
+	streaming bool // BLOB_GET_BINARY_STREAM has been sent
 }
 
 //func getType() byte {
@@ -28,9 +40,43 @@ func getBlob(connection *cwConn, id int64, owned bool) *CloudBlob {
 	blob.cursorId = INT_MIN_VALUE
 	blob.id = id
 	blob.owned = owned
+	blob.chunkSize = connection.lobChunkSize()
 	return blob
 }
 
+// SetChunkSize sets the number of bytes transferred per request when
+// streaming the BLOB. It defaults to the lobChunkSize DSN parameter.
+func (blob *CloudBlob) SetChunkSize(n int) {
+	if n > 0 {
+		blob.chunkSize = n
+	}
+}
+
+func (blob *CloudBlob) chunk() int {
+	if blob.chunkSize > 0 {
+		return blob.chunkSize
+	}
+	return INT_CHUNK_SIZE
+}
+
+// Len returns the length of the BLOB in bytes.
+func (blob *CloudBlob) Len() (int64, error) {
+	size, err := blob.length()
+	if err != nil {
+		return 0, err
+	}
+	blob.size, blob.sizeKnown = size, true
+	return size, nil
+}
+
+// cachedLen returns the length of the BLOB, asking the server only once.
+func (blob *CloudBlob) cachedLen() (int64, error) {
+	if blob.sizeKnown {
+		return blob.size, nil
+	}
+	return blob.Len()
+}
+
 func (blob *CloudBlob) length() (int64, error) {
 	var buf []byte
 	pktLen := 25 + 4*2 + 8
@@ -49,49 +95,108 @@ func (blob *CloudBlob) length() (int64, error) {
 	if err == nil {
 		buf, err = blob.connection.readResultOK()
 		if err == nil {
-			return int64(binary.BigEndian.Uint64(buf[1:])), nil
+			return readLOBInt64(buf, "BLOB length")
 		}
 		err = errors.New("get blob length error")
 	}
 	return 0, err
 }
 
-/*
-func (blob *CloudBlob) position(pattern []byte, start int64) (int64, error) {
-	if start <= 0 {
-		return 0, errors.New("position is less than 1")
+// Position returns the 1-based position of the first occurrence of pattern
+// in the BLOB at or after the 1-based position start, or -1 if there is
+// none. The search runs on the server.
+func (blob *CloudBlob) Position(pattern []byte, start int64) (int64, error) {
+	if start < 1 {
+		return 0, errors.New("cloudwave: position is less than 1")
 	}
-	var buf []byte
-	patternlength := len(pattern)
-	pktLen := 25 + 4*3 + 8*2 + patternlength
-	data := make([]byte, pktLen)
+	payload := make([]byte, 0, 4+len(pattern)+8)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(pattern)))
+	payload = append(payload, pattern...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(start-1))
+	buf, err := blob.connection.lobRequest(BLOB_POSITION_BYTEARRAY_PATTERN, blob.statementId, blob.cursorId, blob.id, payload)
+	if err != nil {
+		return 0, err
+	}
+	return readLOBPosition(buf)
+}
 
-	pos := 25
-	binary.BigEndian.PutUint32(data[pos:], uint32(blob.statementId))
-	pos += 4
-	binary.BigEndian.PutUint32(data[pos:], uint32(blob.cursorId))
-	pos += 4
-	binary.BigEndian.PutUint64(data[pos:], uint64(blob.id))
-	pos += 8
-	binary.BigEndian.PutUint32(data[pos:], uint32(patternlength))
-	pos += 4
-	copy(data[pos:], pattern)
-	pos += patternlength
-	binary.BigEndian.PutUint64(data[pos:], uint64(start-1))
-	pos += 8
-	blob.connection.setCommandPacket(BLOB_POSITION_BYTEARRAY_PATTERN, pos, data[0:25])
-	// Send CMD packet
-	err := blob.connection.writePacket(data[0:pos])
-	if err == nil {
-		buf, err = blob.connection.readResultOK()
-		if err == nil {
-			return int64(binary.BigEndian.Uint64(buf[1:])), nil
+// PositionBlob is like Position, with the pattern held in another BLOB.
+func (blob *CloudBlob) PositionBlob(pattern *CloudBlob, start int64) (int64, error) {
+	if start < 1 {
+		return 0, errors.New("cloudwave: position is less than 1")
+	}
+	payload := make([]byte, 0, 8*2)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(pattern.id))
+	payload = binary.BigEndian.AppendUint64(payload, uint64(start-1))
+	buf, err := blob.connection.lobRequest(BLOB_POSITION_BLOB_PATTERN, blob.statementId, blob.cursorId, blob.id, payload)
+	if err != nil {
+		return 0, err
+	}
+	return readLOBPosition(buf)
+}
+
+// Bytes returns n bytes of the BLOB starting at the 1-based position pos,
+// or fewer if the BLOB ends before.
+func (blob *CloudBlob) Bytes(pos int64, n int) ([]byte, error) {
+	if pos < 1 {
+		return nil, errors.New("cloudwave: position is less than 1")
+	} else if n < 0 {
+		return nil, errors.New("cloudwave: length is less than 0")
+	}
+	size, err := blob.cachedLen()
+	if err != nil {
+		return nil, err
+	}
+	if rest := size - (pos - 1); rest < int64(n) {
+		n = 0
+		if rest > 0 {
+			n = int(rest)
 		}
-		err = errors.New("blob position error")
 	}
-	return 0, err
+	if n == 0 {
+		return []byte{}, nil
+	}
+	payload := make([]byte, 0, 8+4)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(pos-1))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(n))
+	buf, err := blob.connection.lobRequest(BLOB_GET_BYTES, blob.statementId, blob.cursorId, blob.id, payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)-1 != n {
+		return nil, ErrMalformPkt
+	}
+	return buf[1:], nil
+}
+
+// SetBytes writes p into the BLOB starting at the 1-based position pos,
+// overwriting existing bytes and extending the BLOB as needed. It returns
+// the number of bytes written.
+func (blob *CloudBlob) SetBytes(pos int64, p []byte) (int, error) {
+	if pos < 1 {
+		return 0, errors.New("cloudwave: position is less than 1")
+	}
+	n := 0
+	for n < len(p) {
+		l := len(p) - n
+		if l > blob.chunk() {
+			l = blob.chunk()
+		}
+		payload := make([]byte, 0, 8+4+l)
+		payload = binary.BigEndian.AppendUint64(payload, uint64(pos-1+int64(n)))
+		payload = binary.BigEndian.AppendUint32(payload, uint32(l))
+		payload = append(payload, p[n:n+l]...)
+		if _, err := blob.connection.lobRequest(BLOB_SET_BYTES, blob.statementId, blob.cursorId, blob.id, payload); err != nil {
+			return n, err
+		}
+		n += l
+	}
+	blob.streaming = false
+	if end := pos - 1 + int64(n); blob.sizeKnown && end > blob.size {
+		blob.size = end
+	}
+	return n, nil
 }
-*/
 
 func (blob *CloudBlob) getBinaryStream(position int64, length int64) error {
 	if position < 0 {
@@ -157,57 +262,178 @@ func (blob *CloudBlob) readChunk(position int64, length int) ([]byte, error) {
 	return nil, err
 }
 
-func (blob *CloudBlob) GetBytes() ([]byte, error) {
-	length, err := blob.length()
-	if err != nil {
-		return nil, err
+// openStream opens the server side stream LOB_READ_BUFFER reads from.
+func (blob *CloudBlob) openStream(size int64) error {
+	if blob.streaming {
+		return nil
 	}
-	err = blob.getBinaryStream(0, length)
+	if err := blob.getBinaryStream(0, size); err != nil {
+		return err
+	}
+	blob.streaming = true
+	return nil
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (blob *CloudBlob) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("cloudwave: negative offset")
+	}
+	size, err := blob.cachedLen()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	var buf []byte
+	if off >= size {
+		return 0, io.EOF
+	}
+	if err = blob.openStream(size); err != nil {
+		return 0, err
+	}
+	n := 0
+	for n < len(p) && off < size {
+		l := len(p) - n
+		if l > blob.chunk() {
+			l = blob.chunk()
+		}
+		if int64(l) > size-off {
+			l = int(size - off)
+		}
+		buf, err := blob.readChunk(off, l)
+		if err != nil {
+			return n, err
+		}
+		if len(buf) == 0 {
+			break
+		}
+		c := copy(p[n:], buf)
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
 
-	buf, err = blob.readChunk(0, int(length))
-	if err == nil {
-		return buf, nil
+// Read implements the io.Reader interface.
+func (blob *CloudBlob) Read(p []byte) (int, error) {
+	n, err := blob.ReadAt(p, blob.off)
+	blob.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
 	}
-	return nil, err
+	return n, err
 }
 
-func (blob *CloudBlob) GetBlob_File(filename string) error {
-	var readedLength int64
-	var buf []byte
+// Seek implements the io.Seeker interface. Seeking relative to io.SeekEnd
+// asks the server for the current length.
+func (blob *CloudBlob) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += blob.off
+	case io.SeekEnd:
+		size, err := blob.Len()
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, errors.New("cloudwave: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("cloudwave: negative position")
+	}
+	blob.off = offset
+	return offset, nil
+}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+// WriteTo implements the io.WriterTo interface. It writes the BLOB from the
+// current offset to w, one chunk at a time.
+func (blob *CloudBlob) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, blob.chunk())
+	var written int64
+	for {
+		n, err := blob.ReadAt(buf, blob.off)
+		if n > 0 {
+			m, werr := w.Write(buf[:n])
+			blob.off += int64(m)
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+			if m < n {
+				return written, io.ErrShortWrite
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
 	}
-	defer file.Close()
+}
 
-	maxLength, err := blob.length()
+// WriteAt implements the io.WriterAt interface. Writing past the end extends
+// the BLOB.
+func (blob *CloudBlob) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("cloudwave: negative offset")
+	}
+	if err := blob.setBinaryStream(off + 1); err != nil {
+		return 0, err
+	}
+	blob.streaming = false
+	blob.writePos = off
+	n := 0
+	for n < len(p) {
+		l := len(p) - n
+		if l > blob.chunk() {
+			l = blob.chunk()
+		}
+		if err := blob.write(p[n:], l); err != nil {
+			return n, err
+		}
+		n += l
+	}
+	if blob.sizeKnown && off+int64(n) > blob.size {
+		blob.size = off + int64(n)
+	}
+	return n, nil
+}
+
+// GetBytes reads the whole BLOB into memory. Use the io interfaces of
+// CloudBlob for large values.
+func (blob *CloudBlob) GetBytes() ([]byte, error) {
+	size, err := blob.Len()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	buf := make([]byte, size)
+	n, err := blob.ReadAt(buf, 0)
+	if err == io.EOF && int64(n) == size {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
 
-	err = blob.getBinaryStream(0, maxLength)
+// GetBlob_File streams the whole BLOB into the named file.
+func (blob *CloudBlob) GetBlob_File(filename string) error {
+	file, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
-	readedLength = 0
-	for readedLength < maxLength {
-		readLength := INT_CHUNK_SIZE
-		if (readedLength + INT_CHUNK_SIZE) > maxLength {
-			readLength = int(maxLength - readedLength)
-		}
-		buf, err = blob.readChunk(readedLength, readLength)
-		if err != nil {
-			return err
-		}
-		file.Write(buf)
-		readedLength += INT_CHUNK_SIZE
+	if _, err = blob.Seek(0, io.SeekStart); err == nil {
+		_, err = blob.WriteTo(file)
 	}
-	return nil
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // 写入//
@@ -241,7 +467,8 @@ func (blob *CloudBlob) setBinaryStream(position int64) error {
 	return err
 }
 
-func (blob *CloudBlob) truncate(length int64) error {
+// Truncate truncates the BLOB to length bytes.
+func (blob *CloudBlob) Truncate(length int64) error {
 	pktLen := 25 + 4*2 + 8*3
 	data := make([]byte, pktLen)
 
@@ -260,13 +487,16 @@ func (blob *CloudBlob) truncate(length int64) error {
 	if err == nil {
 		_, err = blob.connection.readResultOK()
 		if err == nil {
+			blob.size, blob.sizeKnown = length, true
+			blob.streaming = false
 			return nil
 		}
 	}
 	return err
 }
 
-func (blob *CloudBlob) free() error {
+// Free releases the server resources held by the BLOB handle.
+func (blob *CloudBlob) Free() error {
 	pktLen := 25 + 4*2 + 8
 	data := make([]byte, pktLen)
 
@@ -318,7 +548,11 @@ func (blob *CloudBlob) write(cbuf []byte, length int) error {
 		if err == nil {
 			blob.writePos += int64(length)
 			if blob.id == -1 {
-				blob.id = int64(binary.BigEndian.Uint64(buf[1:]))
+				id, err := readLOBInt64(buf, "BLOB id")
+				if err != nil {
+					return err
+				}
+				blob.id = id
 			}
 			return nil
 		}
@@ -327,60 +561,38 @@ func (blob *CloudBlob) write(cbuf []byte, length int) error {
 	return err
 }
 
-func (blob *CloudBlob) resolveBinaryIO(reader []byte) error {
-	var err error
-	readerlen := len(reader)
-	err = blob.setBinaryStream(1)
+// resolveBinaryReader streams r into the BLOB in chunks. If size is
+// positive, exactly size bytes are read from r.
+func (blob *CloudBlob) resolveBinaryReader(r io.Reader, size int64) error {
+	err := blob.setBinaryStream(1)
 	if err != nil {
 		return err
 	}
 	blob.writePos = 0
 
-	writeoff := 0
-	for writeoff < readerlen {
-		writelen := INT_CHUNK_SIZE
-		if (readerlen - writeoff) < INT_CHUNK_SIZE {
-			writelen = readerlen - writeoff
+	if size > 0 {
+		r = io.LimitReader(r, size)
+	}
+	buffer := make([]byte, blob.chunk())
+	for {
+		n, rerr := io.ReadFull(r, buffer)
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			return rerr
 		}
-		err = blob.write(reader[writeoff:], writelen)
-		if err != nil {
-			return err
+		// write at least once, the server assigns the id on the first write
+		if n > 0 || blob.writePos == 0 {
+			if err = blob.write(buffer, n); err != nil {
+				return err
+			}
 		}
-		writeoff += writelen
-	}
-	err = blob.free()
-	if err == nil {
-		blob.owned = true
-	}
-	return err
-}
-
-func (blob *CloudBlob) resolveBinaryIO_File(fileInfo os.FileInfo) error {
-	readerlen := fileInfo.Size()
-	pathname := reflect.ValueOf(fileInfo).Elem().FieldByName("path").String()
-	file, err := os.Open(pathname)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	err = blob.setBinaryStream(1)
-	if err != nil {
-		return err
-	}
-
-	buffer := make([]byte, INT_CHUNK_SIZE)
-	blob.writePos = 0
-
-	writeoff := int64(0)
-	for writeoff < readerlen {
-		writelen, err := file.Read(buffer)
-		err = blob.write(buffer, writelen)
-		if err != nil {
-			return err
+		if rerr != nil {
+			break
 		}
-		writeoff += int64(writelen)
 	}
-	err = blob.free()
+	if size > 0 && blob.writePos != size {
+		return fmt.Errorf("cloudwave: BLOB stream ended after %d of %d bytes", blob.writePos, size)
+	}
+	err = blob.Free()
 	if err == nil {
 		blob.owned = true
 	}
@@ -0,0 +1,401 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+// lobRequests records the LOB requests sent on connections traced to it.
+type lobRequests struct {
+	mu   sync.Mutex
+	reqs map[int][][]byte // payloads by opcode
+}
+
+func (r *lobRequests) Trace(rec *cloudwave.TraceRecord) {
+	if rec.Direction != cloudwave.TraceSend {
+		return
+	}
+	r.mu.Lock()
+	if r.reqs == nil {
+		r.reqs = make(map[int][][]byte)
+	}
+	r.reqs[rec.Opcode] = append(r.reqs[rec.Opcode], rec.Payload)
+	r.mu.Unlock()
+}
+
+// take returns and forgets the payloads of the cmd requests.
+func (r *lobRequests) take(cmd int) [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.reqs[cmd]
+	delete(r.reqs, cmd)
+	return p
+}
+
+// lengths returns the lengths the cmd requests asked for: the 4 bytes
+// following the statement, cursor, id and position.
+func (r *lobRequests) lengths(cmd int) []int {
+	var n []int
+	for _, p := range r.take(cmd) {
+		n = append(n, int(binary.BigEndian.Uint32(p[24:28])))
+	}
+	return n
+}
+
+// openLOB returns rows positioned on LOB columns of type tp holding values,
+// and the requests sent afterwards. The rows are open until the test ends.
+func openLOB(t *testing.T, tp byte, values ...interface{}) (*cloudwavetest.Server, *sql.Rows, *lobRequests) {
+	t.Helper()
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	columns := make([]cloudwavetest.Column, len(values))
+	for i := range columns {
+		columns[i] = cloudwavetest.Column{Name: "v" + strconv.Itoa(i), Type: tp}
+	}
+	srv.Expect("SELECT * FROM t").WillReturnRows(cloudwavetest.NewRows(columns...).AddRow(values...))
+
+	reqs := new(lobRequests)
+	name := "lob-" + t.Name()
+	cloudwave.RegisterTraceSink(name, reqs)
+	t.Cleanup(func() { cloudwave.DeregisterTraceSink(name) })
+	db, err := sql.Open("cloudwave", srv.DSN()+"?trace="+name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	rows, err := db.Query("SELECT * FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rows.Close() })
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	reqs.mu.Lock()
+	reqs.reqs = nil
+	reqs.mu.Unlock()
+	return srv, rows, reqs
+}
+
+func openBlob(t *testing.T, data []byte) (*cloudwave.CloudBlob, *lobRequests) {
+	t.Helper()
+	_, rows, reqs := openLOB(t, cloudwave.CLOUD_TYPE_BLOB, data)
+	var b cloudwave.Blob
+	if err := rows.Scan(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Handle(), reqs
+}
+
+func TestBlobReadAt(t *testing.T) {
+	blob, reqs := openBlob(t, []byte("0123456789"))
+	blob.SetChunkSize(3)
+
+	tests := []struct {
+		n      int
+		off    int64
+		want   string
+		eof    bool
+		chunks []int
+	}{
+		{8, 1, "12345678", false, []int{3, 3, 2}}, // chunk size smaller than the read
+		{5, 7, "789", true, []int{3}},             // short final chunk
+		{3, 6, "678", false, []int{3}},            // exactly one chunk
+		{1, 9, "9", false, []int{1}},
+		{4, 10, "", true, nil}, // off == size
+		{0, 2, "", false, nil}, // len(p) == 0
+		{0, 10, "", true, nil},
+	}
+	for _, tt := range tests {
+		p := make([]byte, tt.n)
+		n, err := blob.ReadAt(p, tt.off)
+		if string(p[:n]) != tt.want || (err == io.EOF) != tt.eof || (err != nil && err != io.EOF) {
+			t.Errorf("ReadAt(%d, %d) = %q, %v", tt.n, tt.off, p[:n], err)
+		}
+		if chunks := reqs.lengths(cloudwave.LOB_READ_BUFFER); !equalInts(chunks, tt.chunks) {
+			t.Errorf("ReadAt(%d, %d) read chunks %v, want %v", tt.n, tt.off, chunks, tt.chunks)
+		}
+	}
+	if _, err := blob.ReadAt(make([]byte, 1), -1); err == nil {
+		t.Error("ReadAt accepted a negative offset")
+	}
+	// the length is asked for and the stream opened once
+	if n := len(reqs.take(cloudwave.BLOB_LENGTH)); n != 1 {
+		t.Errorf("length asked for %d times", n)
+	}
+	if n := len(reqs.take(cloudwave.BLOB_GET_BINARY_STREAM)); n != 1 {
+		t.Errorf("stream opened %d times", n)
+	}
+}
+
+func TestBlobReadSeek(t *testing.T) {
+	blob, _ := openBlob(t, []byte("0123456789"))
+	blob.SetChunkSize(3)
+
+	var got []string
+	p := make([]byte, 4)
+	for {
+		n, err := blob.Read(p)
+		if n > 0 {
+			got = append(got, string(p[:n]))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			t.Fatal("Read returned neither bytes nor io.EOF")
+		}
+	}
+	if len(got) != 3 || got[0] != "0123" || got[1] != "4567" || got[2] != "89" {
+		t.Errorf("Read returned %q", got)
+	}
+	if n, err := blob.Read(p); n != 0 || err != io.EOF {
+		t.Errorf("Read at the end = %d, %v", n, err)
+	}
+
+	for _, tt := range []struct {
+		offset int64
+		whence int
+		want   int64
+		read   string
+	}{
+		{-2, io.SeekEnd, 8, "89"},
+		{2, io.SeekStart, 2, "2345"},
+		{-3, io.SeekCurrent, 3, "3456"},
+		{0, io.SeekEnd, 10, ""},
+		{5, io.SeekEnd, 15, ""},
+	} {
+		off, err := blob.Seek(tt.offset, tt.whence)
+		if err != nil || off != tt.want {
+			t.Errorf("Seek(%d, %d) = %d, %v", tt.offset, tt.whence, off, err)
+			continue
+		}
+		n, _ := blob.Read(p)
+		if string(p[:n]) != tt.read {
+			t.Errorf("Read after Seek(%d, %d) = %q", tt.offset, tt.whence, p[:n])
+		}
+	}
+	if _, err := blob.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek accepted a negative position")
+	}
+	if _, err := blob.Seek(0, 3); err == nil {
+		t.Error("Seek accepted an invalid whence")
+	}
+}
+
+func TestBlobWriteTo(t *testing.T) {
+	blob, reqs := openBlob(t, []byte("0123456789"))
+	blob.SetChunkSize(4)
+	if _, err := blob.Seek(3, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := blob.WriteTo(&buf)
+	if err != nil || n != 7 || buf.String() != "3456789" {
+		t.Errorf("WriteTo = %d, %q, %v", n, buf.String(), err)
+	}
+	if chunks := reqs.lengths(cloudwave.LOB_READ_BUFFER); !equalInts(chunks, []int{4, 3}) {
+		t.Errorf("WriteTo read chunks %v", chunks)
+	}
+	// at the end, nothing is left to write
+	if n, err = blob.WriteTo(&buf); n != 0 || err != nil {
+		t.Errorf("WriteTo at the end = %d, %v", n, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "blob")
+	if err = blob.GetBlob_File(path); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "0123456789" {
+		t.Errorf("GetBlob_File wrote %q, %v", data, err)
+	}
+}
+
+func TestBlobWriteAtTruncate(t *testing.T) {
+	blob, reqs := openBlob(t, []byte("0123456789"))
+	blob.SetChunkSize(3)
+	if size, err := blob.Len(); err != nil || size != 10 {
+		t.Fatalf("Len = %d, %v", size, err)
+	}
+
+	n, err := blob.WriteAt([]byte("abcde"), 2)
+	if err != nil || n != 5 {
+		t.Fatalf("WriteAt = %d, %v", n, err)
+	}
+	if chunks := reqs.lengths(cloudwave.LOB_WRITE_BUFFER); !equalInts(chunks, []int{3, 2}) {
+		t.Errorf("WriteAt wrote chunks %v", chunks)
+	}
+
+	// writing past the end extends the BLOB and its cached length
+	if n, err = blob.WriteAt([]byte("xy"), 12); err != nil || n != 2 {
+		t.Fatalf("WriteAt past the end = %d, %v", n, err)
+	}
+	reqs.take(cloudwave.BLOB_LENGTH)
+	p := make([]byte, 16)
+	n, err = blob.ReadAt(p, 0)
+	if err != io.EOF || string(p[:n]) != "01abcde789\x00\x00xy" {
+		t.Errorf("ReadAt after WriteAt = %q, %v", p[:n], err)
+	}
+	if asked := len(reqs.take(cloudwave.BLOB_LENGTH)); asked != 0 {
+		t.Errorf("length asked for %d times", asked)
+	}
+	if end, err := blob.Seek(0, io.SeekEnd); err != nil || end != 14 {
+		t.Errorf("Seek to the end = %d, %v", end, err)
+	}
+
+	if err = blob.Truncate(4); err != nil {
+		t.Fatal(err)
+	}
+	n, err = blob.ReadAt(p, 0)
+	if err != io.EOF || string(p[:n]) != "01ab" {
+		t.Errorf("ReadAt after Truncate = %q, %v", p[:n], err)
+	}
+	if n, err = blob.ReadAt(p, 4); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt past the truncated end = %d, %v", n, err)
+	}
+	if _, err = blob.WriteAt([]byte("x"), -1); err == nil {
+		t.Error("WriteAt accepted a negative offset")
+	}
+}
+
+func TestBlobPosition(t *testing.T) {
+	_, rows, reqs := openLOB(t, cloudwave.CLOUD_TYPE_BLOB, []byte("0123456789ab01"), []byte("89"))
+	var b, pattern cloudwave.Blob
+	if err := rows.Scan(&b, &pattern); err != nil {
+		t.Fatal(err)
+	}
+	blob := b.Handle()
+
+	for _, tt := range []struct {
+		pattern string
+		start   int64
+		want    int64
+	}{
+		{"01", 1, 1},
+		{"01", 2, 13},
+		{"b01", 1, 12},
+		{"zz", 1, -1},
+		{"01", 14, -1},
+		{"", 3, 3},
+	} {
+		if got, err := blob.Position([]byte(tt.pattern), tt.start); err != nil || got != tt.want {
+			t.Errorf("Position(%q, %d) = %d, %v", tt.pattern, tt.start, got, err)
+		}
+	}
+	if got, err := blob.PositionBlob(pattern.Handle(), 1); err != nil || got != 9 {
+		t.Errorf("PositionBlob = %d, %v", got, err)
+	}
+	if got, err := blob.PositionBlob(pattern.Handle(), 10); err != nil || got != -1 {
+		t.Errorf("PositionBlob after the match = %d, %v", got, err)
+	}
+	if _, err := blob.Position([]byte("0"), 0); err == nil {
+		t.Error("Position accepted position 0")
+	}
+	if _, err := blob.PositionBlob(pattern.Handle(), 0); err == nil {
+		t.Error("PositionBlob accepted position 0")
+	}
+	if n := len(reqs.take(cloudwave.BLOB_POSITION_BYTEARRAY_PATTERN)); n != 6 {
+		t.Errorf("%d position requests sent", n)
+	}
+}
+
+func TestBlobBytes(t *testing.T) {
+	srv, rows, reqs := openLOB(t, cloudwave.CLOUD_TYPE_BLOB, []byte("0123456789"))
+	var b cloudwave.Blob
+	if err := rows.Scan(&b); err != nil {
+		t.Fatal(err)
+	}
+	blob := b.Handle()
+	blob.SetChunkSize(4)
+
+	for _, tt := range []struct {
+		pos  int64
+		n    int
+		want string
+	}{
+		{1, 3, "012"},
+		{3, 4, "2345"},
+		{8, 10, "789"},
+		{10, 1, "9"},
+		{11, 2, ""},
+		{2, 0, ""},
+	} {
+		if got, err := blob.Bytes(tt.pos, tt.n); err != nil || string(got) != tt.want {
+			t.Errorf("Bytes(%d, %d) = %q, %v", tt.pos, tt.n, got, err)
+		}
+	}
+	// empty ranges aren't asked for
+	if n := len(reqs.take(cloudwave.BLOB_GET_BYTES)); n != 4 {
+		t.Errorf("%d requests for bytes sent", n)
+	}
+	if _, err := blob.Bytes(0, 1); err == nil {
+		t.Error("Bytes accepted position 0")
+	}
+	if _, err := blob.Bytes(1, -1); err == nil {
+		t.Error("Bytes accepted a negative length")
+	}
+
+	n, err := blob.SetBytes(3, []byte("ABCDEFGHIJ"))
+	if err != nil || n != 10 {
+		t.Fatalf("SetBytes = %d, %v", n, err)
+	}
+	if chunks := reqs.lengths(cloudwave.BLOB_SET_BYTES); !equalInts(chunks, []int{4, 4, 2}) {
+		t.Errorf("SetBytes sent chunks %v", chunks)
+	}
+	if n, err = blob.SetBytes(1, nil); err != nil || n != 0 {
+		t.Errorf("SetBytes of nothing = %d, %v", n, err)
+	}
+	if sent := len(reqs.take(cloudwave.BLOB_SET_BYTES)); sent != 0 {
+		t.Errorf("SetBytes of nothing sent %d requests", sent)
+	}
+	if got, err := blob.Bytes(1, 20); err != nil || string(got) != "01ABCDEFGHIJ" {
+		t.Errorf("Bytes after SetBytes = %q, %v", got, err)
+	}
+	if _, err = blob.SetBytes(0, []byte("x")); err == nil {
+		t.Error("SetBytes accepted position 0")
+	}
+
+	// replies shorter than the bytes asked for are rejected
+	srv.Handle(cloudwave.BLOB_GET_BYTES, func(payload []byte) ([]byte, error) {
+		return []byte("01"), nil
+	})
+	if got, err := blob.Bytes(1, 3); err == nil {
+		t.Errorf("Bytes accepted a short reply %q", got)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,12 +1,22 @@
 package cloudwave
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
-	"reflect"
+	"unicode/utf8"
 )
 
+// CloudClob is a handle on a CLOB value stored on the server. It streams the
+// value as UTF-8 text in chunks and implements io.Reader, io.ReaderAt,
+// io.Seeker, io.WriterTo and io.WriterAt; their offsets are byte offsets
+// into the UTF-8 text. Len and Truncate count characters, as the server does.
+// A CloudClob uses the connection it was read from and is not safe for
+// concurrent use.
 type CloudClob struct {
 	connection  *cwConn
 	statementId uint32
@@ -14,6 +24,20 @@ type CloudClob struct {
 	id          int64
 	owned       bool
 	writePos    int64
+
+	chunkSize int   // bytes per CLOB_READ / CLOB_WRITE request
+	off       int64 // UTF-8 offset of the Read / Seek cursor
+	size      int64 // cached length in characters, valid if sizeKnown
+	sizeKnown bool
+	streaming bool // CLOB_GET_ASCII_STREAM has been sent
+
+	// The last chunk read: the UTF-8 text of textLen characters starting at
+	// character textPos, which is at UTF-8 offset textOff. Server positions
+	// are in characters, so the chunk is what maps byte offsets to them.
+	text    []byte
+	textOff int64
+	textPos int64
+	textLen int64
 }
 
 func getClob(connection *cwConn, id int64, owned bool) *CloudClob {
@@ -23,9 +47,48 @@ func getClob(connection *cwConn, id int64, owned bool) *CloudClob {
 	clob.cursorId = INT_MIN_VALUE
 	clob.id = id
 	clob.owned = owned
+	clob.chunkSize = connection.lobChunkSize()
 	return clob
 }
 
+// SetChunkSize sets the number of bytes transferred per request when
+// streaming the CLOB. It defaults to the lobChunkSize DSN parameter.
+func (clob *CloudClob) SetChunkSize(n int) {
+	if n > 0 {
+		clob.chunkSize = n
+	}
+}
+
+// chunk returns the number of characters transferred per request.
+func (clob *CloudClob) chunk() int {
+	n := clob.chunkSize
+	if n <= 0 {
+		n = INT_CHUNK_SIZE
+	}
+	if n < 4 {
+		return 2
+	}
+	return n / 2
+}
+
+// Len returns the length of the CLOB in characters.
+func (clob *CloudClob) Len() (int64, error) {
+	size, err := clob.length()
+	if err != nil {
+		return 0, err
+	}
+	clob.size, clob.sizeKnown = size, true
+	return size, nil
+}
+
+// cachedLen returns the length of the CLOB, asking the server only once.
+func (clob *CloudClob) cachedLen() (int64, error) {
+	if clob.sizeKnown {
+		return clob.size, nil
+	}
+	return clob.Len()
+}
+
 func (clob *CloudClob) length() (int64, error) {
 	var buf []byte
 	pktLen := 25 + 4*2 + 8
@@ -44,47 +107,141 @@ func (clob *CloudClob) length() (int64, error) {
 	if err == nil {
 		buf, err = clob.connection.readResultOK()
 		if err == nil {
-			return int64(binary.BigEndian.Uint64(buf[1:])), nil
+			return readLOBInt64(buf, "CLOB length")
 		}
 		err = errors.New("get clob length error")
 	}
 	return 0, err
 }
 
-/*
-func (clob *CloudClob) position(start int64) (int64, error) {
-	if start <= 0 {
-		return 0, errors.New("position is less than 1")
+// appendUcs2String appends s as a character count followed by its UCS-2
+// encoding, the way strings are sent to the server.
+func appendUcs2String(payload []byte, s string) ([]byte, error) {
+	ucs2, count, consumed, err := Utf8ToUcs2([]byte(s), len(s))
+	if err != nil {
+		return nil, err
 	}
-	var buf []byte
-	pktLen := 25 + 4*2 + 8*3
-	data := make([]byte, pktLen)
+	if consumed < len(s) {
+		return nil, fmt.Errorf("%w: incomplete character at byte %d", errInvalidUTF8, consumed)
+	}
+	payload = binary.BigEndian.AppendUint32(payload, uint32(count))
+	return append(payload, ucs2...), nil
+}
 
-	pos := 25
-	binary.BigEndian.PutUint32(data[pos:], uint32(clob.statementId))
-	pos += 4
-	binary.BigEndian.PutUint32(data[pos:], uint32(clob.cursorId))
-	pos += 4
-	binary.BigEndian.PutUint64(data[pos:], uint64(clob.id))
-	pos += 8
-	binary.BigEndian.PutUint64(data[pos:], uint64(clob.id)) //weip ??????
-	pos += 8
-	binary.BigEndian.PutUint64(data[pos:], uint64(start-1))
-	pos += 8
-	clob.connection.setCommandPacket(CLOB_POSITION_CLOB, pos, data[0:25])
-	// Send CMD packet
-	err := clob.connection.writePacket(data)
-	if err == nil {
-		buf, err = clob.connection.readResultOK()
-		if err == nil {
-			l := int64(binary.BigEndian.Uint64(buf[1:]))
-			return l, nil
+// Position returns the 1-based character position of the first occurrence
+// of pattern in the CLOB at or after the 1-based position start, or -1 if
+// there is none. The search runs on the server.
+func (clob *CloudClob) Position(pattern string, start int64) (int64, error) {
+	if start < 1 {
+		return 0, errors.New("cloudwave: position is less than 1")
+	}
+	payload, err := appendUcs2String(make([]byte, 0, 4+2*len(pattern)+8), pattern)
+	if err != nil {
+		return 0, err
+	}
+	payload = binary.BigEndian.AppendUint64(payload, uint64(start-1))
+	buf, err := clob.connection.lobRequest(CLOB_POSITION_STRING, clob.statementId, clob.cursorId, clob.id, payload)
+	if err != nil {
+		return 0, err
+	}
+	return readLOBPosition(buf)
+}
+
+// PositionClob is like Position, with the pattern held in another CLOB.
+func (clob *CloudClob) PositionClob(pattern *CloudClob, start int64) (int64, error) {
+	if start < 1 {
+		return 0, errors.New("cloudwave: position is less than 1")
+	}
+	payload := make([]byte, 0, 8*2)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(pattern.id))
+	payload = binary.BigEndian.AppendUint64(payload, uint64(start-1))
+	buf, err := clob.connection.lobRequest(CLOB_POSITION_CLOB, clob.statementId, clob.cursorId, clob.id, payload)
+	if err != nil {
+		return 0, err
+	}
+	return readLOBPosition(buf)
+}
+
+// SubString returns n characters of the CLOB starting at the 1-based
+// character position pos, or fewer if the CLOB ends before.
+func (clob *CloudClob) SubString(pos int64, n int) (string, error) {
+	if pos < 1 {
+		return "", errors.New("cloudwave: position is less than 1")
+	} else if n < 0 {
+		return "", errors.New("cloudwave: length is less than 0")
+	}
+	size, err := clob.cachedLen()
+	if err != nil {
+		return "", err
+	}
+	if rest := size - (pos - 1); rest < int64(n) {
+		n = 0
+		if rest > 0 {
+			n = int(rest)
 		}
-		err = errors.New("clob position error")
 	}
-	return 0, err
+	if n == 0 {
+		return "", nil
+	}
+	payload := make([]byte, 0, 8+4)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(pos-1))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(n))
+	buf, err := clob.connection.lobRequest(CLOB_GET_SUB_STRING, clob.statementId, clob.cursorId, clob.id, payload)
+	if err != nil {
+		return "", err
+	}
+	if len(buf) < 5 {
+		return "", ErrMalformPkt
+	}
+	count := int(binary.BigEndian.Uint32(buf[1:]))
+	if count != n || 2*count > len(buf)-5 {
+		return "", ErrMalformPkt
+	}
+	b, _, err := Ucs2ToUtf8(buf[5:], count)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// SetString writes s into the CLOB starting at the 1-based character
+// position pos, overwriting existing characters and extending the CLOB as
+// needed. It returns the number of characters written.
+func (clob *CloudClob) SetString(pos int64, s string) (int, error) {
+	if pos < 1 {
+		return 0, errors.New("cloudwave: position is less than 1")
+	}
+	written := 0
+	for len(s) > 0 {
+		// split at a character boundary
+		l := len(s)
+		if l > clob.chunk() {
+			l = clob.chunk()
+			for l > 0 && !utf8.RuneStart(s[l]) {
+				l--
+			}
+			if l == 0 {
+				_, l = utf8.DecodeRuneInString(s)
+			}
+		}
+		chunk := s[:l]
+		s = s[l:]
+		payload := binary.BigEndian.AppendUint64(make([]byte, 0, 8+4+2*len(chunk)), uint64(pos-1+int64(written)))
+		payload, err := appendUcs2String(payload, chunk)
+		if err != nil {
+			return written, err
+		}
+		if _, err = clob.connection.lobRequest(CLOB_SET_STRING, clob.statementId, clob.cursorId, clob.id, payload); err != nil {
+			return written, err
+		}
+		written += int(utf16Len([]byte(chunk)))
+	}
+	clob.resetText()
+	if end := pos - 1 + int64(written); clob.sizeKnown && end > clob.size {
+		clob.size = end
+	}
+	return written, nil
 }
-*/
 
 func (clob *CloudClob) getCharacterStream(position int64, length int64) error {
 	if position < 0 {
@@ -118,7 +275,11 @@ func (clob *CloudClob) getCharacterStream(position int64, length int64) error {
 	return err
 }
 
-func (clob *CloudClob) readChunk(position int64, length int) ([]byte, error) {
+// readChunk reads up to length UTF-16 code units starting at the 0-based
+// position and returns them as UTF-8 text with the number of code units
+// read. A surrogate pair split by the end of the chunk is left for the next
+// chunk.
+func (clob *CloudClob) readChunk(position int64, length int) ([]byte, int, error) {
 	var err error
 	var buf []byte
 
@@ -141,67 +302,294 @@ func (clob *CloudClob) readChunk(position int64, length int) ([]byte, error) {
 	if err == nil {
 		buf, err = clob.connection.readResultOK()
 		if err == nil {
+			// the server may return fewer characters at the end of the CLOB
+			if n := (len(buf) - 1) / 2; n < length {
+				length = n
+			}
+			// a high surrogate needs the low one of the next chunk
+			if length > 1 {
+				if u := binary.BigEndian.Uint16(buf[2*length-1:]); u >= 0xd800 && u < 0xdc00 {
+					length--
+				}
+			}
 			var b []byte
 			b, _, err = Ucs2ToUtf8(buf[1:], length)
-			return b, err
+			return b, length, err
 		}
 		err = errors.New("clob get string error")
 	}
-	return nil, err
+	return nil, 0, err
 }
 
-func (clob *CloudClob) GetString() ([]byte, error) {
-	var buf []byte
+// openStream opens the server side stream CLOB_READ reads from.
+func (clob *CloudClob) openStream(size int64) error {
+	if clob.streaming {
+		return nil
+	}
+	if err := clob.getCharacterStream(0, size); err != nil {
+		return err
+	}
+	clob.streaming = true
+	return nil
+}
 
-	length, err := clob.length()
-	if err != nil {
-		return nil, err
+// resetText drops the cached chunk, after the CLOB has been modified.
+func (clob *CloudClob) resetText() {
+	clob.text = nil
+	clob.textOff, clob.textPos, clob.textLen = 0, 0, 0
+	clob.streaming = false
+}
+
+// load makes the cached chunk cover the UTF-8 offset off, reading forward
+// from the cached chunk or from the start of the CLOB. It reports false if
+// off is at or past the end of the text.
+func (clob *CloudClob) load(off int64) (bool, error) {
+	if clob.text != nil && off >= clob.textOff && off < clob.textOff+int64(len(clob.text)) {
+		return true, nil
 	}
-	err = clob.getCharacterStream(0, length)
+	size, err := clob.cachedLen()
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	textOff, textPos := int64(0), int64(0)
+	if clob.text != nil && clob.textOff <= off {
+		textOff, textPos = clob.textOff+int64(len(clob.text)), clob.textPos+clob.textLen
 	}
+	if textPos >= size {
+		return false, nil
+	}
+	if err = clob.openStream(size); err != nil {
+		return false, err
+	}
+	for textPos < size {
+		// room for a surrogate pair
+		l := int64(clob.chunk())
+		if l < 2 {
+			l = 2
+		}
+		if l > size-textPos {
+			l = size - textPos
+		}
+		buf, n, err := clob.readChunk(textPos, int(l))
+		if err != nil {
+			return false, err
+		}
+		if len(buf) == 0 {
+			break
+		}
+		l = int64(n)
+		clob.text, clob.textOff, clob.textPos, clob.textLen = buf, textOff, textPos, l
+		if off < textOff+int64(len(buf)) {
+			return true, nil
+		}
+		textOff += int64(len(buf))
+		textPos += l
+	}
+	return false, nil
+}
 
-	buf, err = clob.readChunk(0, int(length))
-	if err == nil {
-		return buf, nil
+// ReadAt implements the io.ReaderAt interface.
+func (clob *CloudClob) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("cloudwave: negative offset")
 	}
-	return nil, err
+	n := 0
+	for n < len(p) {
+		ok, err := clob.load(off)
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			return n, io.EOF
+		}
+		c := copy(p[n:], clob.text[off-clob.textOff:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
 }
 
-func (clob *CloudClob) GetClob_File(filename string) error {
-	var readedLength int64
-	var buf []byte
+// Read implements the io.Reader interface.
+func (clob *CloudClob) Read(p []byte) (int, error) {
+	n, err := clob.ReadAt(p, clob.off)
+	clob.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+// byteLen returns the length of the UTF-8 text, which takes reading the
+// CLOB up to its end.
+func (clob *CloudClob) byteLen() (int64, error) {
+	if _, err := clob.load(math.MaxInt64); err != nil {
+		return 0, err
+	}
+	return clob.textOff + int64(len(clob.text)), nil
+}
+
+// Seek implements the io.Seeker interface. Seeking relative to io.SeekEnd
+// reads the CLOB up to its end to find the length of its UTF-8 text.
+func (clob *CloudClob) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += clob.off
+	case io.SeekEnd:
+		if _, err := clob.Len(); err != nil {
+			return 0, err
+		}
+		n, err := clob.byteLen()
+		if err != nil {
+			return 0, err
+		}
+		offset += n
+	default:
+		return 0, errors.New("cloudwave: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("cloudwave: negative position")
 	}
-	defer file.Close()
+	clob.off = offset
+	return offset, nil
+}
 
-	maxLength, err := clob.length()
-	if err != nil {
-		return err
+// WriteTo implements the io.WriterTo interface. It writes the UTF-8 text of
+// the CLOB from the current offset to w, one chunk at a time.
+func (clob *CloudClob) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for {
+		ok, err := clob.load(clob.off)
+		if err != nil {
+			return written, err
+		}
+		if !ok {
+			return written, nil
+		}
+		buf := clob.text[clob.off-clob.textOff:]
+		m, err := w.Write(buf)
+		clob.off += int64(m)
+		written += int64(m)
+		if err != nil {
+			return written, err
+		}
+		if m < len(buf) {
+			return written, io.ErrShortWrite
+		}
 	}
+}
 
-	err = clob.getCharacterStream(0, maxLength)
+// charPos returns the character position of the UTF-8 offset off.
+func (clob *CloudClob) charPos(off int64) (int64, error) {
+	ok, err := clob.load(off)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if !ok {
+		n, err := clob.byteLen()
+		if err != nil {
+			return 0, err
+		}
+		if off != n {
+			return 0, errors.New("cloudwave: offset is past the end of the CLOB")
+		}
+		return clob.cachedLen()
+	}
+	if !utf8.RuneStart(clob.text[off-clob.textOff]) {
+		return 0, errors.New("cloudwave: offset is not at a character boundary")
+	}
+	return clob.textPos + utf16Len(clob.text[:off-clob.textOff]), nil
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode b.
+func utf16Len(b []byte) int64 {
+	var n int64
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r >= 0x10000 {
+			n++
+		}
+		n++
+		b = b[size:]
+	}
+	return n
+}
+
+// WriteAt implements the io.WriterAt interface. p must be UTF-8 text and off
+// must be at a character boundary, at most the length of the text.
+func (clob *CloudClob) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("cloudwave: negative offset")
+	}
+	pos := int64(0)
+	if off > 0 {
+		var err error
+		if pos, err = clob.charPos(off); err != nil {
+			return 0, err
+		}
+	}
+	if err := clob.setCharacterStream(pos + 1); err != nil {
+		return 0, err
 	}
-	readedLength = 0
-	for readedLength < maxLength {
-		readLength := INT_CHUNK_SIZE
-		if (readedLength + INT_CHUNK_SIZE) > maxLength {
-			readLength = int(maxLength - readedLength)
+	clob.resetText()
+	clob.writePos = pos
+	n := 0
+	for n < len(p) {
+		l := len(p) - n
+		if l > clob.chunk() {
+			l = clob.chunk()
+		}
+		tmp, count, consumed, err := Utf8ToUcs2(p[n:], l)
+		if err == nil && consumed == 0 {
+			l = len(p) - n
+			tmp, count, consumed, err = Utf8ToUcs2(p[n:], l)
 		}
-		buf, err = clob.readChunk(readedLength, readLength)
 		if err != nil {
-			return err
+			return n, err
 		}
-		file.Write(buf)
-		readedLength += INT_CHUNK_SIZE
+		if consumed == 0 {
+			return n, errors.New("cloudwave: invalid UTF-8 text")
+		}
+		if err = clob.write(tmp, len(tmp), count); err != nil {
+			return n, err
+		}
+		n += consumed
 	}
-	return nil
+	if clob.sizeKnown && clob.writePos > clob.size {
+		clob.size = clob.writePos
+	}
+	return n, nil
+}
+
+// GetString reads the whole CLOB into memory as UTF-8 text. Use the io
+// interfaces of CloudClob for large values.
+func (clob *CloudClob) GetString() ([]byte, error) {
+	if _, err := clob.Len(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := clob.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := clob.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetClob_File streams the whole CLOB into the named file as UTF-8 text.
+func (clob *CloudClob) GetClob_File(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err = clob.Seek(0, io.SeekStart); err == nil {
+		_, err = clob.WriteTo(file)
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 //写入//
@@ -264,8 +652,8 @@ func (clob *CloudClob) setCharacterStream(position int64) error {
 	return err
 }
 
-/** Truncates the CLOB value that this Clob designates to have a length of len characters. */
-func (clob *CloudClob) truncate(length int64) error {
+// Truncate truncates the CLOB to length characters.
+func (clob *CloudClob) Truncate(length int64) error {
 	pktLen := 25 + 4*2 + 8*3
 	data := make([]byte, pktLen)
 
@@ -284,13 +672,16 @@ func (clob *CloudClob) truncate(length int64) error {
 	if err == nil {
 		_, err = clob.connection.readResultOK()
 		if err == nil {
+			clob.size, clob.sizeKnown = length, true
+			clob.resetText()
 			return nil
 		}
 	}
 	return err
 }
 
-func (clob *CloudClob) free() error {
+// Free releases the server resources held by the CLOB handle.
+func (clob *CloudClob) Free() error {
 	pktLen := 25 + 4*2 + 8
 	data := make([]byte, pktLen)
 
@@ -340,7 +731,11 @@ func (clob *CloudClob) write(cbuf []byte, length int, position int) error {
 		if err == nil {
 			clob.writePos += int64(position)
 			if clob.id == -1 {
-				clob.id = int64(binary.BigEndian.Uint64(buf[1:]))
+				id, err := readLOBInt64(buf, "CLOB id")
+				if err != nil {
+					return err
+				}
+				clob.id = id
 			}
 			return nil
 		}
@@ -349,76 +744,58 @@ func (clob *CloudClob) write(cbuf []byte, length int, position int) error {
 	return err
 }
 
-func (clob *CloudClob) resolveCharacterIO(reader []byte) error {
-	var err error
-	readerlen := len(reader)
-	err = clob.setCharacterStream(1)
+// resolveCharacterReader streams the UTF-8 text read from r into the CLOB in
+// chunks. If size is positive, exactly size bytes are read from r.
+func (clob *CloudClob) resolveCharacterReader(r io.Reader, size int64) error {
+	err := clob.setCharacterStream(1)
 	if err != nil {
 		return err
 	}
 	clob.writePos = 0
 
-	writeoff := 0
-	for writeoff < readerlen {
-		writelen := 4096
-		if (readerlen - writeoff) < 4096 {
-			writelen = readerlen - writeoff
-		}
-		tmp, count, pos, err := Utf8ToUcs2(reader[writeoff:], writelen)
-		len := len(tmp)
-		if len <= 0 {
-			break
-		}
-		err = clob.write(tmp, len, count)
-		if err != nil {
-			return err
-		}
-		writeoff += pos
-	}
-	err = clob.free()
-	if err == nil {
-		clob.owned = true
-	}
-	return err
-}
-
-func (clob *CloudClob) resolveCharacterIO_File(fileInfo os.FileInfo) error {
-	readerlen := fileInfo.Size()
-	pathname := reflect.ValueOf(fileInfo).Elem().FieldByName("path").String()
-	file, err := os.Open(pathname)
-	if err != nil {
-		return err
+	if size > 0 {
+		r = io.LimitReader(r, size)
 	}
-	defer file.Close()
-	err = clob.setCharacterStream(1)
-	if err != nil {
-		return err
+	// room for at least one complete character
+	n := clob.chunk()
+	if n < 8 {
+		n = 8
 	}
-
-	buffer := make([]byte, INT_CHUNK_SIZE)
-	clob.writePos = 0
-
-	writeoff := int64(0)
+	buffer := make([]byte, n)
+	var read int64
 	start := 0
-	for writeoff < readerlen {
-		writelen, err := file.Read(buffer[start:])
-		writelen += start
-		tmp, count, pos, err := Utf8ToUcs2(buffer, writelen)
-		len := len(tmp)
-		if len <= 0 {
-			break
+	for {
+		n, rerr := io.ReadFull(r, buffer[start:])
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			return rerr
 		}
-		err = clob.write(tmp, len, count)
-		if err != nil {
-			return err
+		read += int64(n)
+		n += start
+		tmp, count, consumed := []byte(nil), 0, 0
+		if n > 0 {
+			if tmp, count, consumed, err = Utf8ToUcs2(buffer, n); err != nil {
+				return err
+			}
+		}
+		// write at least once, the server assigns the id on the first write
+		if count > 0 || (rerr != nil && clob.writePos == 0) {
+			if err = clob.write(tmp, len(tmp), count); err != nil {
+				return err
+			}
 		}
-		for i := 0; i < writelen-pos; i++ {
-			buffer[i] = buffer[pos+i]
+		// keep an incomplete character for the next chunk
+		start = copy(buffer, buffer[consumed:n])
+		if rerr != nil {
+			break
 		}
-		start = writelen - pos
-		writeoff += int64(pos)
 	}
-	err = clob.free()
+	if start > 0 {
+		return errors.New("cloudwave: CLOB stream ends with incomplete UTF-8 text")
+	}
+	if size > 0 && read != size {
+		return fmt.Errorf("cloudwave: CLOB stream ended after %d of %d bytes", read, size)
+	}
+	err = clob.Free()
 	if err == nil {
 		clob.owned = true
 	}
@@ -0,0 +1,126 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"testing"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+)
+
+// Positions and lengths of CLOBs count UTF-16 code units: 𝄞 takes two.
+const clobText = "héllo 𝄞 wörld héllo"
+
+func TestClobPosition(t *testing.T) {
+	_, rows, reqs := openLOB(t, cloudwave.CLOUD_TYPE_CLOB, clobText, "wörld")
+	var c, pattern cloudwave.Clob
+	if err := rows.Scan(&c, &pattern); err != nil {
+		t.Fatal(err)
+	}
+	clob := c.Handle()
+
+	for _, tt := range []struct {
+		pattern string
+		start   int64
+		want    int64
+	}{
+		{"héllo", 1, 1},
+		{"héllo", 2, 16},
+		{"𝄞", 1, 7},
+		{" w", 1, 9},
+		{"xyz", 1, -1},
+		{"héllo", 17, -1},
+		{"", 4, 4},
+	} {
+		if got, err := clob.Position(tt.pattern, tt.start); err != nil || got != tt.want {
+			t.Errorf("Position(%q, %d) = %d, %v", tt.pattern, tt.start, got, err)
+		}
+	}
+	if got, err := clob.PositionClob(pattern.Handle(), 1); err != nil || got != 10 {
+		t.Errorf("PositionClob = %d, %v", got, err)
+	}
+	if got, err := clob.PositionClob(pattern.Handle(), 11); err != nil || got != -1 {
+		t.Errorf("PositionClob after the match = %d, %v", got, err)
+	}
+	if _, err := clob.Position("h", 0); err == nil {
+		t.Error("Position accepted position 0")
+	}
+	if _, err := clob.Position("\xff", 1); err == nil {
+		t.Error("Position accepted invalid UTF-8")
+	}
+	if n := len(reqs.take(cloudwave.CLOB_POSITION_STRING)); n != 7 {
+		t.Errorf("%d position requests sent", n)
+	}
+}
+
+func TestClobSubString(t *testing.T) {
+	srv, rows, reqs := openLOB(t, cloudwave.CLOUD_TYPE_CLOB, clobText)
+	var c cloudwave.Clob
+	if err := rows.Scan(&c); err != nil {
+		t.Fatal(err)
+	}
+	clob := c.Handle()
+	clob.SetChunkSize(8) // 4 characters per request
+
+	for _, tt := range []struct {
+		pos  int64
+		n    int
+		want string
+	}{
+		{1, 5, "héllo"},
+		{7, 2, "𝄞"},
+		{10, 5, "wörld"},
+		{16, 10, "héllo"},
+		{21, 1, ""},
+		{3, 0, ""},
+	} {
+		if got, err := clob.SubString(tt.pos, tt.n); err != nil || got != tt.want {
+			t.Errorf("SubString(%d, %d) = %q, %v", tt.pos, tt.n, got, err)
+		}
+	}
+	// empty ranges aren't asked for
+	if n := len(reqs.take(cloudwave.CLOB_GET_SUB_STRING)); n != 4 {
+		t.Errorf("%d requests for substrings sent", n)
+	}
+	if _, err := clob.SubString(0, 1); err == nil {
+		t.Error("SubString accepted position 0")
+	}
+	if _, err := clob.SubString(1, -1); err == nil {
+		t.Error("SubString accepted a negative length")
+	}
+
+	// chunks are split at character boundaries
+	n, err := clob.SetString(10, "WÖRLD 𝄞")
+	if err != nil || n != 8 {
+		t.Fatalf("SetString = %d, %v", n, err)
+	}
+	if chunks := reqs.lengths(cloudwave.CLOB_SET_STRING); !equalInts(chunks, []int{3, 3, 2}) {
+		t.Errorf("SetString sent chunks %v", chunks)
+	}
+	if n, err = clob.SetString(1, ""); err != nil || n != 0 {
+		t.Errorf("SetString of nothing = %d, %v", n, err)
+	}
+	if sent := len(reqs.take(cloudwave.CLOB_SET_STRING)); sent != 0 {
+		t.Errorf("SetString of nothing sent %d requests", sent)
+	}
+	if got, err := clob.SubString(1, 30); err != nil || got != "héllo 𝄞 WÖRLD 𝄞llo" {
+		t.Errorf("SubString after SetString = %q, %v", got, err)
+	}
+	if _, err = clob.SetString(0, "x"); err == nil {
+		t.Error("SetString accepted position 0")
+	}
+
+	// replies shorter than the characters asked for are rejected
+	srv.Handle(cloudwave.CLOB_GET_SUB_STRING, func(payload []byte) ([]byte, error) {
+		return []byte{0, 0, 0, 1, 0, 'h'}, nil
+	})
+	if got, err := clob.SubString(1, 3); err == nil {
+		t.Errorf("SubString accepted a short reply %q", got)
+	}
+}
@@ -0,0 +1,347 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwavetest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unicode/utf16"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+)
+
+// Raw is a value already encoded the way the server sends values of its
+// column type, without the null flag and type code. It covers the types
+// the server doesn't encode itself, such as decimals and intervals.
+type Raw []byte
+
+// decimalScale is the scale of TINY_DECIMAL and SMALL_DECIMAL parameters,
+// which the driver sends without a scale byte.
+const decimalScale = 10
+
+var errShortPacket = errors.New("cloudwavetest: short packet")
+
+// reader decodes a request payload. The first error sticks, later reads
+// return zero values.
+type reader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.b) {
+		r.err = errShortPacket
+		return nil
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) uint8() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) int64() int64 {
+	if b := r.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads a 4-byte length followed by that many bytes.
+func (r *reader) string() string {
+	return string(r.next(int(int32(r.uint32()))))
+}
+
+// ucs2 reads a code unit count followed by that many UTF-16 code units.
+func (r *reader) ucs2() []byte {
+	return r.next(2 * int(int32(r.uint32())))
+}
+
+func (r *reader) rest() []byte {
+	return r.next(len(r.b) - r.pos)
+}
+
+// encodeUcs2 encodes s as big-endian UTF-16, the way the server sends text.
+func encodeUcs2(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.BigEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+func decodeUcs2(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// appendValue appends v as a value of a tp column: a null flag, the type
+// code and the value. LOB values are stored with store, which returns the
+// id sent in their place.
+func appendValue(b []byte, tp byte, v interface{}, store func(clob bool, data []byte) int64) ([]byte, error) {
+	if v == nil {
+		return append(b, 1), nil
+	}
+	if raw, ok := v.(Raw); ok {
+		return append(append(b, 0, tp), raw...), nil
+	}
+	b = append(b, 0, tp)
+	switch tp {
+	case cloudwave.CLOUD_TYPE_CHAR, cloudwave.CLOUD_TYPE_VARCHAR:
+		s, ok := text(v)
+		if !ok {
+			break
+		}
+		u := encodeUcs2(s)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(u)/2))
+		return append(b, u...), nil
+	case cloudwave.CLOUD_TYPE_SINGLE_CHAR:
+		s, ok := text(v)
+		if u := encodeUcs2(s); ok && len(u) == 2 {
+			return append(b, u...), nil
+		}
+	case cloudwave.CLOUD_TYPE_SINGLE_BYTE:
+		if p, ok := v.([]byte); ok && len(p) == 1 {
+			return append(b, p[0]), nil
+		}
+	case cloudwave.CLOUD_TYPE_BINARY, cloudwave.CLOUD_TYPE_VARBINARY:
+		if p, ok := v.([]byte); ok {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(p)))
+			return append(b, p...), nil
+		}
+	case cloudwave.CLOUD_TYPE_INTEGER, cloudwave.CLOUD_TYPE_TINY_INTEGER:
+		if n, ok := integer(v); ok && n >= math.MinInt32 && n <= math.MaxInt32 {
+			return binary.BigEndian.AppendUint32(b, uint32(n)), nil
+		}
+	case cloudwave.CLOUD_TYPE_LONG, cloudwave.CLOUD_TYPE_SMALL_INTEGER:
+		if n, ok := integer(v); ok {
+			return binary.BigEndian.AppendUint64(b, uint64(n)), nil
+		}
+	case cloudwave.CLOUD_TYPE_BIG_INTEGER:
+		if n, ok := integer(v); ok {
+			return binary.BigEndian.AppendUint64(append(b, 0), uint64(n)), nil
+		}
+	case cloudwave.CLOUD_TYPE_FLOAT:
+		if f, ok := float(v); ok {
+			return binary.BigEndian.AppendUint32(b, math.Float32bits(float32(f))), nil
+		}
+	case cloudwave.CLOUD_TYPE_DOUBLE:
+		if f, ok := float(v); ok {
+			return binary.BigEndian.AppendUint64(b, math.Float64bits(f)), nil
+		}
+	case cloudwave.CLOUD_TYPE_BOOLEAN:
+		if t, ok := v.(bool); ok {
+			if t {
+				return append(b, 1), nil
+			}
+			return append(b, 0), nil
+		}
+	case cloudwave.CLOUD_TYPE_DATE, cloudwave.CLOUD_TYPE_YEAR_MONTH_DAY, cloudwave.CLOUD_TYPE_COMPACT_DATE:
+		if t, ok := v.(time.Time); ok {
+			year, month, day := t.Date()
+			return binary.BigEndian.AppendUint32(b, uint32(int32(year*10000+(int(month)-1)*100+day))), nil
+		}
+	case cloudwave.CLOUD_TYPE_TIME, cloudwave.CLOUD_TYPE_TIMESTAMP:
+		if t, ok := v.(time.Time); ok {
+			return binary.BigEndian.AppendUint64(b, uint64(t.UnixMilli())), nil
+		}
+	case cloudwave.CLOUD_TYPE_BLOB:
+		if p, ok := v.([]byte); ok {
+			return binary.BigEndian.AppendUint64(b, uint64(store(false, p))), nil
+		}
+	case cloudwave.CLOUD_TYPE_CLOB:
+		if s, ok := text(v); ok {
+			return binary.BigEndian.AppendUint64(b, uint64(store(true, encodeUcs2(s)))), nil
+		}
+	default:
+		return nil, fmt.Errorf("cloudwavetest: type %d needs a Raw value", tp)
+	}
+	return nil, fmt.Errorf("cloudwavetest: can't encode %T as type %d", v, tp)
+}
+
+// readValue decodes a value the driver wrote with writeObject. Integers are
+// returned as int64, floating point numbers as float64, decimals as
+// cloudwave.Decimal, text as string,
+// temporal values as time.Time in UTC and LOBs as their content, looked up
+// with load.
+func readValue(r *reader, load func(id int64) ([]byte, bool, bool)) (interface{}, error) {
+	if r.uint8() != 0 {
+		return nil, r.err
+	}
+	tp := r.uint8()
+	var v interface{}
+	switch tp {
+	case cloudwave.CLOUD_TYPE_CHAR, cloudwave.CLOUD_TYPE_VARCHAR:
+		v = decodeUcs2(r.ucs2())
+	case cloudwave.CLOUD_TYPE_SINGLE_CHAR:
+		v = decodeUcs2(r.next(2))
+	case cloudwave.CLOUD_TYPE_SINGLE_BYTE:
+		v = append([]byte{}, r.next(1)...)
+	case cloudwave.CLOUD_TYPE_BINARY, cloudwave.CLOUD_TYPE_VARBINARY:
+		v = append([]byte{}, r.next(int(int32(r.uint32())))...)
+	case cloudwave.CLOUD_TYPE_JSON_OBJECT, cloudwave.CLOUD_TYPE_JSON_ARRAY, cloudwave.CLOUD_TYPE_JSON_TEXT,
+		cloudwave.CLOUD_TYPE_JSON_BINARY, cloudwave.CLOUD_TYPE_JSON_KEYWORD, cloudwave.CLOUD_TYPE_JSON_BIGDECIMAL:
+		// the JSON text
+		v = string(r.next(int(int32(r.uint32()))))
+	case cloudwave.CLOUD_TYPE_INTEGER, cloudwave.CLOUD_TYPE_TINY_INTEGER:
+		v = int64(int32(r.uint32()))
+	case cloudwave.CLOUD_TYPE_LONG, cloudwave.CLOUD_TYPE_SMALL_INTEGER:
+		v = r.int64()
+	case cloudwave.CLOUD_TYPE_BIG_INTEGER:
+		if r.uint8() != 0 {
+			return nil, fmt.Errorf("cloudwavetest: can't decode long BIG_INTEGER values")
+		}
+		v = r.int64()
+	case cloudwave.CLOUD_TYPE_TINY_DECIMAL:
+		v = cloudwave.NewDecimalFromInt64(int64(int32(r.uint32())), decimalScale)
+	case cloudwave.CLOUD_TYPE_SMALL_DECIMAL:
+		v = cloudwave.NewDecimalFromInt64(r.int64(), decimalScale)
+	case cloudwave.CLOUD_TYPE_BIG_DECIMAL:
+		if r.uint8() != 0 {
+			return nil, fmt.Errorf("cloudwavetest: can't decode long BIG_DECIMAL values")
+		}
+		n := r.int64()
+		v = cloudwave.NewDecimalFromInt64(n, int32(r.uint8()))
+	case cloudwave.CLOUD_TYPE_FLOAT:
+		v = float64(math.Float32frombits(r.uint32()))
+	case cloudwave.CLOUD_TYPE_DOUBLE:
+		v = math.Float64frombits(uint64(r.int64()))
+	case cloudwave.CLOUD_TYPE_BOOLEAN:
+		v = r.uint8() != 0
+	case cloudwave.CLOUD_TYPE_DATE, cloudwave.CLOUD_TYPE_YEAR_MONTH_DAY, cloudwave.CLOUD_TYPE_COMPACT_DATE:
+		d := int(int32(r.uint32()))
+		v = time.Date(d/10000, time.Month((d%10000)/100+1), d%100, 0, 0, 0, 0, time.UTC)
+	case cloudwave.CLOUD_TYPE_TIME, cloudwave.CLOUD_TYPE_TIMESTAMP:
+		v = time.UnixMilli(r.int64()).UTC()
+	case cloudwave.CLOUD_TYPE_BLOB, cloudwave.CLOUD_TYPE_CLOB:
+		id := r.int64()
+		data, clob, ok := load(id)
+		if !ok {
+			return nil, fmt.Errorf("cloudwavetest: unknown LOB %d", id)
+		}
+		if clob {
+			v = decodeUcs2(data)
+		} else {
+			v = data
+		}
+	default:
+		return nil, fmt.Errorf("cloudwavetest: can't decode parameters of type %d", tp)
+	}
+	return v, r.err
+}
+
+func text(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	}
+	return "", false
+}
+
+func integer(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), true
+		}
+	}
+	return 0, false
+}
+
+func float(v interface{}) (float64, bool) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), true
+	case float64:
+		return f, true
+	}
+	if n, ok := integer(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// jdbcTypes maps the types of result columns to the JDBC type codes and
+// type names of the result set header.
+var jdbcTypes = map[byte]struct {
+	code int32
+	name string
+}{
+	cloudwave.CLOUD_TYPE_INTEGER:       {cloudwave.INTEGER, "INTEGER"},
+	cloudwave.CLOUD_TYPE_TINY_INTEGER:  {cloudwave.INTEGER, "INTEGER"},
+	cloudwave.CLOUD_TYPE_LONG:          {cloudwave.BIGINT, "BIGINT"},
+	cloudwave.CLOUD_TYPE_SMALL_INTEGER: {cloudwave.BIGINT, "BIGINT"},
+	cloudwave.CLOUD_TYPE_BIG_INTEGER:   {cloudwave.NUMERIC, "NUMERIC"},
+	cloudwave.CLOUD_TYPE_TINY_DECIMAL:  {cloudwave.NUMERIC, "NUMERIC"},
+	cloudwave.CLOUD_TYPE_SMALL_DECIMAL: {cloudwave.NUMERIC, "NUMERIC"},
+	cloudwave.CLOUD_TYPE_BIG_DECIMAL:   {cloudwave.NUMERIC, "NUMERIC"},
+	cloudwave.CLOUD_TYPE_FLOAT:         {cloudwave.FLOAT, "FLOAT"},
+	cloudwave.CLOUD_TYPE_DOUBLE:        {cloudwave.DOUBLE, "DOUBLE"},
+	cloudwave.CLOUD_TYPE_BOOLEAN:       {cloudwave.BOOLEAN, "BOOLEAN"},
+	cloudwave.CLOUD_TYPE_CHAR:          {cloudwave.CHAR, "CHAR"},
+	cloudwave.CLOUD_TYPE_SINGLE_CHAR:   {cloudwave.CHAR, "CHAR"},
+	cloudwave.CLOUD_TYPE_VARCHAR:       {cloudwave.VARCHAR, "VARCHAR"},
+	cloudwave.CLOUD_TYPE_BINARY:        {cloudwave.BINARY, "BINARY"},
+	cloudwave.CLOUD_TYPE_SINGLE_BYTE:   {cloudwave.BINARY, "BINARY"},
+	cloudwave.CLOUD_TYPE_VARBINARY:     {cloudwave.VARBINARY, "VARBINARY"},
+	cloudwave.CLOUD_TYPE_DATE:          {cloudwave.DATE, "DATE"},
+	cloudwave.CLOUD_TYPE_TIME:          {cloudwave.TIME, "TIME"},
+	cloudwave.CLOUD_TYPE_TIMESTAMP:     {cloudwave.TIMESTAMP, "TIMESTAMP"},
+	cloudwave.CLOUD_TYPE_BLOB:          {cloudwave.BLOB, "BLOB"},
+	cloudwave.CLOUD_TYPE_CLOB:          {cloudwave.CLOB, "CLOB"},
+	cloudwave.CLOUD_TYPE_ARRAY:         {cloudwave.ARRAY, "ARRAY"},
+}
+
+// paramType returns the parameter type a prepared statement reports for
+// arguments like v, when the expectation doesn't set the types.
+func paramType(v interface{}) byte {
+	switch v.(type) {
+	case bool:
+		return cloudwave.CLOUD_TYPE_BOOLEAN
+	case float32, float64:
+		return cloudwave.CLOUD_TYPE_DOUBLE
+	case []byte:
+		return cloudwave.CLOUD_TYPE_VARBINARY
+	case time.Time:
+		return cloudwave.CLOUD_TYPE_TIMESTAMP
+	}
+	if _, ok := integer(v); ok {
+		return cloudwave.CLOUD_TYPE_LONG
+	}
+	return cloudwave.CLOUD_TYPE_VARCHAR
+}
@@ -0,0 +1,217 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwavetest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+)
+
+// Column describes a column of a canned result set. Type is one of the
+// cloudwave.CLOUD_TYPE_* codes writeObject uses. TypeName defaults to the
+// SQL name of Type.
+type Column struct {
+	Name      string // "column" or "table.column"
+	Type      byte
+	TypeName  string
+	Precision uint32
+	Scale     uint32
+}
+
+// AutoKeyColumn is the name of the hidden column the server appends to the
+// rows of tables with an auto sequence, holding the key of each row. The
+// driver reads it but leaves it out of the columns of the rows.
+const AutoKeyColumn = "__WISDOM_AUTO_KEY__"
+
+// Rows is a canned result set.
+type Rows struct {
+	columns []Column
+	rows    [][]interface{}
+}
+
+// NewRows returns an empty result set with the given columns.
+func NewRows(columns ...Column) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow appends a row holding one value per column. Values are encoded
+// according to the column type: strings for character and CLOB columns,
+// []byte for binary and BLOB columns, any integer type for integer
+// columns, float32 or float64 for FLOAT and DOUBLE, bool for BOOLEAN,
+// time.Time for DATE, TIME and TIMESTAMP, and Raw for any type. A nil
+// value is NULL.
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	r.rows = append(r.rows, values)
+	return r
+}
+
+// Argument matches an argument of a prepared statement.
+type Argument interface {
+	Match(v interface{}) bool
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(interface{}) bool { return true }
+
+// AnyArg returns an Argument matching any value, including NULL.
+func AnyArg() Argument {
+	return anyArg{}
+}
+
+// Expectation is a statement the server expects, along with its response.
+// An expectation is met by a single execution.
+type Expectation struct {
+	query      string
+	args       []interface{}
+	hasArgs    bool
+	paramTypes []byte
+	rows       *Rows
+	affected   int64
+	err        *Error
+	delay      time.Duration
+	closeConn  bool
+	met        bool
+}
+
+// Error is an error the server returns, read by the driver as a
+// *cloudwave.CloudWaveError.
+type Error struct {
+	Brief   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Error %s: %s", e.Brief, e.Message)
+}
+
+// WithArgs sets the arguments a prepared execution must be run with. Values
+// are compared after decoding: integers as int64, floating point numbers as
+// float64, decimals by value and times by time.Time.Equal. An Argument matches values itself.
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = args
+	e.hasArgs = true
+	return e
+}
+
+// WithParamTypes sets the parameter types reported when the statement is
+// prepared. They default to types inferred from the arguments of WithArgs,
+// and to VARCHAR for the placeholders otherwise.
+func (e *Expectation) WithParamTypes(types ...byte) *Expectation {
+	e.paramTypes = types
+	return e
+}
+
+// WillReturnRows makes the statement return rows.
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult makes the statement report affected rows.
+func (e *Expectation) WillReturnResult(affected int64) *Expectation {
+	e.affected = affected
+	return e
+}
+
+// WillReturnError makes the statement fail with the given error.
+func (e *Expectation) WillReturnError(brief, message string) *Expectation {
+	e.err = &Error{Brief: brief, Message: message}
+	return e
+}
+
+// WillCloseConnection makes the server close the connection instead of
+// responding, as if it failed.
+func (e *Expectation) WillCloseConnection() *Expectation {
+	e.closeConn = true
+	return e
+}
+
+// WillDelayFor delays the response by d.
+func (e *Expectation) WillDelayFor(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+func (e *Expectation) String() string {
+	if e.hasArgs {
+		return fmt.Sprintf("%q with args %v", e.query, e.args)
+	}
+	return fmt.Sprintf("%q", e.query)
+}
+
+// types returns the parameter types of the statement, which has n
+// placeholders.
+func (e *Expectation) types(n int) []byte {
+	if e.paramTypes != nil {
+		return e.paramTypes
+	}
+	if e.hasArgs {
+		n = len(e.args)
+	}
+	types := make([]byte, n)
+	for i := range types {
+		types[i] = paramType(nil)
+		if i < len(e.args) {
+			types[i] = paramType(e.args[i])
+		}
+	}
+	return types
+}
+
+func (e *Expectation) matchArgs(args []interface{}) bool {
+	if !e.hasArgs {
+		return true
+	}
+	if len(args) != len(e.args) {
+		return false
+	}
+	for i, want := range e.args {
+		if !matchArg(want, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchArg(want, got interface{}) bool {
+	if m, ok := want.(Argument); ok {
+		return m.Match(got)
+	}
+	switch w := want.(type) {
+	case nil:
+		return got == nil
+	case time.Time:
+		g, ok := got.(time.Time)
+		return ok && w.Equal(g)
+	case []byte:
+		g, ok := got.([]byte)
+		return ok && bytes.Equal(w, g)
+	case float32, float64:
+		f, _ := float(w)
+		return f == got
+	case cloudwave.Decimal:
+		g, ok := got.(cloudwave.Decimal)
+		return ok && w.Cmp(g) == 0
+	}
+	if n, ok := integer(want); ok {
+		return n == got
+	}
+	return want == got
+}
+
+// normalizeQuery collapses the whitespace of query, which is compared with
+// the expected queries.
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
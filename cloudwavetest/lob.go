@@ -0,0 +1,186 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwavetest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+)
+
+func isLOBCommand(cmd int) bool {
+	switch cmd {
+	case cloudwave.BLOB_LENGTH, cloudwave.CLOB_LENGTH,
+		cloudwave.BLOB_GET_BINARY_STREAM, cloudwave.CLOB_GET_ASCII_STREAM, cloudwave.CLOB_GET_CHARACTER_STREAM,
+		cloudwave.LOB_READ_BUFFER, cloudwave.CLOB_READ,
+		cloudwave.BLOB_SET_BINARY_STREAM, cloudwave.CLOB_SET_ASCII_STREAM, cloudwave.CLOB_SET_CHARACTER_STREAM,
+		cloudwave.LOB_WRITE_BUFFER, cloudwave.CLOB_WRITE,
+		cloudwave.BLOB_TRUNCATE, cloudwave.CLOB_TRUNCATE, cloudwave.BLOB_FREE, cloudwave.CLOB_FREE,
+		cloudwave.BLOB_GET_BYTES, cloudwave.BLOB_SET_BYTES, cloudwave.CLOB_GET_SUB_STRING, cloudwave.CLOB_SET_STRING,
+		cloudwave.BLOB_POSITION_BYTEARRAY_PATTERN, cloudwave.BLOB_POSITION_BLOB_PATTERN,
+		cloudwave.CLOB_POSITION_STRING, cloudwave.CLOB_POSITION_CLOB:
+		return true
+	}
+	return false
+}
+
+// lobCommand runs a LOB command. Its payload starts with the statement,
+// cursor and id of the LOB. Positions and lengths of CLOBs count UTF-16 code
+// units, except for those of CLOB_READ and CLOB_WRITE, which count bytes.
+// Writes of a stream carry their position in the LOB, not in the stream.
+func (c *session) lobCommand(cmd int, r *reader) ([]byte, error) {
+	r.uint32() // statement
+	r.uint32() // cursor
+	id := r.int64()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// A new LOB gets its id on the first write of its stream.
+	switch cmd {
+	case cloudwave.BLOB_SET_BINARY_STREAM, cloudwave.CLOB_SET_ASCII_STREAM, cloudwave.CLOB_SET_CHARACTER_STREAM:
+		r.int64() // start, the writes carry their positions
+		return ok(), r.err
+	case cloudwave.LOB_WRITE_BUFFER, cloudwave.CLOB_WRITE:
+		clob := cmd == cloudwave.CLOB_WRITE
+		pos := r.int64()
+		if clob {
+			pos *= 2
+		}
+		data := r.next(int(int32(r.uint32())))
+		if r.err != nil {
+			return nil, r.err
+		}
+		if id == -1 {
+			id = c.srv.storeLOB(clob, nil)
+		}
+		err := c.srv.updateLOB(id, func(l *lob) {
+			l.data = overwrite(l.data, pos, data)
+		})
+		return binary.BigEndian.AppendUint64(ok(), uint64(id)), err
+	case cloudwave.BLOB_FREE, cloudwave.CLOB_FREE:
+		return ok(), nil
+	}
+
+	data, _, found := c.srv.loadLOB(id)
+	if !found {
+		return nil, fmt.Errorf("cloudwavetest: unknown LOB %d", id)
+	}
+	switch cmd {
+	case cloudwave.BLOB_LENGTH:
+		return binary.BigEndian.AppendUint64(ok(), uint64(len(data))), nil
+	case cloudwave.CLOB_LENGTH:
+		return binary.BigEndian.AppendUint64(ok(), uint64(len(data)/2)), nil
+	case cloudwave.BLOB_GET_BINARY_STREAM, cloudwave.CLOB_GET_ASCII_STREAM, cloudwave.CLOB_GET_CHARACTER_STREAM:
+		return ok(), nil
+	case cloudwave.LOB_READ_BUFFER, cloudwave.CLOB_READ:
+		pos := r.int64()
+		n := int64(r.uint32())
+		return ok(window(data, pos, n)...), r.err
+	case cloudwave.BLOB_GET_BYTES:
+		pos := r.int64()
+		n := int64(r.uint32())
+		return ok(window(data, pos, n)...), r.err
+	case cloudwave.CLOB_GET_SUB_STRING:
+		pos := r.int64()
+		n := int64(r.uint32())
+		sub := window(data, 2*pos, 2*n)
+		return append(binary.BigEndian.AppendUint32(ok(), uint32(len(sub)/2)), sub...), r.err
+	case cloudwave.BLOB_SET_BYTES, cloudwave.CLOB_SET_STRING:
+		pos := r.int64()
+		var p []byte
+		if cmd == cloudwave.CLOB_SET_STRING {
+			pos *= 2
+			p = r.ucs2()
+		} else {
+			p = r.next(int(int32(r.uint32())))
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		return ok(), c.srv.updateLOB(id, func(l *lob) { l.data = overwrite(l.data, pos, p) })
+	case cloudwave.BLOB_TRUNCATE, cloudwave.CLOB_TRUNCATE:
+		n := r.int64()
+		if cmd == cloudwave.CLOB_TRUNCATE {
+			n *= 2
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		return ok(), c.srv.updateLOB(id, func(l *lob) {
+			if n >= 0 && n < int64(len(l.data)) {
+				l.data = l.data[:n]
+			}
+		})
+	case cloudwave.BLOB_POSITION_BYTEARRAY_PATTERN, cloudwave.BLOB_POSITION_BLOB_PATTERN,
+		cloudwave.CLOB_POSITION_STRING, cloudwave.CLOB_POSITION_CLOB:
+		var pattern []byte
+		switch cmd {
+		case cloudwave.BLOB_POSITION_BYTEARRAY_PATTERN:
+			pattern = r.next(int(int32(r.uint32())))
+		case cloudwave.CLOB_POSITION_STRING:
+			pattern = r.ucs2()
+		default:
+			pid := r.int64()
+			if pattern, _, found = c.srv.loadLOB(pid); !found && r.err == nil {
+				return nil, fmt.Errorf("cloudwavetest: unknown LOB %d", pid)
+			}
+		}
+		start := r.int64()
+		if r.err != nil {
+			return nil, r.err
+		}
+		unit := int64(1)
+		if cmd == cloudwave.CLOB_POSITION_STRING || cmd == cloudwave.CLOB_POSITION_CLOB {
+			unit = 2
+		}
+		pos := int64(-1)
+		for i := start * unit; i >= 0 && i+int64(len(pattern)) <= int64(len(data)); i += unit {
+			if bytes.Equal(data[i:i+int64(len(pattern))], pattern) {
+				pos = i / unit
+				break
+			}
+		}
+		return binary.BigEndian.AppendUint64(ok(), uint64(pos)), nil
+	}
+	return nil, fmt.Errorf("cloudwavetest: unsupported command %d", cmd)
+}
+
+func (s *Server) updateLOB(id int64, f func(l *lob)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.lobs[id]
+	if !ok {
+		return fmt.Errorf("cloudwavetest: unknown LOB %d", id)
+	}
+	f(l)
+	return nil
+}
+
+// window returns up to n bytes of data at pos.
+func window(data []byte, pos, n int64) []byte {
+	if pos < 0 || pos >= int64(len(data)) || n <= 0 {
+		return nil
+	}
+	if end := pos + n; end < int64(len(data)) {
+		return data[pos:end]
+	}
+	return data[pos:]
+}
+
+// overwrite writes p into data at pos, extending data as needed.
+func overwrite(data []byte, pos int64, p []byte) []byte {
+	if end := int(pos) + len(p); end > len(data) {
+		data = append(data, make([]byte, end-len(data))...)
+	}
+	copy(data[pos:], p)
+	return data
+}
@@ -0,0 +1,199 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwavetest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+)
+
+// ReplayServer answers the requests of the driver with the responses of a
+// trace recorded with cloudwave.RegisterTraceSink, so that problems seen
+// against a real server can be reproduced offline:
+//
+//	f, err := os.Open("cloudwave.trace")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	recs, err := cloudwave.ReadTrace(f)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	srv, err := cloudwavetest.NewReplayServer(recs)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Close()
+//	db, err := sql.Open("cloudwave", srv.DSN())
+//
+// The n-th connection accepted replays the n-th connection of the trace.
+// Requests are matched by their command only, so the statements run have to
+// be those of the trace but the credentials needn't be. When a request
+// differs from the trace, it is answered with an error and the connection
+// is closed; Err reports the first such difference.
+type ReplayServer struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu     sync.Mutex
+	convs  [][]cloudwave.TraceRecord
+	next   int
+	conns  map[net.Conn]struct{}
+	err    error
+	closed bool
+}
+
+// NewReplayServer starts a server replaying recs, which hold the records
+// of one or more connections, on a random port of the loopback interface.
+func NewReplayServer(recs []cloudwave.TraceRecord) (*ReplayServer, error) {
+	var convs [][]cloudwave.TraceRecord
+	index := make(map[uint64]int)
+	for _, rec := range recs {
+		i, found := index[rec.Conn]
+		if !found {
+			i = len(convs)
+			index[rec.Conn] = i
+			convs = append(convs, nil)
+		}
+		convs[i] = append(convs[i], rec)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &ReplayServer{
+		ln:    ln,
+		convs: convs,
+		conns: make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *ReplayServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// DSN returns a data source name connecting to the server. Parameters
+// changing the requests of the driver must be those of the traced
+// connections.
+func (s *ReplayServer) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s", DefaultUser, DefaultPassword, s.Addr(), DefaultDatabase)
+}
+
+// Err returns the first difference between the requests of the driver and
+// the trace, or nil.
+func (s *ReplayServer) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops the server and closes its connections.
+func (s *ReplayServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *ReplayServer) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+		var conv []cloudwave.TraceRecord
+		if s.next < len(s.convs) {
+			conv = s.convs[s.next]
+		}
+		s.next++
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.replay(c, conv)
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+			c.Close()
+		}()
+	}
+}
+
+func (s *ReplayServer) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+}
+
+// replay answers the requests of connection c with the responses of conv.
+func (s *ReplayServer) replay(c net.Conn, conv []cloudwave.TraceRecord) {
+	sess := &session{conn: c}
+	for {
+		cmd, _, err := sess.readRequest()
+		if err != nil {
+			if err != io.EOF && !s.isClosed() {
+				s.fail(err)
+			}
+			return
+		}
+		// skip to the next request of the trace
+		for len(conv) > 0 && conv[0].Direction != cloudwave.TraceSend {
+			conv = conv[1:]
+		}
+		if len(conv) == 0 {
+			err = fmt.Errorf("cloudwavetest: request %s after the end of the trace", cloudwave.CommandName(cmd))
+		} else if conv[0].Opcode != cmd {
+			err = fmt.Errorf("cloudwavetest: request %s, the trace has %s", cloudwave.CommandName(cmd), conv[0].Name)
+		}
+		if err != nil {
+			s.fail(err)
+			sess.writeResponse(errorPacket(err))
+			return
+		}
+		conv = conv[1:]
+		for len(conv) > 0 && conv[0].Direction == cloudwave.TraceRecv {
+			if err = sess.writeResponse(conv[0].Payload); err != nil {
+				return
+			}
+			conv = conv[1:]
+		}
+		if cmd == cloudwave.B_REQ_CLOSE_CONNECTION {
+			return
+		}
+	}
+}
+
+func (s *ReplayServer) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
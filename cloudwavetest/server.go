@@ -0,0 +1,664 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package cloudwavetest provides an in-process server speaking the
+// CloudWave wire protocol, for testing code using the driver without a
+// cluster.
+//
+// The server answers the statements it is told to expect with canned
+// results:
+//
+//	srv, err := cloudwavetest.NewServer()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Close()
+//
+//	srv.Expect("SELECT id, name FROM users WHERE id = ?").
+//		WithArgs(1).
+//		WillReturnRows(cloudwavetest.NewRows(
+//			cloudwavetest.Column{Name: "id", Type: cloudwave.CLOUD_TYPE_LONG},
+//			cloudwavetest.Column{Name: "name", Type: cloudwave.CLOUD_TYPE_VARCHAR},
+//		).AddRow(1, "alice"))
+//
+//	db, err := sql.Open("cloudwave", srv.DSN())
+//
+// Statements run without arguments are matched when they are executed,
+// statements with arguments when the prepared statement is executed with
+// matching arguments. USE and SET statements without an expectation
+// succeed, so that connecting needs no setup. BLOB and CLOB values are kept
+// in memory, so LOB columns can be read and LOB parameters written.
+package cloudwavetest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+)
+
+// Default credentials and database of the server.
+const (
+	DefaultUser     = "test"
+	DefaultPassword = "test"
+	DefaultDatabase = "test"
+)
+
+// Version is the version GET_SERVER_VERSION reports by default. It holds no
+// version number, so the driver enables every feature.
+const Version = "cloudwavetest"
+
+// HandlerFunc handles a command of the server. It is passed the payload
+// following the request header and returns the response following the OK
+// status byte, or an error sent as an error response. ErrCloseConnection
+// closes the connection without a response.
+type HandlerFunc func(payload []byte) ([]byte, error)
+
+// ErrCloseConnection makes the server close the connection, as a server
+// dropping the session does.
+var ErrCloseConnection = errors.New("cloudwavetest: connection closed")
+
+// Server is an in-process CloudWave server.
+type Server struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	user     string
+	password string
+	version  string
+	expected []*Expectation
+	handlers map[int]HandlerFunc
+	lobs     map[int64]*lob
+	lastLOB  int64
+	conns    map[net.Conn]struct{}
+	sessions uint64
+	closed   bool
+}
+
+type lob struct {
+	clob bool
+	data []byte // UTF-16 for CLOBs
+}
+
+// NewServer starts a server listening on a random port of the loopback
+// interface.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:       ln,
+		user:     DefaultUser,
+		password: DefaultPassword,
+		version:  Version,
+		handlers: make(map[int]HandlerFunc),
+		lobs:     make(map[int64]*lob),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// DSN returns a data source name connecting to the server.
+func (s *Server) DSN() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s", s.user, s.password, s.Addr(), DefaultDatabase)
+}
+
+// SetCredentials sets the user and password new connections must log in
+// with.
+func (s *Server) SetCredentials(user, password string) {
+	s.mu.Lock()
+	s.user, s.password = user, password
+	s.mu.Unlock()
+}
+
+// SetVersion sets the version string GET_SERVER_VERSION reports, such as
+// "CloudWave 2.4.1".
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	s.version = version
+	s.mu.Unlock()
+}
+
+// Expect adds an expected statement. Whitespace is collapsed before
+// statements are compared.
+func (s *Server) Expect(query string) *Expectation {
+	e := &Expectation{query: normalizeQuery(query)}
+	s.mu.Lock()
+	s.expected = append(s.expected, e)
+	s.mu.Unlock()
+	return e
+}
+
+// Handle sets the handler of the command cmd, such as an admin command, or
+// overrides the built-in handling of a command. A nil handler removes it.
+func (s *Server) Handle(cmd int, h HandlerFunc) {
+	s.mu.Lock()
+	if h == nil {
+		delete(s.handlers, cmd)
+	} else {
+		s.handlers[cmd] = h
+	}
+	s.mu.Unlock()
+}
+
+// ExpectationsWereMet returns an error listing the expected statements
+// that weren't executed.
+func (s *Server) ExpectationsWereMet() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var unmet []string
+	for _, e := range s.expected {
+		if !e.met {
+			unmet = append(unmet, e.String())
+		}
+	}
+	if len(unmet) > 0 {
+		return fmt.Errorf("cloudwavetest: expected statements weren't executed: %s", strings.Join(unmet, ", "))
+	}
+	return nil
+}
+
+// Close stops the server and closes its connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.sessions++
+		sess := &session{
+			srv:      s,
+			conn:     c,
+			time:     uint64(time.Now().UnixNano()),
+			sequence: s.sessions,
+			stmts:    make(map[uint32]*statement),
+			cursors:  make(map[int32]*cursor),
+		}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			sess.run()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+			c.Close()
+		}()
+	}
+}
+
+// match returns the first unmet expectation of query matching args, and
+// marks it as met. A nil args matches any expectation of query.
+func (s *Server) match(query string, args []interface{}) (*Expectation, error) {
+	query = normalizeQuery(query)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for _, e := range s.expected {
+		if e.met || e.query != query {
+			continue
+		}
+		if args == nil || e.matchArgs(args) {
+			e.met = true
+			return e, nil
+		}
+		found = true
+	}
+	if found {
+		return nil, &Error{Brief: "cloudwavetest", Message: fmt.Sprintf("unexpected arguments %v for %q", args, query)}
+	}
+	return nil, &Error{Brief: "cloudwavetest", Message: fmt.Sprintf("unexpected statement %q", query)}
+}
+
+// lookup returns the first unmet expectation of query, without marking it.
+func (s *Server) lookup(query string) *Expectation {
+	query = normalizeQuery(query)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.expected {
+		if !e.met && e.query == query {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *Server) handler(cmd int) HandlerFunc {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handlers[cmd]
+}
+
+// storeLOB adds a LOB and returns its id.
+func (s *Server) storeLOB(clob bool, data []byte) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastLOB++
+	s.lobs[s.lastLOB] = &lob{clob: clob, data: append([]byte{}, data...)}
+	return s.lastLOB
+}
+
+func (s *Server) loadLOB(id int64) ([]byte, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.lobs[id]
+	if !ok {
+		return nil, false, false
+	}
+	return append([]byte{}, l.data...), l.clob, true
+}
+
+// session is a client connection.
+type session struct {
+	srv      *Server
+	conn     net.Conn
+	time     uint64
+	sequence uint64
+
+	lastStmt   uint32
+	lastCursor int32
+	stmts      map[uint32]*statement
+	cursors    map[int32]*cursor
+}
+
+type statement struct {
+	query      string
+	paramTypes []byte
+}
+
+type cursor struct {
+	rows *Rows
+	next int
+}
+
+func (c *session) run() {
+	for {
+		cmd, payload, err := c.readRequest()
+		if err != nil {
+			return
+		}
+		if cmd == cloudwave.B_REQ_CLOSE_CONNECTION {
+			return
+		}
+		resp, err := c.dispatch(cmd, payload)
+		if err == ErrCloseConnection {
+			return
+		}
+		if err != nil {
+			resp = errorPacket(err)
+		}
+		if err = c.writeResponse(resp); err != nil {
+			return
+		}
+	}
+}
+
+// readRequest reads a request and returns its command and the payload
+// following the header.
+func (c *session) readRequest() (int, []byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(c.conn, head[:]); err != nil {
+		return 0, nil, err
+	}
+	if head[0] != cloudwave.B_REQ_TAG {
+		return 0, nil, fmt.Errorf("cloudwavetest: bad request tag %#x", head[0])
+	}
+	body := make([]byte, binary.BigEndian.Uint32(head[1:]))
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		return 0, nil, err
+	}
+	if len(body) < 4 {
+		return 0, nil, errShortPacket
+	}
+	cmd := int(int32(binary.BigEndian.Uint32(body)))
+	// the session time and sequence precede the payload, once connected
+	if cmd == cloudwave.B_REQ_BUILD_CONNECTION {
+		return cmd, body[4:], nil
+	}
+	if len(body) < 20 {
+		return 0, nil, errShortPacket
+	}
+	return cmd, body[20:], nil
+}
+
+func (c *session) writeResponse(resp []byte) error {
+	b := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(resp)), uint32(4+len(resp)))
+	_, err := c.conn.Write(append(b, resp...))
+	return err
+}
+
+func ok(b ...byte) []byte {
+	return append([]byte{1}, b...)
+}
+
+func errorPacket(err error) []byte {
+	e, isErr := err.(*Error)
+	if !isErr {
+		e = &Error{Brief: "cloudwavetest", Message: err.Error()}
+	}
+	b := []byte{0}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(e.Brief)))
+	b = append(b, e.Brief...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(e.Message)))
+	return append(b, e.Message...)
+}
+
+func (c *session) dispatch(cmd int, payload []byte) ([]byte, error) {
+	if h := c.srv.handler(cmd); h != nil {
+		resp, err := h(payload)
+		if err != nil {
+			return nil, err
+		}
+		return ok(resp...), nil
+	}
+	r := &reader{b: payload}
+	switch cmd {
+	case cloudwave.B_REQ_BUILD_CONNECTION:
+		return c.connect(r)
+	case cloudwave.B_REQ_PING:
+		return ok(), nil
+	case cloudwave.CONNECTION_SET_AUTO_COMMIT, cloudwave.CONNECTION_COMMIT,
+		cloudwave.CONNECTION_ROLLBACK, cloudwave.SET_TRANSACTION_ISOLATION:
+		return ok(), nil
+	case cloudwave.GET_SERVER_VERSION:
+		c.srv.mu.Lock()
+		version := c.srv.version
+		c.srv.mu.Unlock()
+		return ok(appendString(nil, version)...), nil
+	case cloudwave.CONNECTION_CREATE_STATEMENT:
+		c.lastStmt++
+		c.stmts[c.lastStmt] = &statement{}
+		return ok(binary.BigEndian.AppendUint32(nil, c.lastStmt)...), nil
+	case cloudwave.CLOSE_STATEMENT, cloudwave.CLOSE_PREPARED_STATEMENT:
+		id := r.uint32()
+		delete(c.stmts, id)
+		return ok(binary.BigEndian.AppendUint32(nil, id)...), r.err
+	case cloudwave.EXECUTE_STATEMENT:
+		return c.execute(r)
+	case cloudwave.CONNECTION_PREPARED_STATEMENT:
+		return c.prepare(r)
+	case cloudwave.EXECUTE_PREPARED_STATEMENT:
+		return c.executePrepared(r, false)
+	case cloudwave.EXECUTE_BATCH_PREPARED:
+		return c.executePrepared(r, true)
+	case cloudwave.RESULT_SET_QUERY_NEXT:
+		return c.next(r)
+	case cloudwave.RESULT_SET_GET_RECORD_COUNT:
+		r.uint32()
+		cur := c.cursors[int32(r.uint32())]
+		if cur == nil {
+			return nil, fmt.Errorf("cloudwavetest: unknown cursor")
+		}
+		return ok(binary.BigEndian.AppendUint64(nil, uint64(len(cur.rows.rows)))...), r.err
+	}
+	if isLOBCommand(cmd) {
+		return c.lobCommand(cmd, r)
+	}
+	return nil, fmt.Errorf("cloudwavetest: unsupported command %d", cmd)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func (c *session) connect(r *reader) ([]byte, error) {
+	user := r.string()
+	password := r.string()
+	r.string() // time zone
+	if r.err != nil {
+		return nil, r.err
+	}
+	c.srv.mu.Lock()
+	wantUser, wantPassword := c.srv.user, c.srv.password
+	c.srv.mu.Unlock()
+	sum := sha1.Sum([]byte(wantPassword))
+	if user != wantUser || password != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, &Error{Brief: "28000", Message: fmt.Sprintf("access denied for user %q", user)}
+	}
+	b := binary.BigEndian.AppendUint64(ok(), c.time)
+	b = binary.BigEndian.AppendUint64(b, c.sequence)
+	return binary.BigEndian.AppendUint64(b, 0), nil
+}
+
+// header returns the start of a result set header.
+func (c *session) header(stmtID uint32, cursorID int32, affected int64) []byte {
+	b := binary.BigEndian.AppendUint64(ok(), c.time)
+	b = binary.BigEndian.AppendUint64(b, c.sequence)
+	b = binary.BigEndian.AppendUint32(b, stmtID)
+	b = binary.BigEndian.AppendUint32(b, uint32(cursorID))
+	return binary.BigEndian.AppendUint32(b, uint32(affected))
+}
+
+// respond returns the response of e to the execution of statement stmtID.
+func (c *session) respond(stmtID uint32, e *Expectation) ([]byte, error) {
+	if e.delay > 0 {
+		time.Sleep(e.delay)
+	}
+	if e.closeConn {
+		return nil, ErrCloseConnection
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.rows == nil {
+		return c.header(stmtID, -1, e.affected), nil
+	}
+	c.lastCursor++
+	c.cursors[c.lastCursor] = &cursor{rows: e.rows}
+	b := c.header(stmtID, c.lastCursor, 0)
+	b = append(b, 1, 1) // a query, without correlation name
+	b = binary.BigEndian.AppendUint32(b, uint32(len(e.rows.columns)))
+	for _, col := range e.rows.columns {
+		jt, known := jdbcTypes[col.Type]
+		if !known {
+			jt.code, jt.name = cloudwave.OTHER, "OTHER"
+		}
+		if col.TypeName != "" {
+			jt.name = col.TypeName
+		}
+		b = appendString(append(b, 0), col.Name)
+		b = binary.BigEndian.AppendUint32(b, uint32(jt.code))
+		b = appendString(append(b, 0), jt.name)
+		b = binary.BigEndian.AppendUint32(b, col.Precision)
+		b = binary.BigEndian.AppendUint32(b, col.Scale)
+		b = append(b, 1) // no class name
+	}
+	return b, nil
+}
+
+func (c *session) execute(r *reader) ([]byte, error) {
+	id := r.uint32()
+	r.uint32() // execution sequence
+	query := r.string()
+	if r.err != nil {
+		return nil, r.err
+	}
+	e, err := c.srv.match(query, nil)
+	if err != nil {
+		if keyword := strings.ToUpper(firstWord(query)); keyword == "USE" || keyword == "SET" {
+			return c.header(id, -1, 0), nil
+		}
+		return nil, err
+	}
+	return c.respond(id, e)
+}
+
+func firstWord(query string) string {
+	if f := strings.Fields(query); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+func (c *session) prepare(r *reader) ([]byte, error) {
+	query := r.string()
+	if r.err != nil {
+		return nil, r.err
+	}
+	e := c.srv.lookup(query)
+	if e == nil {
+		return nil, &Error{Brief: "cloudwavetest", Message: fmt.Sprintf("unexpected statement %q", normalizeQuery(query))}
+	}
+	types := e.types(countPlaceholders(query))
+	c.lastStmt++
+	c.stmts[c.lastStmt] = &statement{query: query, paramTypes: types}
+	b := binary.BigEndian.AppendUint32(ok(), c.lastStmt)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(types)))
+	b = append(b, types...)
+	return binary.BigEndian.AppendUint32(b, uint32(0xffffffff)), nil
+}
+
+func (c *session) executePrepared(r *reader, batch bool) ([]byte, error) {
+	id := r.uint32()
+	r.uint32() // execution sequence
+	r.uint32() // cursor
+	if !batch {
+		r.uint32() // execution type
+	}
+	n := int(r.uint32())
+	if batch {
+		r.uint32()
+	}
+	stmt := c.stmts[id]
+	if r.err != nil {
+		return nil, r.err
+	}
+	if stmt == nil {
+		return nil, fmt.Errorf("cloudwavetest: unknown statement %d", id)
+	}
+	args := make([]interface{}, n)
+	for i := range args {
+		if !batch {
+			r.uint32() // parameter index
+		}
+		v, err := readValue(r, c.srv.loadLOB)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	e, err := c.srv.match(stmt.query, args)
+	if err != nil {
+		return nil, err
+	}
+	if !batch {
+		return c.respond(id, e)
+	}
+	if e.delay > 0 {
+		time.Sleep(e.delay)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	b := binary.BigEndian.AppendUint64(ok(), c.time)
+	b = binary.BigEndian.AppendUint64(b, c.sequence)
+	return binary.BigEndian.AppendUint64(b, uint64(e.affected)), nil
+}
+
+func (c *session) next(r *reader) ([]byte, error) {
+	r.uint32() // statement
+	id := int32(r.uint32())
+	count := int(r.uint32())
+	cur := c.cursors[id]
+	if r.err != nil {
+		return nil, r.err
+	}
+	if cur == nil {
+		return nil, fmt.Errorf("cloudwavetest: unknown cursor %d", id)
+	}
+	if cur.next >= len(cur.rows.rows) {
+		delete(c.cursors, id)
+		return ok(0), nil
+	}
+	if count < 1 {
+		count = 1
+	}
+	if left := len(cur.rows.rows) - cur.next; count > left {
+		count = left
+	}
+	b := binary.BigEndian.AppendUint32(ok(1), uint32(count))
+	for ; count > 0; count-- {
+		row := cur.rows.rows[cur.next]
+		cur.next++
+		if len(row) != len(cur.rows.columns) {
+			return nil, fmt.Errorf("cloudwavetest: row %d has %d values for %d columns", cur.next, len(row), len(cur.rows.columns))
+		}
+		for i, v := range row {
+			var err error
+			if b, err = appendValue(b, cur.rows.columns[i].Type, v, c.srv.storeLOB); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// countPlaceholders counts the ? placeholders of query outside of quotes
+// and comments.
+func countPlaceholders(query string) int {
+	n := 0
+	for i := 0; i < len(query); i++ {
+		switch ch := query[i]; {
+		case ch == '?':
+			n++
+		case ch == '\'' || ch == '"' || ch == '`':
+			for i++; i < len(query) && query[i] != ch; i++ {
+			}
+		case strings.HasPrefix(query[i:], "--"):
+			for ; i < len(query) && query[i] != '\n'; i++ {
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+		}
+	}
+	return n
+}
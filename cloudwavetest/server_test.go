@@ -0,0 +1,105 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwavetest
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+)
+
+func TestExpectations(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	db, err := sql.Open("cloudwave", srv.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	srv.Expect("UPDATE t  SET a = 1").WillReturnResult(2)
+	srv.Expect("UPDATE t SET a = ?").WithArgs(AnyArg()).WillReturnResult(1)
+	srv.Expect("SELECT 1")
+	srv.Expect("SELECT sleep()").WillDelayFor(200 * time.Millisecond)
+
+	if _, err = db.Exec("UPDATE t\n\tSET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("UPDATE t SET a = ?", 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("UPDATE t SET a = 1"); err == nil {
+		t.Error("expectation met twice")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = db.ExecContext(ctx, "SELECT sleep()"); err == nil {
+		t.Error("delayed statement didn't time out")
+	}
+
+	err = srv.ExpectationsWereMet()
+	if err == nil || !strings.Contains(err.Error(), `"SELECT 1"`) || strings.Contains(err.Error(), "sleep") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	db, err := sql.Open("cloudwave", srv.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var got string
+	srv.Handle(cloudwave.DATABASE_META_DATA_GET_TABLES, func(payload []byte) ([]byte, error) {
+		r := &reader{b: payload}
+		got = r.string()
+		return []byte("tables"), r.err
+	})
+	res, err := db.Exec("", cloudwave.AdminCommand(cloudwave.DATABASE_META_DATA_GET_TABLES), "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := res.RowsAffected()
+	if data := cloudwave.PullData(int(h)); string(data) != "\x01tables" {
+		t.Errorf("got admin result %q", data)
+	}
+	if got != "s" {
+		t.Errorf("handler got argument %q", got)
+	}
+}
+
+func TestCountPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		n     int
+	}{
+		{"SELECT ?", 1},
+		{"INSERT INTO t VALUES (?, '?', \"?\", ?)", 2},
+		{"SELECT 'it''s ?', ? -- ?\n, ? /* ? */", 2},
+	}
+	for _, tt := range tests {
+		if n := countPlaceholders(tt.query); n != tt.n {
+			t.Errorf("%q: got %d placeholders, want %d", tt.query, n, tt.n)
+		}
+	}
+}
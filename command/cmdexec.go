@@ -2,7 +2,6 @@ package command
 
 import (
 	"database/sql"
-	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,7 +16,7 @@ type DbWorker struct {
 	Db  *sql.DB
 }
 
-func convert(cmd commandType) int {
+func convert(cmd commandType) cloudwave.AdminCommand {
 	n := -10000
 	switch cmd {
 	case GetResultTaskStatistics:
@@ -75,16 +74,33 @@ func convert(cmd commandType) int {
 	case GetRuntimeReport:
 		n = cloudwave.DATABASE_GET_RUNTIME_REPORT
 	}
-	return n
+	return cloudwave.AdminCommand(n)
 }
 
-func readString(data []byte) (string, int, error) {
-	bytes, _, n, err := cloudwave.ReadLengthEncodedString(data[0:])
-	if err != nil {
-		return "", n, err
+// newResultReader returns a reader of buf, the result of an admin command,
+// positioned after its status byte.
+func newResultReader(buf []byte, context string) *cloudwave.PacketReader {
+	r := cloudwave.NewPacketReader(buf, context)
+	r.Skip(1)
+	return r
+}
+
+// readStringList reads a string count followed by the strings. Negative
+// counts denote no result.
+func readStringList(r *cloudwave.PacketReader) ([]string, error) {
+	count := int(r.Int32())
+	if count < 0 {
+		return nil, r.Err()
+	}
+	var ss []string
+	for i := 0; i < count; i++ {
+		s := r.String()
+		if r.Err() != nil {
+			break
+		}
+		ss = append(ss, s)
 	}
-	str := string(bytes)
-	return str, n, nil
+	return ss, r.Err()
 }
 
 func (db *DbWorker) GetInfoNoparamCommon(cmd commandType) (interface{}, error) {
@@ -92,82 +108,49 @@ func (db *DbWorker) GetInfoNoparamCommon(cmd commandType) (interface{}, error) {
 	cd := convert(cmd)
 	if cmd == GetOnlineSessions || cmd == GetCloudwaveVersion || cmd == GetDfsStatus ||
 		cmd == GetConfigOptions || cmd == GetSystemOverview || cmd == GetRuntimeReport {
-		var s string
-		var n int
-		resExec, err := db.Db.Exec("CloudWave", cd)
+		resExec, err := db.Db.Exec("", cd)
 		if err != nil {
 			return nil, err
 		}
 		i, _ := resExec.RowsAffected()
 		buf := cloudwave.PullData(int(i))
-		if buf == nil && len(buf) < 5 {
+		if len(buf) < 5 {
 			return nil, errors.New("no result")
 		}
+		r := newResultReader(buf, "command result")
 		switch cmd {
 		case GetOnlineSessions:
 			var s1, s2, s3 string
-			count := int(binary.BigEndian.Uint32(buf[1:]))
+			count := int(r.Int32())
 			if count < 0 {
 				return nil, nil
 			}
-			pos := 5
-			ss := make([][]string, count)
-			index := 0
-			for index < count {
-				s1, n, err = readString(buf[pos:])
-				if err != nil {
-					break
-				}
-				pos += n
-				s2, n, err = readString(buf[pos:])
-				if err != nil {
+			var ss [][]string
+			for index := 0; index < count; index++ {
+				s1 = r.String()
+				s2 = r.String()
+				if r.Err() != nil {
 					break
 				}
-				pos += n
-				//				s3, n, err = readString(buf[pos:])
-				//				if err != nil {
-				//					break
-				//				}
-				//				pos += n
-				ss[index] = append(ss[index], s1, s2, s3)
-				index++
+				ss = append(ss, []string{s1, s2, s3})
 			}
-			return ss[0:index], err
+			return ss, r.Err()
 		case GetCloudwaveVersion:
-			var str string
-			pos := 1
-			str, n, err = readString(buf[pos:])
-			if err != nil {
-				break
-			}
-			pos += n
-			s, n, err = readString(buf[pos:])
-			if err != nil {
-				break
+			str := r.String()
+			s := r.String()
+			if r.Err() != nil {
+				return nil, r.Err()
 			}
-			str += (", " + s)
-			return str, nil
+			return str + ", " + s, nil
 		case GetDfsStatus, GetConfigOptions, GetSystemOverview:
-			count := int(binary.BigEndian.Uint32(buf[1:]))
-			if count < 0 {
+			ss, err := readStringList(r)
+			if ss == nil && err == nil {
 				return nil, nil
 			}
-			pos := 5
-			ss := make([]string, count)
-			index := 0
-			for index < count {
-				s, n, err = readString(buf[pos:])
-				if err != nil {
-					break
-				}
-				pos += n
-				ss[index] = s
-				index++
-			}
-			return ss[0:index], err
+			return ss, err
 		case GetRuntimeReport:
-			str, _, err := readString(buf[1:])
-			return str, err
+			str := r.String()
+			return str, r.Err()
 		}
 		return buf, nil
 	}
@@ -175,7 +158,7 @@ func (db *DbWorker) GetInfoNoparamCommon(cmd commandType) (interface{}, error) {
 		cmd == GetServerList || cmd == GetServerStatus || cmd == GetSystemUtilization ||
 		cmd == GetMemorySize || cmd == GetNetworkStatus || cmd == GetUserNameList {
 		var index int
-		rows, err := db.Db.Query("CloudWave", cd)
+		rows, err := db.Db.Query("", cd)
 		defer rows.Close()
 		if err != nil {
 			return nil, err
@@ -338,7 +321,7 @@ func (db *DbWorker) GetNameList(cmd commandType, catalog string, schema []byte,
 	case GetViewNameList:
 		js, _ = json.Marshal([]string{"VIEW"})
 	}
-	rows, err := db.Db.Query("CloudWave", cd, schema, table, json.RawMessage(js))
+	rows, err := db.Db.Query("", cd, schema, table, json.RawMessage(js))
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -363,41 +346,36 @@ func (db *DbWorker) GetNameList(cmd commandType, catalog string, schema []byte,
 
 func (db *DbWorker) GetResultTaskStatistics(requestID int64) ([][]string, error) {
 	cd := convert(GetResultTaskStatistics)
-	resExec, err := db.Db.Exec("CloudWave", cd)
+	resExec, err := db.Db.Exec("", cd)
 	if err != nil {
 		return nil, err
 	}
 	i, _ := resExec.RowsAffected()
 	buf := cloudwave.PullData(int(i))
-	if buf == nil && len(buf) < 5 {
+	if len(buf) < 5 {
 		return nil, errors.New("no result")
 	}
-	count := int(binary.BigEndian.Uint32(buf[1:]))
-	pos := 5
-	sss := make([][]string, count)
-	var s string
-	var n int
-	index := 0
-	for index < count {
-		subcount := int(binary.BigEndian.Uint32(buf[pos:]))
-		pos += 4
+	r := newResultReader(buf, "task statistics")
+	count := r.Count(4)
+	var sss [][]string
+	for index := 0; index < count && r.Err() == nil; index++ {
+		subcount := r.Count(4)
+		var ss []string
 		for i := 0; i < subcount; i++ {
-			s, n, err = readString(buf[pos:])
-			if err != nil {
+			s := r.String()
+			if r.Err() != nil {
 				break
 			}
-			pos += n
-			sss[index] = append(sss[index], s)
-
+			ss = append(ss, s)
 		}
-		index++
+		sss = append(sss, ss)
 	}
-	return sss[0:index], err
+	return sss, r.Err()
 }
 
 func (db *DbWorker) GetTableColumns(schema []byte, table []byte, requestID int64) ([][]string, error) {
 	cd := convert(GetTableColumns)
-	rows, err := db.Db.Query("CloudWave", cd, schema, table, []byte(nil))
+	rows, err := db.Db.Query("", cd, schema, table, []byte(nil))
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -425,7 +403,7 @@ func (db *DbWorker) GetTableColumns(schema []byte, table []byte, requestID int64
 
 func (db *DbWorker) GetTableDefinition(schema []byte, table []byte, requestID int64) ([][]string, error) {
 	cd := convert(GetTableDefinition)
-	rows, err := db.Db.Query("CloudWave", cd, schema, table, []byte(nil))
+	rows, err := db.Db.Query("", cd, schema, table, []byte(nil))
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -453,7 +431,7 @@ func (db *DbWorker) GetTableDefinition(schema []byte, table []byte, requestID in
 
 func (db *DbWorker) GetTabletData(schema string, table string, tabletID int64, requestID int64) ([][]string, error) {
 	cd := convert(GetTabletData)
-	rows, err := db.Db.Query("CloudWave", cd, schema, table, tabletID)
+	rows, err := db.Db.Query("", cd, schema, table, tabletID)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -478,7 +456,7 @@ func (db *DbWorker) GetTabletData(schema string, table string, tabletID int64, r
 
 func (db *DbWorker) GetTableDistribution(schema string, table string, requestID int64) ([][]string, error) {
 	cd := convert(GetTableDistribution)
-	rows, err := db.Db.Query("CloudWave", cd, schema, table, requestID)
+	rows, err := db.Db.Query("", cd, schema, table, requestID)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -503,7 +481,7 @@ func (db *DbWorker) GetTableDistribution(schema string, table string, requestID
 
 func (db *DbWorker) GetTableDistributionStatistics(schema string, table string) ([][]string, error) {
 	cd := convert(GetTableDistributionStatistics)
-	rows, err := db.Db.Query("CloudWave", cd, schema, table)
+	rows, err := db.Db.Query("", cd, schema, table)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -528,7 +506,7 @@ func (db *DbWorker) GetTableDistributionStatistics(schema string, table string)
 
 func (db *DbWorker) GetSQLStatistics(timeRange int64) (string, error) {
 	cd := convert(GetSQLStatistics)
-	resExec, err := db.Db.Exec("CloudWave", cd, timeRange)
+	resExec, err := db.Db.Exec("", cd, timeRange)
 	if err != nil {
 		return "", err
 	}
@@ -537,37 +515,23 @@ func (db *DbWorker) GetSQLStatistics(timeRange int64) (string, error) {
 	if len(buf) < 5 {
 		return "", errors.New("result is null")
 	}
-	str, _, err := readString(buf[1:])
-	return str, nil
+	r := newResultReader(buf, "command result")
+	str := r.String()
+	return str, r.Err()
 }
 
 func (db *DbWorker) getSQLHistorys(tp int, count int) ([]string, error) {
 	cd := convert(GetHistorySQLs)
-	resExec, err := db.Db.Exec("CloudWave", cd, float64(tp), float64(count))
+	resExec, err := db.Db.Exec("", cd, float64(tp), float64(count))
 	if err != nil {
 		return nil, err
 	}
 	i, _ := resExec.RowsAffected()
 	buf := cloudwave.PullData(int(i))
-	if buf == nil && len(buf) < 5 {
+	if len(buf) < 5 {
 		return nil, errors.New("no result")
 	}
-	count2 := int(binary.BigEndian.Uint32(buf[1:]))
-	pos := 5
-	ss := make([]string, count2)
-	var s string
-	var n int
-	index := 0
-	for index < count2 {
-		s, n, err = readString(buf[pos:])
-		if err != nil {
-			break
-		}
-		pos += n
-		ss[index] = s
-		index++
-	}
-	return ss[0:index], err
+	return readStringList(newResultReader(buf, "SQL history"))
 }
 
 func (db *DbWorker) GetHistorySQLs() ([][]string, error) {
@@ -606,7 +570,7 @@ func (db *DbWorker) GetHistorySQLs() ([][]string, error) {
 
 func (db *DbWorker) GetUserPrivileges(user string) (string, error) {
 	cd := convert(GetUserPrivileges)
-	resExec, err := db.Db.Exec("CloudWave", cd, user)
+	resExec, err := db.Db.Exec("", cd, user)
 	if err != nil {
 		return "", err
 	}
@@ -615,13 +579,14 @@ func (db *DbWorker) GetUserPrivileges(user string) (string, error) {
 	if len(buf) < 5 {
 		return "", errors.New("result is null")
 	}
-	str, _, err := readString(buf[1:])
-	return str, nil
+	r := newResultReader(buf, "command result")
+	str := r.String()
+	return str, r.Err()
 }
 
 func (db *DbWorker) GetServerLogger(server []byte, tail bool, count int) (string, error) {
 	cd := convert(GetServerLogger)
-	resExec, err := db.Db.Exec("CloudWave", cd, server, tail, float64(count))
+	resExec, err := db.Db.Exec("", cd, server, tail, float64(count))
 	if err != nil {
 		return "", err
 	}
@@ -630,13 +595,14 @@ func (db *DbWorker) GetServerLogger(server []byte, tail bool, count int) (string
 	if len(buf) < 5 {
 		return "", errors.New("result is null")
 	}
-	str, _, err := readString(buf[1:])
-	return str, err
+	r := newResultReader(buf, "command result")
+	str := r.String()
+	return str, r.Err()
 }
 
 func (db *DbWorker) GetProcessJstack(trim bool, server []byte) (string, error) {
 	cd := convert(GetProcessJstack)
-	resExec, err := db.Db.Exec("CloudWave", cd, trim, server)
+	resExec, err := db.Db.Exec("", cd, trim, server)
 	if err != nil {
 		return "", err
 	}
@@ -645,13 +611,14 @@ func (db *DbWorker) GetProcessJstack(trim bool, server []byte) (string, error) {
 	if len(buf) < 5 {
 		return "", errors.New("result is null")
 	}
-	str, _, err := readString(buf[1:])
-	return str, err
+	r := newResultReader(buf, "command result")
+	str := r.String()
+	return str, r.Err()
 }
 
 func (db *DbWorker) GetHealthDiagnostic(simpleCheck bool) ([]string, error) {
 	cd := convert(GetHealthDiagnostic)
-	resExec, err := db.Db.Exec("CloudWave", cd, simpleCheck)
+	resExec, err := db.Db.Exec("", cd, simpleCheck)
 	if err != nil {
 		return nil, err
 	}
@@ -660,26 +627,18 @@ func (db *DbWorker) GetHealthDiagnostic(simpleCheck bool) ([]string, error) {
 	if len(buf) < 5 {
 		return nil, errors.New("result is null")
 	}
-	ss := make([]string, MaxResultReturnRecord)
-	index := 0
-	pos := 1
-	for i := 0; i < 7; i++ {
-		if buf[pos] == 0 {
-			count := int(binary.BigEndian.Uint32(buf[pos:]))
-			pos += 4
-			for j := 0; j < count; j++ {
-				checkType := int(binary.BigEndian.Uint32(buf[pos:]))
-				pos += 4
-				checkTarget := int(binary.BigEndian.Uint32(buf[pos:]))
-				pos += 4
-				healthServer, n, _ := readString(buf[pos:])
-				pos += n
-				healthWeight := float64(binary.BigEndian.Uint64(buf[pos:]))
-				pos += 8
-				healthScore := float64(binary.BigEndian.Uint64(buf[pos:]))
-				pos += 8
-				size := int(binary.BigEndian.Uint32(buf[pos:]))
-				pos += 4
+	r := newResultReader(buf, "health diagnostic")
+	var ss []string
+	for i := 0; i < 7 && r.Err() == nil; i++ {
+		if r.Uint8() == 0 {
+			count := r.Count(4)
+			for j := 0; j < count && r.Err() == nil; j++ {
+				checkType := r.Int32()
+				checkTarget := r.Int32()
+				healthServer := r.String()
+				healthWeight := r.Float64()
+				healthScore := r.Float64()
+				size := r.Int32()
 				fmt.Print(checkType)
 				fmt.Print(checkTarget)
 				fmt.Print(healthServer)
@@ -689,12 +648,12 @@ func (db *DbWorker) GetHealthDiagnostic(simpleCheck bool) ([]string, error) {
 			}
 		}
 	}
-	return ss[0:index], err
+	return ss, r.Err()
 }
 
 func (db *DbWorker) DoRestartServer(target string) (bool, error) {
 	cd := convert(DoRestartServer)
-	resExec, err := db.Db.Exec("CloudWave", cd, target)
+	resExec, err := db.Db.Exec("", cd, target)
 	if err != nil {
 		return false, err
 	}
@@ -707,7 +666,7 @@ func (db *DbWorker) GetSchemaNameList(catalog string) ([]string, error) {
 	cd := convert(GetSchemaNameList)
 	var b []byte
 	b = nil
-	rows, err := db.Db.Query("CloudWave", cd, b)
+	rows, err := db.Db.Query("", cd, b)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -13,11 +13,9 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"encoding/binary"
-	"encoding/json"
 	"errors"
 	"io"
 	"net"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -47,14 +45,31 @@ type cwConn struct {
 	canceled atomicError // set non-nil if conn is canceled
 	closed   atomicBool  // set when conn is closed, before closech is closed
 
+	idleMu sync.Mutex // held while idle is set or the connection is kept alive
+	idle   bool       // set while the connection is in the pool
+
 	txBatchFlag bool
+	inTx        bool // set between BeginTx and the end of the transaction
+	generation  int  // times the connection was opened again
 
 	//add vars for cloudwave
 	sessionTime     uint64
 	sessionSequence uint64
 	sessionToken    uint64
 
-	execType byte
+	execType  byte
+	version   ServerVersion // version of the server, read on connecting
+	fetchSize int           // rows requested per RESULT_SET_QUERY_NEXT
+
+	lastCmd  int       // command of the last request
+	lastSent time.Time // time of the last request
+
+	connID uint64    // connection number, unique within the process
+	trace  TraceSink // set when frames are traced
+
+	stats       metrics     // statistics of the connection
+	pool        *metrics    // statistics of the connector
+	metricsSink MetricsSink // set when metrics are forwarded
 }
 
 const DATAAREALEN = 32
@@ -176,22 +191,27 @@ func (mc *cwConn) markBadConn(err error) error {
 	if err != errBadConnNoWrite {
 		return err
 	}
+	mc.count(counterBadConns, 1)
 	return driver.ErrBadConn
 }
 
 func (mc *cwConn) Begin() (driver.Tx, error) {
-	return mc.begin(false)
+	return mc.begin(context.Background(), false)
 }
 
-func (mc *cwConn) begin(readOnly bool) (driver.Tx, error) {
+func (mc *cwConn) begin(ctx context.Context, readOnly bool) (driver.Tx, error) {
 	if mc.closed.IsSet() {
-		errLog.Print(ErrInvalidConn)
+		mc.logError(ErrInvalidConn)
 		return nil, driver.ErrBadConn
 	}
-	return &cwTx{mc}, nil
+	return &cwTx{mc: mc, ctx: ctx}, nil
 }
 
 func (mc *cwConn) Close() (err error) {
+	// the watcher may be pinging the idle connection
+	mc.idleMu.Lock()
+	defer mc.idleMu.Unlock()
+
 	// Makes Close idempotent
 	if !mc.closed.IsSet() {
 		err = mc.writeCommandPacket(B_REQ_CLOSE_CONNECTION)
@@ -217,7 +237,7 @@ func (mc *cwConn) cleanup() {
 		return
 	}
 	if err := mc.netConn.Close(); err != nil {
-		errLog.Print(err)
+		mc.logError(err)
 	}
 }
 
@@ -231,6 +251,14 @@ func (mc *cwConn) error() error {
 	return nil
 }
 
+// lobChunkSize returns the chunk size for streaming LOB values.
+func (mc *cwConn) lobChunkSize() int {
+	if mc == nil || mc.cfg == nil || mc.cfg.LobChunkSize <= 0 {
+		return INT_CHUNK_SIZE
+	}
+	return mc.cfg.LobChunkSize
+}
+
 func (mc *cwConn) createStatement() (*cwStmt, error) {
 	stmt := &cwStmt{
 		mc:       mc,
@@ -281,11 +309,13 @@ func (mc *cwConn) setAutoCommit(autoCommit bool) error {
 }
 
 func (mc *cwConn) Prepare(query string) (driver.Stmt, error) {
-	mc.execType = whichExecute(query)
 	if mc.closed.IsSet() {
-		errLog.Print(ErrInvalidConn)
+		mc.logError(ErrInvalidConn)
 		return nil, driver.ErrBadConn
 	}
+	if err := mc.checkStatement(query, nil); err != nil {
+		return nil, err
+	}
 	// Send command
 
 	mc.sequence = 0
@@ -294,7 +324,7 @@ func (mc *cwConn) Prepare(query string) (driver.Stmt, error) {
 	data, err := mc.buf.takeBuffer(pktLen)
 	if err != nil {
 		// cannot take the buffer. Something must be wrong with the connection
-		errLog.Print(err)
+		mc.logError(err)
 		return nil, err
 	}
 	pos := 25
@@ -311,8 +341,11 @@ func (mc *cwConn) Prepare(query string) (driver.Stmt, error) {
 	}
 
 	stmt := &cwStmt{
-		mc:       mc,
-		stmtType: CONNECTION_PREPARED_STATEMENT,
+		mc:         mc,
+		sql:        query,
+		generation: mc.generation,
+		stmtType:   CONNECTION_PREPARED_STATEMENT,
+		execType:   classifyStatement(query).execType(),
 	}
 
 	// Read Result
@@ -332,204 +365,66 @@ func (mc *cwConn) Prepare(query string) (driver.Stmt, error) {
 	return stmt, err
 }
 
+// interpolateParams replaces the placeholders of query with args encoded as
+// SQL literals. It returns driver.ErrSkip if the query is to be prepared
+// instead.
 func (mc *cwConn) interpolateParams(query string, args []driver.Value) (string, error) {
-	// Number of ? should be same to len(args)
-	if strings.Count(query, "?") != len(args) {
+	pos := placeholders(query)
+	if len(pos) != len(args) {
 		return "", driver.ErrSkip
 	}
 
 	buf, err := mc.buf.takeCompleteBuffer()
 	if err != nil {
 		// can not take the buffer. Something must be wrong with the connection
-		errLog.Print(err)
+		mc.logError(err)
 		return "", ErrInvalidConn
 	}
 	buf = buf[:0]
-	argPos := 0
-
-	for i := 0; i < len(query); i++ {
-		q := strings.IndexByte(query[i:], '?')
-		if q == -1 {
-			buf = append(buf, query[i:]...)
-			break
+	last := 0
+	for i, arg := range args {
+		buf = append(buf, query[last:pos[i]]...)
+		last = pos[i] + 1
+		if buf, err = appendLiteral(buf, arg, mc.cfg.Loc); err != nil {
+			return "", err
 		}
-		buf = append(buf, query[i:i+q]...)
-		i += q
-
-		arg := args[argPos]
-		argPos++
-
-		if arg == nil {
-			buf = append(buf, "NULL"...)
-			continue
-		}
-
-		switch v := arg.(type) {
-		case int64:
-			buf = strconv.AppendInt(buf, v, 10)
-		case uint64:
-			// Handle uint64 explicitly because our custom ConvertValue emits unsigned values
-			buf = strconv.AppendUint(buf, v, 10)
-		case float64:
-			buf = strconv.AppendFloat(buf, v, 'g', -1, 64)
-		case bool:
-			if v {
-				buf = append(buf, '1')
-			} else {
-				buf = append(buf, '0')
-			}
-		case time.Time:
-			if v.IsZero() {
-				buf = append(buf, "'0000-00-00'"...)
-			} else {
-				buf = append(buf, '\'')
-				buf, err = appendDateTime(buf, v.In(mc.cfg.Loc))
-				if err != nil {
-					return "", err
-				}
-				buf = append(buf, '\'')
-			}
-		case json.RawMessage:
-			buf = append(buf, '\'')
-			if mc.status&statusNoBackslashEscapes == 0 {
-				buf = escapeBytesBackslash(buf, v)
-			} else {
-				buf = escapeBytesQuotes(buf, v)
-			}
-			buf = append(buf, '\'')
-		case []byte:
-			if v == nil {
-				buf = append(buf, "NULL"...)
-			} else {
-				buf = append(buf, "_binary'"...)
-				if mc.status&statusNoBackslashEscapes == 0 {
-					buf = escapeBytesBackslash(buf, v)
-				} else {
-					buf = escapeBytesQuotes(buf, v)
-				}
-				buf = append(buf, '\'')
-			}
-		case string:
-			buf = append(buf, '\'')
-			if mc.status&statusNoBackslashEscapes == 0 {
-				buf = escapeStringBackslash(buf, v)
-			} else {
-				buf = escapeStringQuotes(buf, v)
-			}
-			buf = append(buf, '\'')
-		default:
-			return "", driver.ErrSkip
-		}
-
 		if len(buf)+4 > mc.maxAllowedPacket {
 			return "", driver.ErrSkip
 		}
 	}
-	if argPos != len(args) {
-		return "", driver.ErrSkip
-	}
+	buf = append(buf, query[last:]...)
 	return string(buf), nil
 }
 
 func (mc *cwConn) Exec(query string, args []driver.Value) (driver.Result, error) {
-	mc.execType = whichExecute(query)
-	if mc.execType == CLOUDWAVE_SELFUSEDRIVE {
-		var buf []byte
-		var err error
-		cmd := -10000
-		if len(args) > 0 {
-			switch v := args[0].(type) {
-			case int64:
-				cmd = int(v)
-			}
+	if err := mc.checkStatement(query, args); err != nil {
+		return nil, err
+	}
+	if cmd, ok := adminCommand(args); ok {
+		if mc.closed.IsSet() {
+			mc.logError(ErrInvalidConn)
+			return nil, driver.ErrBadConn
 		}
-		if cmd > -10000 {
-			if len(args) == 1 {
-				err = mc.writeCommandPacket(cmd)
-			} else {
-				var data []byte
-				data, err = mc.buf.takeCompleteBuffer()
-				if err != nil {
-					return nil, ErrBusyBuffer
-				}
-				pos := 25
-				for i := 1; i < len(args); i++ {
-					switch v := args[i].(type) {
-					case bool:
-						if v {
-							data[pos] = 1
-						} else {
-							data[pos] = 0
-						}
-						pos++
-					case float64:
-						binary.BigEndian.PutUint32(data[pos:pos+4], uint32(int32(v)))
-						pos += 4
-					case int64:
-						binary.BigEndian.PutUint64(data[pos:pos+8], uint64(v))
-						pos += 8
-					case []byte:
-						pos++
-						if v == nil {
-							data[pos-1] = 1
-							continue
-						} else {
-							data[pos-1] = 0
-						}
-						n := copy(data[pos+4:], v)
-						binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
-						pos += (4 + n)
-					case string:
-						n := copy(data[pos+4:], v)
-						binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
-						pos += (4 + n)
-					case json.RawMessage:
-						js := v
-						var ss []string
-						err = json.Unmarshal(js, &ss)
-						if len(ss) <= 0 {
-							data[pos] = 1
-							pos++
-						} else {
-							data[pos] = 0
-							pos++
-							for i := 0; i < len(ss); i++ {
-								data[pos] = 0
-								pos++
-								n := copy(data[pos+4:], ss[i])
-								binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
-								pos += (n + 4)
-							}
-						}
-					}
-				}
-				err = mc.setCommandPacket(cmd, pos, data[0:25])
-				if err == nil {
-					err = mc.writePacket(data[0:pos])
-				}
-			}
-			if err == nil {
-				buf, err = mc.readResultOK()
-			}
-			if err == nil {
-				i := int64(pushData(buf))
-				return &cwResult{
-					affectedRows: i,
-					insertId:     0,
-				}, nil
-			}
-		} else {
-			err = errors.New("command code is error")
+		if err := mc.writeAdminCommand(cmd, args[1:]); err != nil {
+			return nil, mc.markBadConn(err)
 		}
-		return nil, err
+		buf, err := mc.readResultOK()
+		if err != nil {
+			return nil, err
+		}
+		return &cwResult{
+			affectedRows: int64(pushData(buf)),
+			insertId:     0,
+		}, nil
 	}
+	mc.execType = classifyStatement(query).execType()
 	if mc.closed.IsSet() {
-		errLog.Print(ErrInvalidConn)
+		mc.logError(ErrInvalidConn)
 		return nil, driver.ErrBadConn
 	}
 	if len(args) != 0 {
 		if !mc.cfg.InterpolateParams {
-			//			return nil, driver.ErrSkip
+			return nil, driver.ErrSkip
 		}
 		// try to interpolate the parameters to save extra roundtrips for preparing and closing a statement
 		prepared, err := mc.interpolateParams(query, args)
@@ -595,109 +490,35 @@ type ssss struct {
 
 func (mc *cwConn) query(query string, args []driver.Value) (*textRows, error) {
 	var err error
-	var cmd int
 	var stmt *cwStmt
 
 	if mc.closed.IsSet() {
-		errLog.Print(ErrInvalidConn)
+		mc.logError(ErrInvalidConn)
 		return nil, driver.ErrBadConn
 	}
+	if err := mc.checkStatement(query, args); err != nil {
+		return nil, err
+	}
 
-	mc.execType = whichExecute(query)
-	if mc.execType == CLOUDWAVE_SELFUSEDRIVE {
-		cmd = -10000
-		if len(args) > 0 {
-			switch v := args[0].(type) {
-			case int64:
-				cmd = int(v)
-			}
-		}
-		if cmd <= -10000 {
-			return nil, errors.New("command code is error")
+	cmd, admin := adminCommand(args)
+	if !admin && len(args) != 0 {
+		if !mc.cfg.InterpolateParams {
+			return nil, driver.ErrSkip
 		}
-	} else {
-		if len(args) != 0 {
-			if !mc.cfg.InterpolateParams {
-				//				return nil, driver.ErrSkip
-			}
-			// try client-side prepare to reduce roundtrip
-			prepared, err := mc.interpolateParams(query, args)
-			if err != nil {
-				return nil, err
-			}
-			query = prepared
+		// try client-side prepare to reduce roundtrip
+		prepared, err := mc.interpolateParams(query, args)
+		if err != nil {
+			return nil, err
 		}
+		query = prepared
 	}
 	stmt, err = mc.createStatement()
 	if err != nil {
 		return nil, err
 	}
 
-	if mc.execType == CLOUDWAVE_SELFUSEDRIVE {
-		if len(args) == 1 {
-			err = stmt.mc.writeCommandPacket(cmd)
-		} else {
-			var data []byte
-			data, err = mc.buf.takeCompleteBuffer()
-			if err != nil {
-				return nil, ErrBusyBuffer
-			}
-			pos := 25
-			for i := 1; i < len(args); i++ {
-				switch v := args[i].(type) {
-				case bool:
-					if v {
-						data[pos] = 1
-					} else {
-						data[pos] = 0
-					}
-					pos++
-				case float64:
-					binary.BigEndian.PutUint32(data[pos:pos+4], uint32(int32(v)))
-					pos += 4
-				case int64:
-					binary.BigEndian.PutUint64(data[pos:pos+8], uint64(v))
-					pos += 8
-				case []byte:
-					pos++
-					if v == nil {
-						data[pos-1] = 1
-						continue
-					} else {
-						data[pos-1] = 0
-					}
-					n := copy(data[pos+4:], v)
-					binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
-					pos += (4 + n)
-				case string:
-					n := copy(data[pos+4:], v)
-					binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
-					pos += (4 + n)
-				case json.RawMessage:
-					js := v
-					var ss []string
-					err = json.Unmarshal(js, &ss)
-					if len(ss) <= 0 {
-						data[pos] = 1
-						pos++
-					} else {
-						data[pos] = 0
-						pos++
-						for i := 0; i < len(ss); i++ {
-							data[pos] = 0
-							pos++
-							n := copy(data[pos+4:], ss[i])
-							binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
-							pos += (n + 4)
-						}
-					}
-				}
-			}
-			err = mc.setCommandPacket(cmd, pos, data[0:25])
-			if err == nil {
-				err = mc.writePacket(data[0:pos])
-			}
-		}
+	if admin {
+		err = mc.writeAdminCommand(cmd, args[1:])
 	} else {
 		// Send command
 		err = stmt.writeCommandPacketStr(CLOUDWAVE_EXECUTE_QUERY, query)
@@ -722,14 +543,12 @@ func (mc *cwConn) query(query string, args []driver.Value) (*textRows, error) {
 			return nil, err
 		}
 	}
-	if mc.execType == CLOUDWAVE_SELFUSEDRIVE {
-		//		rows.resultCount = rows.ResultSetRecordCount()
-	}
 	return rows, nil
 }
 
 // finish is called when the query has canceled.
 func (mc *cwConn) cancel(err error) {
+	mc.count(counterCancellations, 1)
 	mc.canceled.Set(err)
 	mc.cleanup()
 }
@@ -749,7 +568,7 @@ func (mc *cwConn) finish() {
 // Ping implements driver.Pinger interface
 func (mc *cwConn) Ping(ctx context.Context) (err error) {
 	if mc.closed.IsSet() {
-		errLog.Print(ErrInvalidConn)
+		mc.logError(ErrInvalidConn)
 		return driver.ErrBadConn
 	}
 
@@ -758,16 +577,15 @@ func (mc *cwConn) Ping(ctx context.Context) (err error) {
 	}
 	defer mc.finish()
 
-	if err = mc.writeCommandPacket(GET_SERVER_VERSION); err != nil {
-		return mc.markBadConn(err)
-	}
-
-	_, err = mc.readResultOK()
-	return err
+	return mc.ping()
 }
 
 // BeginTx implements driver.ConnBeginTx interface
 func (mc *cwConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return interceptorChain(mc.cfg.Interceptors).beginTx(ctx, opts, mc.beginTx)
+}
+
+func (mc *cwConn) beginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	if mc.closed.IsSet() {
 		return nil, driver.ErrBadConn
 	}
@@ -776,6 +594,7 @@ func (mc *cwConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx
 		return nil, err
 	}
 	mc.txBatchFlag = true
+	mc.inTx = true
 	mc.setAutoCommit(false)
 
 	defer mc.finish()
@@ -798,10 +617,31 @@ func (mc *cwConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx
 		}
 	}
 
-	return mc.begin(opts.ReadOnly)
+	return mc.begin(ctx, opts.ReadOnly)
 }
 
 func (mc *cwConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if mc.skipsExec(args) {
+		return nil, driver.ErrSkip
+	}
+	chain := interceptorChain(mc.cfg.Interceptors)
+	return chain.query(ctx, query, args, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		var rows *textRows
+		err := mc.retry(ctx, query, func() (err error) {
+			rows, err = mc.queryContext(ctx, query, args)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		rows.intercept(ctx, query, chain)
+		return rows, nil
+	})
+}
+
+func (mc *cwConn) queryContext(ctx context.Context, query string, args []driver.NamedValue) (*textRows, error) {
+	mc.logStatement(ctx, "query", query, args)
+	defer mc.observeQuery(time.Now())
 	dargs, err := namedValueToValue(args)
 	if err != nil {
 		return nil, err
@@ -821,6 +661,21 @@ func (mc *cwConn) QueryContext(ctx context.Context, query string, args []driver.
 }
 
 func (mc *cwConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if mc.skipsExec(args) {
+		return nil, driver.ErrSkip
+	}
+	return interceptorChain(mc.cfg.Interceptors).exec(ctx, query, args, func(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
+		err = mc.retry(ctx, query, func() error {
+			res, err = mc.execContext(ctx, query, args)
+			return err
+		})
+		return res, err
+	})
+}
+
+func (mc *cwConn) execContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	mc.logStatement(ctx, "exec", query, args)
+	defer mc.observeQuery(time.Now())
 	dargs, err := namedValueToValue(args)
 	if err != nil {
 		return nil, err
@@ -835,6 +690,10 @@ func (mc *cwConn) ExecContext(ctx context.Context, query string, args []driver.N
 }
 
 func (mc *cwConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return interceptorChain(mc.cfg.Interceptors).prepare(ctx, query, mc.prepareContext)
+}
+
+func (mc *cwConn) prepareContext(ctx context.Context, query string) (driver.Stmt, error) {
 	if err := mc.watchCancel(ctx); err != nil {
 		return nil, err
 	}
@@ -855,6 +714,27 @@ func (mc *cwConn) PrepareContext(ctx context.Context, query string) (driver.Stmt
 }
 
 func (stmt *cwStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	chain := interceptorChain(stmt.mc.cfg.Interceptors)
+	// the statement is prepared, its query can't be rewritten
+	return chain.query(ctx, stmt.sql, args, func(ctx context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+		var rows *textRows
+		err := stmt.mc.retry(ctx, stmt.sql, func() (err error) {
+			if err = stmt.reprepare(ctx); err == nil {
+				rows, err = stmt.queryContext(ctx, args)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		rows.intercept(ctx, stmt.sql, chain)
+		return rows, nil
+	})
+}
+
+func (stmt *cwStmt) queryContext(ctx context.Context, args []driver.NamedValue) (*textRows, error) {
+	stmt.mc.logStatement(ctx, "query", stmt.sql, args)
+	defer stmt.mc.observeQuery(time.Now())
 	dargs, err := namedValueToValue(args)
 	if err != nil {
 		return nil, err
@@ -874,6 +754,20 @@ func (stmt *cwStmt) QueryContext(ctx context.Context, args []driver.NamedValue)
 }
 
 func (stmt *cwStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return interceptorChain(stmt.mc.cfg.Interceptors).exec(ctx, stmt.sql, args, func(ctx context.Context, _ string, args []driver.NamedValue) (res driver.Result, err error) {
+		err = stmt.mc.retry(ctx, stmt.sql, func() error {
+			if err = stmt.reprepare(ctx); err == nil {
+				res, err = stmt.execContext(ctx, args)
+			}
+			return err
+		})
+		return res, err
+	})
+}
+
+func (stmt *cwStmt) execContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	stmt.mc.logStatement(ctx, "exec", stmt.sql, args)
+	defer stmt.mc.observeQuery(time.Now())
 	dargs, err := namedValueToValue(args)
 	if err != nil {
 		return nil, err
@@ -888,6 +782,7 @@ func (stmt *cwStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (
 }
 
 func (mc *cwConn) watchCancel(ctx context.Context) error {
+	mc.setIdle(false)
 	if mc.watching {
 		// Reach here if canceled,
 		// so the connection is already invalid
@@ -917,12 +812,23 @@ func (mc *cwConn) startWatcher() {
 	mc.watcher = watcher
 	finished := make(chan struct{})
 	mc.finished = finished
+	// a reconnected connection has a new closech and a new watcher
+	closech := mc.closech
 	go func() {
+		var keepAlive <-chan time.Time
+		if mc.cfg.KeepAlive > 0 {
+			ticker := time.NewTicker(mc.cfg.KeepAlive)
+			defer ticker.Stop()
+			keepAlive = ticker.C
+		}
 		for {
 			var ctx context.Context
 			select {
 			case ctx = <-watcher:
-			case <-mc.closech:
+			case <-keepAlive:
+				mc.keepAlive()
+				continue
+			case <-closech:
 				return
 			}
 
@@ -930,7 +836,7 @@ func (mc *cwConn) startWatcher() {
 			case <-ctx.Done():
 				mc.cancel(ctx.Err())
 			case <-finished:
-			case <-mc.closech:
+			case <-closech:
 				return
 			}
 		}
@@ -938,22 +844,33 @@ func (mc *cwConn) startWatcher() {
 }
 
 func (mc *cwConn) CheckNamedValue(nv *driver.NamedValue) (err error) {
-	nv.Value, err = converter{}.ConvertValue(nv.Value)
+	if _, ok := nv.Value.(AdminCommand); ok && nv.Ordinal == 1 {
+		return nil
+	}
+	nv.Value, err = mc.cfg.convertValue(nv.Value, noParamType)
 	return
 }
 
 // ResetSession implements driver.SessionResetter.
 // (From Go 1.10)
 func (mc *cwConn) ResetSession(ctx context.Context) error {
+	expired := mc.setIdle(false)
 	if mc.closed.IsSet() {
 		return driver.ErrBadConn
 	}
+	if expired {
+		mc.log(ctx, logLevelInfo, "closing connection before its session times out")
+		mc.Close()
+		return driver.ErrBadConn
+	}
 	mc.reset = true
 	return nil
 }
 
 // IsValid implements driver.Validator interface
 // (From Go 1.15)
+// database/sql calls it when it puts the connection back into the pool.
 func (mc *cwConn) IsValid() bool {
-	return !mc.closed.IsSet()
+	expired := mc.setIdle(true)
+	return !mc.closed.IsSet() && !expired
 }
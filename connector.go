@@ -11,29 +11,73 @@ package cloudwave
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
 	"net"
+	"sync/atomic"
+	"time"
 )
 
+// connIDs numbers the connections.
+var connIDs uint64
+
 type connector struct {
-	cfg *Config // immutable private copy.
+	cfg   *Config // immutable private copy.
+	stats metrics // statistics of the connections
 }
 
 // Connect implements driver.Connector interface.
 // Connect returns a connection to the database.
 func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
-	var err error
+	return interceptorChain(c.cfg.Interceptors).connect(ctx, c.connect)
+}
 
+func (c *connector) connect(ctx context.Context) (driver.Conn, error) {
 	// New cwConn
 	mc := &cwConn{
-		maxAllowedPacket: maxPacketSize,
-		maxWriteSize:     maxPacketSize - 1,
-		closech:          make(chan struct{}),
-		cfg:              c.cfg,
-		execType:         CLOUDWAVE_EXECUTE,
-		txBatchFlag:      false,
+		cfg:         c.cfg,
+		connID:      atomic.AddUint64(&connIDs, 1),
+		trace:       c.cfg.traceSink,
+		pool:        &c.stats,
+		metricsSink: c.cfg.metricsSink,
+	}
+	mc.init()
+
+	if err := mc.open(ctx); err != nil {
+		return nil, err
 	}
+	return mc, nil
+}
+
+// init sets the state of a connection about to be opened. connect calls it
+// for new connections and reconnect for connections opened again, which
+// keep only their configuration, identity and statistics.
+func (mc *cwConn) init() {
+	mc.maxAllowedPacket = maxPacketSize
+	mc.maxWriteSize = maxPacketSize - 1
+	mc.affectedRows, mc.insertId = 0, 0
+	mc.status = 0
+	mc.sequence = 0
 	mc.parseTime = mc.cfg.ParseTime
+	mc.reset = false
+
+	mc.watching = false
+	mc.closech = make(chan struct{})
+	mc.canceled = atomicError{}
+	mc.closed.Set(false)
+
+	mc.idleMu.Lock()
+	mc.idle = false
+	mc.lastCmd, mc.lastSent = 0, time.Time{}
+	mc.idleMu.Unlock()
+
+	mc.txBatchFlag = false
+	mc.inTx = false
+	mc.execType = CLOUDWAVE_EXECUTE
+}
 
+// open dials the server, builds the connection and sets up its session.
+// connect opens new connections, and reconnect opens them again.
+func (mc *cwConn) open(ctx context.Context) (err error) {
 	// Connect to Server
 	dialsLock.RLock()
 	dial, ok := dials[mc.cfg.Net]
@@ -42,7 +86,7 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 		dctx := ctx
 		if mc.cfg.Timeout > 0 {
 			var cancel context.CancelFunc
-			dctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+			dctx, cancel = context.WithTimeout(ctx, mc.cfg.Timeout)
 			defer cancel()
 		}
 		mc.netConn, err = dial(dctx, mc.cfg.Addr)
@@ -52,7 +96,7 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 	}
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Enable TCP Keepalives on TCP connections
@@ -61,7 +105,7 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 			// Don't send COM_QUIT before handshake.
 			mc.netConn.Close()
 			mc.netConn = nil
-			return nil, err
+			return err
 		}
 	}
 
@@ -69,11 +113,12 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 	mc.startWatcher()
 	if err := mc.watchCancel(ctx); err != nil {
 		mc.cleanup()
-		return nil, err
+		return err
 	}
 	defer mc.finish()
 
 	mc.buf = newBuffer(mc.netConn)
+	mc.buf.counter = mc.countBytesRead
 
 	// Set I/O timeouts
 	mc.buf.timeout = mc.cfg.ReadTimeout
@@ -82,13 +127,34 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 	err = mc.writeFirstPacket()
 	if err != nil {
 		mc.cleanup()
-		return nil, err
+		return err
 	}
 
 	err = mc.readFirstResponsePacket()
 	if err != nil {
 		mc.cleanup()
-		return nil, err
+		return err
+	}
+
+	if err = mc.readServerVersion(); err != nil {
+		// servers failing GET_SERVER_VERSION, or answering it with
+		// something else than a version, are of unknown version
+		var cwErr *CloudWaveError
+		if mc.closed.IsSet() || !errors.As(err, &cwErr) && !errors.Is(err, ErrMalformPkt) {
+			mc.Close()
+			return err
+		}
+		mc.log(ctx, logLevelWarn, "reading the server version failed", "err", err)
+		mc.version = ServerVersion{}
+	}
+
+	mc.fetchSize = 1
+	if mc.cfg.FetchSize > 1 {
+		if err := mc.require(FeatureFetchSize); err != nil {
+			mc.log(ctx, logLevelWarn, "reading one row per request", "err", err)
+		} else {
+			mc.fetchSize = mc.cfg.FetchSize
+		}
 	}
 
 	mc.UseSchema()
@@ -102,7 +168,7 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 			maxap, err := mc.getSystemVar("max_allowed_packet")
 			if err != nil {
 				mc.Close()
-				return nil, err
+				return err
 			}
 			mc.maxAllowedPacket = stringToInt(maxap) - 1
 		*/
@@ -116,10 +182,11 @@ func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
 	err = mc.handleParams()
 	if err != nil {
 		mc.Close()
-		return nil, err
+		return err
 	}
 
-	return mc, nil
+	mc.count(counterConnects, 1)
+	return nil
 }
 
 // Driver implements driver.Connector interface.
@@ -14,7 +14,6 @@ const (
 	minProtocolVersion      = 10
 	maxPacketSize           = 1<<24 - 1
 	dateFormat              = "2006-01-02"
-	timeFormat              = "2006-01-02 15:04:05.00000"
 
 	INT_MIN_VALUE = 0x80000000
 	INT_MAX_VALUE = 0x7fffffff
@@ -23,6 +22,9 @@ const (
 	LONG_MAX_VALUE = 0x7fffffffffffffff
 
 	INT_CHUNK_SIZE = 8192
+
+	lobModeLazy  = "lazy"
+	lobModeEager = "eager"
 )
 
 // MySQL constants documentation:
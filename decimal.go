@@ -0,0 +1,491 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// defaultDecimalScale is the scale TINY_DECIMAL and SMALL_DECIMAL parameters
+// are sent at: their wire format has no scale byte and the prepare result
+// only reports the parameter types. It also bounds the digits kept when a
+// *big.Rat parameter has no exact decimal representation.
+const defaultDecimalScale = 10
+
+// maxDecimalExponent bounds the exponent ParseDecimal accepts, so that
+// "1e999999999" fails instead of building a huge number.
+const maxDecimalExponent = 4096
+
+var (
+	bigOne = big.NewInt(1)
+	bigTen = big.NewInt(10)
+)
+
+// Decimal is an exact decimal number, the value of unscaled * 10^-scale.
+// Decimal implements the Scanner and Valuer interfaces, so it can be used
+// both as a scan destination and as a parameter for DECIMAL / NUMERIC
+// columns:
+//
+//	var d cloudwave.Decimal
+//	err := db.QueryRow("SELECT price FROM goods WHERE id=?", id).Scan(&d)
+//	...
+//	_, err = db.Exec("UPDATE goods SET price=? WHERE id=?", d, id)
+//
+// Scan into a *Decimal (i.e. pass a **Decimal) to handle NULL values.
+type Decimal struct {
+	unscaled *big.Int // nil for the zero Decimal, never changed once set
+	scale    int32
+}
+
+// NewDecimal returns unscaled * 10^-scale.
+func NewDecimal(unscaled *big.Int, scale int32) Decimal {
+	d := Decimal{unscaled: new(big.Int), scale: scale}
+	if unscaled != nil {
+		d.unscaled.Set(unscaled)
+	}
+	return d
+}
+
+// NewDecimalFromInt64 returns unscaled * 10^-scale.
+func NewDecimalFromInt64(unscaled int64, scale int32) Decimal {
+	return Decimal{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+// bigInt returns the unscaled value of d, which must not be changed.
+func (d Decimal) bigInt() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// ParseDecimal parses a decimal string such as "-12.50", "3" or "1.5e-3".
+// The scale of the result is the number of digits after the decimal point,
+// so "12.50" keeps its scale of 2.
+func ParseDecimal(s string) (Decimal, error) {
+	d := Decimal{unscaled: new(big.Int)}
+	str := strings.TrimSpace(s)
+	if len(str) == 0 {
+		return d, fmt.Errorf("cloudwave: invalid decimal %q", s)
+	}
+
+	exp := 0
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		e, err := strconv.Atoi(str[i+1:])
+		if err != nil {
+			return d, fmt.Errorf("cloudwave: invalid decimal %q", s)
+		}
+		if e > maxDecimalExponent || e < -maxDecimalExponent {
+			return d, fmt.Errorf("cloudwave: decimal exponent out of range %q", s)
+		}
+		exp = e
+		str = str[:i]
+	}
+
+	digits := str
+	scale := 0
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		digits = str[:i] + str[i+1:]
+		scale = len(str) - i - 1
+	}
+	if len(digits) == 0 || digits == "-" || digits == "+" {
+		return d, fmt.Errorf("cloudwave: invalid decimal %q", s)
+	}
+	for i := 0; i < len(digits); i++ {
+		c := digits[i]
+		if (c < '0' || c > '9') && !(i == 0 && (c == '-' || c == '+')) {
+			return d, fmt.Errorf("cloudwave: invalid decimal %q", s)
+		}
+	}
+	if _, ok := d.unscaled.SetString(digits, 10); !ok {
+		return d, fmt.Errorf("cloudwave: invalid decimal %q", s)
+	}
+
+	scale -= exp
+	if scale < 0 {
+		d.unscaled.Mul(d.unscaled, pow10(-scale))
+		scale = 0
+	}
+	if scale > maxDecimalExponent {
+		return d, fmt.Errorf("cloudwave: decimal scale out of range %q", s)
+	}
+	d.scale = int32(scale)
+	return d, nil
+}
+
+// NewDecimalFromRat converts r to a Decimal. It fails if r has no finite
+// decimal representation, e.g. 1/3; use Rat.FloatString and ParseDecimal to
+// round such values explicitly.
+func NewDecimalFromRat(r *big.Rat) (Decimal, error) {
+	d, exact := decimalFromRat(r, -1)
+	if !exact {
+		return Decimal{}, fmt.Errorf("cloudwave: %s has no exact decimal representation", r.String())
+	}
+	return d, nil
+}
+
+// decimalFromRat converts r to a Decimal. If r terminates it is converted
+// exactly. Otherwise it is rounded half up to maxScale digits when maxScale
+// is not negative.
+func decimalFromRat(r *big.Rat, maxScale int) (Decimal, bool) {
+	d := Decimal{unscaled: new(big.Int)}
+	denom := new(big.Int).Set(r.Denom())
+
+	// A fraction terminates iff its reduced denominator is 2^a * 5^b.
+	var twos, fives int
+	mod := new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(denom, big.NewInt(2), mod)
+		if m.Sign() != 0 {
+			break
+		}
+		denom = q
+		twos++
+	}
+	for {
+		q, m := new(big.Int).QuoRem(denom, big.NewInt(5), mod)
+		if m.Sign() != 0 {
+			break
+		}
+		denom = q
+		fives++
+	}
+	if denom.Cmp(bigOne) == 0 {
+		scale := twos
+		if fives > scale {
+			scale = fives
+		}
+		num := new(big.Int).Mul(r.Num(), pow10(scale))
+		d.unscaled.Quo(num, r.Denom())
+		d.scale = int32(scale)
+		return d, true
+	}
+	if maxScale < 0 {
+		return d, false
+	}
+	num := new(big.Int).Mul(r.Num(), pow10(maxScale))
+	d.unscaled = roundQuo(num, r.Denom())
+	d.scale = int32(maxScale)
+	return d, true
+}
+
+// pow10 returns 10^n as a new big.Int.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// roundQuo returns x/y rounded half away from zero.
+func roundQuo(x, y *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	r2 := new(big.Int).Abs(r)
+	r2.Lsh(r2, 1)
+	if r2.Cmp(new(big.Int).Abs(y)) >= 0 {
+		if x.Sign()*y.Sign() < 0 {
+			q.Sub(q, bigOne)
+		} else {
+			q.Add(q, bigOne)
+		}
+	}
+	return q
+}
+
+// Unscaled returns a copy of the unscaled value of d.
+func (d Decimal) Unscaled() *big.Int {
+	return new(big.Int).Set(d.bigInt())
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	return d.bigInt().Sign()
+}
+
+// Rat returns d as a *big.Rat.
+func (d Decimal) Rat() *big.Rat {
+	r := new(big.Rat).SetInt(d.bigInt())
+	if d.scale > 0 {
+		r.Quo(r, new(big.Rat).SetInt(pow10(int(d.scale))))
+	} else if d.scale < 0 {
+		r.Mul(r, new(big.Rat).SetInt(pow10(int(-d.scale))))
+	}
+	return r
+}
+
+// Float64 returns the nearest float64 value of d.
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// Cmp compares d and x and returns -1, 0 or +1.
+// Decimals with different scales but the same value compare equal.
+func (d Decimal) Cmp(x Decimal) int {
+	return d.Rat().Cmp(x.Rat())
+}
+
+// Round returns d with the given scale. Digits beyond scale are rounded half
+// away from zero.
+func (d Decimal) Round(scale int32) Decimal {
+	r := Decimal{scale: scale}
+	switch {
+	case scale == d.scale:
+		r.unscaled = new(big.Int).Set(d.bigInt())
+	case scale > d.scale:
+		r.unscaled = new(big.Int).Mul(d.bigInt(), pow10(int(scale-d.scale)))
+	default:
+		r.unscaled = roundQuo(d.bigInt(), pow10(int(d.scale-scale)))
+	}
+	return r
+}
+
+// String returns d in plain notation, keeping trailing zeros of the scale.
+func (d Decimal) String() string {
+	s := d.bigInt().String()
+	if d.scale <= 0 {
+		if d.scale < 0 && d.Sign() != 0 {
+			s += strings.Repeat("0", int(-d.scale))
+		}
+		return s
+	}
+	neg := false
+	if s[0] == '-' {
+		neg = true
+		s = s[1:]
+	}
+	scale := int(d.scale)
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// Scan implements the Scanner interface.
+func (d *Decimal) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return errors.New("cloudwave: cannot scan NULL into Decimal, use *Decimal")
+	case Decimal:
+		*d = NewDecimal(v.unscaled, v.scale)
+		return nil
+	case string:
+		p, err := ParseDecimal(v)
+		if err != nil {
+			return err
+		}
+		*d = p
+		return nil
+	case []byte:
+		p, err := ParseDecimal(string(v))
+		if err != nil {
+			return err
+		}
+		*d = p
+		return nil
+	case int64:
+		*d = NewDecimalFromInt64(v, 0)
+		return nil
+	case int32:
+		*d = NewDecimalFromInt64(int64(v), 0)
+		return nil
+	case float64:
+		return d.scanFloat(v, 64)
+	case float32:
+		return d.scanFloat(float64(v), 32)
+	}
+	return fmt.Errorf("cloudwave: can't convert %T to Decimal", value)
+}
+
+func (d *Decimal) scanFloat(f float64, bitSize int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("cloudwave: can't convert %v to Decimal", f)
+	}
+	p, err := ParseDecimal(strconv.FormatFloat(f, 'f', -1, bitSize))
+	if err != nil {
+		return err
+	}
+	*d = p
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+// The value is sent as its exact decimal string.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// toDecimal converts a bound parameter to a Decimal. Values without a
+// finite decimal representation are rounded to scale digits.
+func toDecimal(arg driver.Value, scale int) (Decimal, error) {
+	switch v := arg.(type) {
+	case Decimal:
+		return NewDecimal(v.unscaled, v.scale), nil
+	case *Decimal:
+		return NewDecimal(v.unscaled, v.scale), nil
+	case *big.Rat:
+		d, _ := decimalFromRat(v, scale)
+		return d, nil
+	case *big.Int:
+		return NewDecimal(v, 0), nil
+	case int64:
+		return NewDecimalFromInt64(v, 0), nil
+	case uint64:
+		return NewDecimal(new(big.Int).SetUint64(v), 0), nil
+	case float64:
+		var d Decimal
+		err := d.scanFloat(v, 64)
+		return d, err
+	case bool:
+		if v {
+			return NewDecimalFromInt64(1, 0), nil
+		}
+		return NewDecimalFromInt64(0, 0), nil
+	case string:
+		return ParseDecimal(v)
+	case []byte:
+		return ParseDecimal(string(v))
+	}
+	return Decimal{}, fmt.Errorf("cloudwave: can't convert %T to a decimal", arg)
+}
+
+// decimalFits reports whether d rounded to scale fits the unscaled value of
+// a TINY_DECIMAL or SMALL_DECIMAL.
+func decimalFits(tp byte, d Decimal, scale int) bool {
+	r := d.Round(int32(scale))
+	if !r.unscaled.IsInt64() {
+		return false
+	}
+	if tp == CLOUD_TYPE_TINY_DECIMAL {
+		return r.unscaled.Int64() >= math.MinInt32 && r.unscaled.Int64() <= math.MaxInt32
+	}
+	return true
+}
+
+// appendDecimal encodes d for a decimal column of type tp.
+// TINY_DECIMAL and SMALL_DECIMAL are sent as a 32 or 64 bit unscaled value
+// at the given scale. BIG_DECIMAL carries its own scale: a flag byte, then
+// either a 64 bit unscaled value (flag 0) or a length prefixed two's
+// complement big integer (flag 1), then the scale byte.
+func appendDecimal(data []byte, tp byte, d Decimal, scale int) (int, error) {
+	pos := 0
+	switch tp {
+	case CLOUD_TYPE_TINY_DECIMAL:
+		if !decimalFits(tp, d, scale) {
+			return 0, fmt.Errorf("cloudwave: decimal %s overflows TINY_DECIMAL", d.String())
+		}
+		r := d.Round(int32(scale))
+		binary.BigEndian.PutUint32(data[pos:], uint32(int32(r.unscaled.Int64())))
+		pos += 4
+	case CLOUD_TYPE_SMALL_DECIMAL:
+		if !decimalFits(tp, d, scale) {
+			return 0, fmt.Errorf("cloudwave: decimal %s overflows SMALL_DECIMAL", d.String())
+		}
+		r := d.Round(int32(scale))
+		binary.BigEndian.PutUint64(data[pos:], uint64(r.unscaled.Int64()))
+		pos += 8
+	case CLOUD_TYPE_BIG_DECIMAL:
+		r := d.Round(d.scale)
+		if r.scale < 0 {
+			r = r.Round(0)
+		}
+		if r.scale > math.MaxUint8 {
+			return 0, fmt.Errorf("cloudwave: decimal scale %d out of range", r.scale)
+		}
+		if r.unscaled.IsInt64() {
+			data[pos] = 0
+			pos++
+			binary.BigEndian.PutUint64(data[pos:], uint64(r.unscaled.Int64()))
+			pos += 8
+		} else {
+			buf, err := bigInt2bytes(*r.unscaled)
+			if err != nil {
+				return 0, err
+			}
+			data[pos] = 1
+			pos++
+			binary.BigEndian.PutUint32(data[pos:], uint32(len(buf)))
+			pos += 4
+			pos += copy(data[pos:], buf)
+		}
+		data[pos] = byte(r.scale)
+		pos++
+	default:
+		return 0, fmt.Errorf("cloudwave: type %d is not a decimal type", tp)
+	}
+	return pos, nil
+}
+
+// readDecimal decodes a decimal value of type tp and returns it along with
+// the number of bytes read.
+func readDecimal(b []byte, tp byte) (Decimal, int, error) {
+	d := Decimal{unscaled: new(big.Int)}
+	pos := 0
+	switch tp {
+	case CLOUD_TYPE_TINY_DECIMAL:
+		if len(b) < 5 {
+			return d, 0, ErrMalformPkt
+		}
+		d.unscaled.SetInt64(int64(int32(binary.BigEndian.Uint32(b[pos:]))))
+		pos += 4
+	case CLOUD_TYPE_SMALL_DECIMAL:
+		if len(b) < 9 {
+			return d, 0, ErrMalformPkt
+		}
+		d.unscaled.SetInt64(int64(binary.BigEndian.Uint64(b[pos:])))
+		pos += 8
+	case CLOUD_TYPE_BIG_DECIMAL:
+		if len(b) < 1 {
+			return d, 0, ErrMalformPkt
+		}
+		if b[pos] == 0 {
+			if len(b) < 1+8+1 {
+				return d, 0, ErrMalformPkt
+			}
+			d.unscaled.SetInt64(int64(binary.BigEndian.Uint64(b[pos+1:])))
+			pos += 1 + 8
+		} else {
+			if len(b) < 2 {
+				return d, 0, ErrMalformPkt
+			}
+			n := int(b[pos+1])
+			pos += 2
+			if len(b) < pos+n+1 {
+				return d, 0, ErrMalformPkt
+			}
+			bi, err := bytes2bigInt(b[pos : pos+n])
+			if err != nil {
+				return d, 0, err
+			}
+			d.unscaled = &bi
+			pos += n
+		}
+	default:
+		return d, 0, fmt.Errorf("cloudwave: type %d is not a decimal type", tp)
+	}
+	d.scale = int32(b[pos])
+	pos++
+	return d, pos, nil
+}
@@ -0,0 +1,237 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"math/big"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in       string
+		unscaled int64
+		scale    int32
+		str      string
+	}{
+		{"12.50", 1250, 2, "12.50"},
+		{"-3", -3, 0, "-3"},
+		{"+0.000", 0, 3, "0.000"},
+		{" 7.1 ", 71, 1, "7.1"},
+		{".5", 5, 1, "0.5"},
+		{"-0.05", -5, 2, "-0.05"},
+		{"1.5e-3", 15, 4, "0.0015"},
+		{"1.5E3", 1500, 0, "1500"},
+		{"123456789012345678.9", 1234567890123456789, 1, "123456789012345678.9"},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if err != nil {
+			t.Errorf("ParseDecimal(%q): %v", tt.in, err)
+			continue
+		}
+		if d.Unscaled().Int64() != tt.unscaled || d.Scale() != tt.scale || d.String() != tt.str {
+			t.Errorf("ParseDecimal(%q) = %s * 10^-%d (%s)", tt.in, d.Unscaled(), d.Scale(), d)
+		}
+	}
+	for _, in := range []string{"", "-", "+", ".", "1.2.3", "abc", "1e", "1e+x", "--1", "1-", "1e999999999", "1e-999999999"} {
+		if d, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) = %s", in, d)
+		}
+	}
+
+	huge := "-123456789012345678901234567890.123456789"
+	if d, err := ParseDecimal(huge); err != nil || d.String() != huge {
+		t.Errorf("ParseDecimal(%q) = %s, %v", huge, d, err)
+	}
+}
+
+func TestDecimalCopies(t *testing.T) {
+	d, err := ParseDecimal("1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Unscaled().SetInt64(9)
+	r := d.Round(d.Scale())
+	r.unscaled.SetInt64(7)
+	var scanned Decimal
+	if err = scanned.Scan(d); err != nil {
+		t.Fatal(err)
+	}
+	scanned.unscaled.SetInt64(8)
+	conv, err := toDecimal(&d, defaultDecimalScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv.unscaled.SetInt64(6)
+	if d.String() != "1.5" {
+		t.Errorf("Decimal changed through a copy: %s", d)
+	}
+
+	var zero Decimal
+	if zero.String() != "0" || zero.Sign() != 0 || zero.Round(2).String() != "0.00" {
+		t.Errorf("zero Decimal is %s", zero)
+	}
+}
+
+func TestDecimalNegativeScale(t *testing.T) {
+	d := NewDecimalFromInt64(12, -3)
+	if d.String() != "12000" || d.Rat().Cmp(big.NewRat(12000, 1)) != 0 {
+		t.Errorf("12e3 is %s", d)
+	}
+	if r := d.Round(0); r.String() != "12000" || r.Unscaled().Int64() != 12000 {
+		t.Errorf("12e3 rounded to scale 0 is %s", r)
+	}
+	if r := NewDecimalFromInt64(1250, 0).Round(-2); r.String() != "1300" {
+		t.Errorf("1250 rounded to scale -2 is %s", r)
+	}
+
+	// BIG_DECIMAL has no negative scales
+	buf := make([]byte, 16)
+	n, err := appendDecimal(buf, CLOUD_TYPE_BIG_DECIMAL, d, defaultDecimalScale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, _, err := readDecimal(buf[:n], CLOUD_TYPE_BIG_DECIMAL)
+	if err != nil || back.String() != "12000" || back.Scale() != 0 {
+		t.Errorf("12e3 read back as %s, %v", back, err)
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	tests := []struct {
+		in    string
+		scale int32
+		want  string
+	}{
+		{"1.25", 1, "1.3"},
+		{"-1.25", 1, "-1.3"},
+		{"1.24", 1, "1.2"},
+		{"-1.24", 1, "-1.2"},
+		{"0.5", 0, "1"},
+		{"-0.5", 0, "-1"},
+		{"0.49", 0, "0"},
+		{"12.3", 3, "12.300"},
+		{"9.999", 2, "10.00"},
+		{"12.30", 2, "12.30"},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Round(tt.scale); got.String() != tt.want || got.Scale() != tt.scale {
+			t.Errorf("%s rounded to %d is %s", tt.in, tt.scale, got)
+		}
+	}
+}
+
+func TestToDecimal(t *testing.T) {
+	tests := []struct {
+		arg  driver.Value
+		want string
+	}{
+		{"12.345", "12.345"},
+		{[]byte("-0.10"), "-0.10"},
+		{big.NewRat(1, 8), "0.125"},
+		{big.NewRat(-5, 4), "-1.25"},
+		{big.NewRat(1, 3), "0.3333333333"},
+		{big.NewRat(2, 3), "0.6666666667"},
+		{big.NewInt(-42), "-42"},
+		{int64(7), "7"},
+		{uint64(1) << 63, "9223372036854775808"},
+		{float64(0.1), "0.1"},
+		{true, "1"},
+		{NewDecimalFromInt64(314, 2), "3.14"},
+	}
+	for _, tt := range tests {
+		d, err := toDecimal(tt.arg, defaultDecimalScale)
+		if err != nil || d.String() != tt.want {
+			t.Errorf("toDecimal(%v) = %s, %v", tt.arg, d, err)
+		}
+	}
+	if _, err := toDecimal("1,5", defaultDecimalScale); err == nil {
+		t.Error("toDecimal accepted 1,5")
+	}
+	if _, err := NewDecimalFromRat(big.NewRat(1, 3)); err == nil {
+		t.Error("NewDecimalFromRat accepted 1/3")
+	}
+	if d, err := NewDecimalFromRat(big.NewRat(-3, 40)); err != nil || d.String() != "-0.075" {
+		t.Errorf("NewDecimalFromRat(-3/40) = %s, %v", d, err)
+	}
+}
+
+func TestDecimalWire(t *testing.T) {
+	tests := []struct {
+		tp    byte
+		in    string
+		scale int
+		wire  []byte // as sent; responses append the scale to TINY and SMALL values
+	}{
+		{CLOUD_TYPE_TINY_DECIMAL, "12.34", 2, []byte{0, 0, 0x04, 0xd2}},
+		{CLOUD_TYPE_TINY_DECIMAL, "-0.5", 1, []byte{0xff, 0xff, 0xff, 0xfb}},
+		{CLOUD_TYPE_TINY_DECIMAL, "1.005", 2, []byte{0, 0, 0, 0x65}},
+		{CLOUD_TYPE_SMALL_DECIMAL, "-12.34", 3, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xcf, 0xcc}},
+		{CLOUD_TYPE_BIG_DECIMAL, "12.34", 0, []byte{0, 0, 0, 0, 0, 0, 0, 0x04, 0xd2, 2}},
+		{CLOUD_TYPE_BIG_DECIMAL, "-1.5", 0, []byte{0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf1, 1}},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 32)
+		n, err := appendDecimal(buf, tt.tp, d, tt.scale)
+		if err != nil || !bytes.Equal(buf[:n], tt.wire) {
+			t.Errorf("appendDecimal(%d, %s) = %x, %v", tt.tp, tt.in, buf[:n], err)
+			continue
+		}
+		resp := tt.wire
+		if tt.tp != CLOUD_TYPE_BIG_DECIMAL {
+			resp = append(append([]byte(nil), tt.wire...), byte(tt.scale))
+		}
+		back, m, err := readDecimal(resp, tt.tp)
+		if err != nil || m != len(resp) || back.Cmp(d.Round(back.Scale())) != 0 {
+			t.Errorf("readDecimal(%d, %x) = %s, %d, %v", tt.tp, resp, back, m, err)
+		}
+	}
+
+	// BIG_DECIMAL values beyond 64 bits are sent as two's complement bytes
+	// with a 4 byte length, and received with a 1 byte length
+	huge, _ := ParseDecimal("-18446744073709551616.5")
+	buf := make([]byte, 32)
+	n, err := appendDecimal(buf, CLOUD_TYPE_BIG_DECIMAL, huge, 0)
+	want := []byte{1, 0, 0, 0, 9, 0xf5, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfb, 1}
+	if err != nil || !bytes.Equal(buf[:n], want) {
+		t.Errorf("appendDecimal(%s) = %x, %v", huge, buf[:n], err)
+	}
+	resp := []byte{1, 9, 0xf5, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfb, 1}
+	back, m, err := readDecimal(resp, CLOUD_TYPE_BIG_DECIMAL)
+	if err != nil || m != len(resp) || back.String() != huge.String() {
+		t.Errorf("readDecimal(%x) = %s, %d, %v", resp, back, m, err)
+	}
+
+	if _, err = appendDecimal(buf, CLOUD_TYPE_TINY_DECIMAL, NewDecimalFromInt64(1<<31, 0), 0); err == nil {
+		t.Error("TINY_DECIMAL overflow not detected")
+	}
+	if _, err = appendDecimal(buf, CLOUD_TYPE_SMALL_DECIMAL, huge, 0); err == nil {
+		t.Error("SMALL_DECIMAL overflow not detected")
+	}
+	for _, short := range [][]byte{{0, 0, 0, 1}, {0, 1, 2}, {1, 10, 0xff}} {
+		tp := byte(CLOUD_TYPE_TINY_DECIMAL)
+		if len(short) == 3 {
+			tp = CLOUD_TYPE_BIG_DECIMAL
+		}
+		if _, _, err = readDecimal(short, tp); err == nil {
+			t.Errorf("readDecimal(%d, %x) accepted a short value", tp, short)
+		}
+	}
+}
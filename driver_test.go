@@ -0,0 +1,391 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+// openTestDB starts a fake server and opens a database on it.
+func openTestDB(t *testing.T) (*cloudwavetest.Server, *sql.DB) {
+	t.Helper()
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("cloudwave", srv.DSN()+"?parseTime=true")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		srv.Close()
+		if err := srv.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return srv, db
+}
+
+func TestQueryRows(t *testing.T) {
+	srv, db := openTestDB(t)
+	ts := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+	srv.Expect("SELECT * FROM t").WillReturnRows(cloudwavetest.NewRows(
+		cloudwavetest.Column{Name: "t.id", Type: cloudwave.CLOUD_TYPE_LONG},
+		cloudwavetest.Column{Name: "t.name", Type: cloudwave.CLOUD_TYPE_VARCHAR, Precision: 20},
+		cloudwavetest.Column{Name: "t.score", Type: cloudwave.CLOUD_TYPE_DOUBLE},
+		cloudwavetest.Column{Name: "t.ok", Type: cloudwave.CLOUD_TYPE_BOOLEAN},
+		cloudwavetest.Column{Name: "t.at", Type: cloudwave.CLOUD_TYPE_TIMESTAMP},
+	).AddRow(1, "张三", 1.5, true, ts).AddRow(2, nil, nil, false, nil))
+
+	rows, err := db.Query("SELECT * FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 5 || cols[0] != "id" || cols[4] != "at" {
+		t.Errorf("unexpected columns %v", cols)
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := types[1].DatabaseTypeName(); name != "VARCHAR" {
+		t.Errorf("unexpected type name %q", name)
+	}
+
+	type row struct {
+		id    int64
+		name  sql.NullString
+		score sql.NullFloat64
+		ok    bool
+		at    sql.NullTime
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.name, &r.score, &r.ok, &r.at); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d rows", len(got))
+	}
+	if r := got[0]; r.id != 1 || r.name.String != "张三" || r.score.Float64 != 1.5 || !r.ok || !r.at.Time.Equal(ts) {
+		t.Errorf("unexpected first row %+v", r)
+	}
+	if r := got[1]; r.id != 2 || r.name.Valid || r.score.Valid || r.ok || r.at.Valid {
+		t.Errorf("unexpected second row %+v", r)
+	}
+}
+
+func TestFetchSize(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	reqs := new(lobRequests)
+	cloudwave.RegisterTraceSink("fetch", reqs)
+	defer cloudwave.DeregisterTraceSink("fetch")
+
+	for _, tt := range []struct {
+		version string
+		params  string
+		fetches int
+	}{
+		{"CloudWave 2.4.0", "", 6},
+		{"CloudWave 2.4.0", "&fetchSize=2", 4},
+		{"CloudWave 2.4.0", "&fetchSize=10", 2},
+		{"CloudWave 2.2.0", "&fetchSize=2", 4}, // fetchSize isn't gated
+	} {
+		srv.SetVersion(tt.version)
+		// the hidden auto key column ends each row
+		rows := cloudwavetest.NewRows(
+			cloudwavetest.Column{Name: "id", Type: cloudwave.CLOUD_TYPE_INTEGER},
+			cloudwavetest.Column{Name: "doc", Type: cloudwave.CLOUD_TYPE_CLOB},
+			cloudwavetest.Column{Name: cloudwavetest.AutoKeyColumn, Type: cloudwave.CLOUD_TYPE_LONG},
+		)
+		for i := 1; i <= 5; i++ {
+			rows.AddRow(int32(i), strings.Repeat("é", i), int64(1000+i))
+		}
+		srv.Expect("SELECT id, doc FROM t").WillReturnRows(rows)
+
+		db, err := sql.Open("cloudwave", srv.DSN()+"?trace=fetch&lobMode=eager"+tt.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs.take(cloudwave.RESULT_SET_QUERY_NEXT)
+		// the CLOBs are read between the rows of a response
+		var ids []int
+		err = func() error {
+			rows, err := db.Query("SELECT id, doc FROM t")
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var id int
+				var doc string
+				if err = rows.Scan(&id, &doc); err != nil {
+					return err
+				}
+				if doc != strings.Repeat("é", id) {
+					t.Errorf("%s%s: row %d holds %q", tt.version, tt.params, id, doc)
+				}
+				ids = append(ids, id)
+			}
+			return rows.Err()
+		}()
+		db.Close()
+		if err != nil {
+			t.Fatalf("%s%s: %v", tt.version, tt.params, err)
+		}
+		if !equalInts(ids, []int{1, 2, 3, 4, 5}) {
+			t.Errorf("%s%s: read rows %v", tt.version, tt.params, ids)
+		}
+		if n := len(reqs.take(cloudwave.RESULT_SET_QUERY_NEXT)); n != tt.fetches {
+			t.Errorf("%s%s: %d fetches, want %d", tt.version, tt.params, n, tt.fetches)
+		}
+	}
+}
+
+func TestExecAndPreparedArgs(t *testing.T) {
+	srv, db := openTestDB(t)
+	srv.Expect("DELETE FROM t").WillReturnResult(3)
+	srv.Expect("INSERT INTO t VALUES (?, ?, ?)").
+		WithParamTypes(cloudwave.CLOUD_TYPE_INTEGER, cloudwave.CLOUD_TYPE_VARCHAR, cloudwave.CLOUD_TYPE_DATE).
+		WithArgs(7, "x", time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)).
+		WillReturnResult(1)
+
+	res, err := db.Exec("DELETE FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("got %d affected rows", n)
+	}
+	// the string is converted to the INTEGER parameter type
+	res, err = db.Exec("INSERT INTO t VALUES (?, ?, ?)", "7", "x", "2023-01-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("got %d affected rows", n)
+	}
+}
+
+func TestDecimalArgs(t *testing.T) {
+	srv, db := openTestDB(t)
+	srv.Expect("INSERT INTO t VALUES (?, ?, ?)").
+		WithParamTypes(cloudwave.CLOUD_TYPE_TINY_DECIMAL, cloudwave.CLOUD_TYPE_TINY_DECIMAL, cloudwave.CLOUD_TYPE_SMALL_DECIMAL).
+		WithArgs(cloudwave.NewDecimalFromInt64(125, 3), cloudwave.NewDecimalFromInt64(-15, 1), cloudwave.NewDecimalFromInt64(12345678, 3)).
+		WillReturnResult(1)
+
+	// -1.5 doesn't fit a TINY_DECIMAL at the scale parameters are sent at
+	// and goes as a BIG_DECIMAL
+	if _, err := db.Exec("INSERT INTO t VALUES (?, ?, ?)", "0.125", cloudwave.NewDecimalFromInt64(-15, 1), 12345.678); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNullTimeRoundTrip(t *testing.T) {
+	srv, db := openTestDB(t)
+	ts := time.Date(2023, 7, 15, 11, 20, 5, 123000000, time.UTC)
+	srv.Expect("INSERT INTO t VALUES (?, ?)").
+		WithParamTypes(cloudwave.CLOUD_TYPE_TIMESTAMP, cloudwave.CLOUD_TYPE_TIMESTAMP).
+		WithArgs(ts, nil).
+		WillReturnResult(1)
+	srv.Expect("SELECT a, b FROM t").WillReturnRows(cloudwavetest.NewRows(
+		cloudwavetest.Column{Name: "t.a", Type: cloudwave.CLOUD_TYPE_TIMESTAMP},
+		cloudwavetest.Column{Name: "t.b", Type: cloudwave.CLOUD_TYPE_TIMESTAMP},
+	).AddRow(ts, nil))
+
+	if _, err := db.Exec("INSERT INTO t VALUES (?, ?)",
+		sql.NullTime{Time: ts.In(time.FixedZone("UTC+8", 8*60*60)), Valid: true}, sql.NullTime{}); err != nil {
+		t.Fatal(err)
+	}
+	var a, b sql.NullTime
+	if err := db.QueryRow("SELECT a, b FROM t").Scan(&a, &b); err != nil {
+		t.Fatal(err)
+	}
+	// without the loc parameter, times are returned in UTC
+	if !a.Valid || !a.Time.Equal(ts) || a.Time.Location() != time.UTC || b.Valid {
+		t.Errorf("scanned %+v, %+v", a, b)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	srv, db := openTestDB(t)
+	srv.Expect("SELECT * FROM missing").WillReturnError("42S02", "table missing not found")
+
+	_, err := db.Query("SELECT * FROM missing")
+	var cwErr *cloudwave.CloudWaveError
+	if !errors.As(err, &cwErr) || cwErr.Message != "table missing not found" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !errors.Is(err, cloudwave.ErrTableNotFound) || cwErr.SQLState != "42S02" {
+		t.Errorf("unexpected classification %q, %q", cwErr.Code, cwErr.SQLState)
+	}
+	if _, err = db.Exec("DROP TABLE t"); err == nil {
+		t.Error("unexpected statement succeeded")
+	}
+}
+
+func TestLOBRoundTrip(t *testing.T) {
+	srv, db := openTestDB(t)
+	srv.Expect("INSERT INTO docs VALUES (?, ?)").
+		WithParamTypes(cloudwave.CLOUD_TYPE_BLOB, cloudwave.CLOUD_TYPE_CLOB).
+		WithArgs([]byte{1, 2, 3}, "héllo 𝄞").
+		WillReturnResult(1)
+	srv.Expect("SELECT data, text FROM docs").WillReturnRows(cloudwavetest.NewRows(
+		cloudwavetest.Column{Name: "data", Type: cloudwave.CLOUD_TYPE_BLOB},
+		cloudwavetest.Column{Name: "text", Type: cloudwave.CLOUD_TYPE_CLOB},
+	).AddRow([]byte("binary"), "wörld 𝄞"))
+
+	if _, err := db.Exec("INSERT INTO docs VALUES (?, ?)", []byte{1, 2, 3}, "héllo 𝄞"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT data, text FROM docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	var blob cloudwave.Blob
+	var clob cloudwave.Clob
+	if err := rows.Scan(&blob, &clob); err != nil {
+		t.Fatal(err)
+	}
+	data, err := blob.Bytes()
+	if err != nil || string(data) != "binary" {
+		t.Errorf("got BLOB %q, %v", data, err)
+	}
+	text, err := clob.String()
+	if err != nil || text != "wörld 𝄞" {
+		t.Errorf("got CLOB %q, %v", text, err)
+	}
+}
+
+func TestColumnScanTypes(t *testing.T) {
+	for _, mode := range []string{"lazy", "eager"} {
+		t.Run(mode, func(t *testing.T) {
+			srv, err := cloudwavetest.NewServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+			srv.Expect("SELECT * FROM t").WillReturnRows(cloudwavetest.NewRows(
+				cloudwavetest.Column{Name: "ok", Type: cloudwave.CLOUD_TYPE_BOOLEAN},
+				cloudwavetest.Column{Name: "price", Type: cloudwave.CLOUD_TYPE_BIG_DECIMAL, Precision: 300, Scale: 2},
+				cloudwavetest.Column{Name: "data", Type: cloudwave.CLOUD_TYPE_BLOB},
+				cloudwavetest.Column{Name: "text", Type: cloudwave.CLOUD_TYPE_CLOB},
+			).AddRow(true, cloudwavetest.Raw{0, 0, 0, 0, 0, 0, 0, 0x04, 0xe2, 2}, []byte("binary"), "text"))
+
+			db, err := sql.Open("cloudwave", srv.DSN()+"?lobMode="+mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			rows, err := db.Query("SELECT * FROM t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			types, err := rows.ColumnTypes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []interface{}{sql.NullByte{}, sql.NullString{}, cloudwave.Blob{}, cloudwave.Clob{}}
+			if mode == "eager" {
+				want[2], want[3] = []byte(nil), sql.NullString{}
+			}
+			dest := make([]interface{}, len(types))
+			for i, ct := range types {
+				if ct.ScanType() != reflect.TypeOf(want[i]) {
+					t.Errorf("column %s: scan type %v, want %T", ct.Name(), ct.ScanType(), want[i])
+				}
+				dest[i] = reflect.New(ct.ScanType()).Interface()
+			}
+			if p, s, ok := types[1].DecimalSize(); !ok || p != 300 || s != 2 {
+				t.Errorf("DECIMAL size %d, %d, %v", p, s, ok)
+			}
+			// the values can be scanned into the reported types
+			if !rows.Next() {
+				t.Fatal(rows.Err())
+			}
+			if err = rows.Scan(dest...); err != nil {
+				t.Fatal(err)
+			}
+			if ok := dest[0].(*sql.NullByte); !ok.Valid || ok.Byte != 1 {
+				t.Errorf("BOOLEAN scanned as %+v", ok)
+			}
+			if price := dest[1].(*sql.NullString); price.String != "12.50" {
+				t.Errorf("DECIMAL scanned as %+v", price)
+			}
+		})
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	srv, db := openTestDB(t)
+	srv.Expect("UPDATE t SET a = ?").WithArgs(int64(1)).WillReturnResult(4)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := tx.Prepare("UPDATE t SET a = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stmt.Exec(1); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPingAndAuth(t *testing.T) {
+	srv, db := openTestDB(t)
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.SetCredentials("other", "secret")
+	bad, err := sql.Open("cloudwave", "other:wrong@tcp("+srv.Addr()+")/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bad.Close()
+	if err = bad.Ping(); err == nil {
+		t.Error("connected with wrong credentials")
+	}
+}
@@ -34,22 +34,36 @@ var (
 // If a new Config is created instead of being parsed from a DSN string,
 // the NewConfig function should be used, which sets default values.
 type Config struct {
-	User             string            // Username
-	Passwd           string            // Password (requires User)
-	Net              string            // Network type
-	Addr             string            // Network address (requires Net)
-	DBName           string            // Database name
-	Params           map[string]string // Connection parameters
-	Collation        string            // Connection collation
-	Loc              *time.Location    // Location for time.Time values
-	MaxAllowedPacket int               // Max packet size allowed
-	ServerPubKey     string            // Server public key name
-	pubKey           *rsa.PublicKey    // Server public key
-	TLSConfig        string            // TLS configuration name
-	tls              *tls.Config       // TLS configuration
-	Timeout          time.Duration     // Dial timeout
-	ReadTimeout      time.Duration     // I/O read timeout
-	WriteTimeout     time.Duration     // I/O write timeout
+	User             string             // Username
+	Passwd           string             // Password (requires User)
+	Net              string             // Network type
+	Addr             string             // Network address (requires Net)
+	DBName           string             // Database name
+	Params           map[string]string  // Connection parameters
+	Collation        string             // Connection collation
+	Loc              *time.Location     // Location for time.Time values
+	MaxAllowedPacket int                // Max packet size allowed
+	FetchSize        int                // Rows read per request by queries, 0 for one
+	LobChunkSize     int                // Chunk size for streaming BLOB and CLOB values
+	LobMode          string             // How BLOB and CLOB values are returned: "lazy" or "eager"
+	ServerPubKey     string             // Server public key name
+	pubKey           *rsa.PublicKey     // Server public key
+	TLSConfig        string             // TLS configuration name
+	tls              *tls.Config        // TLS configuration
+	Timeout          time.Duration      // Dial timeout
+	KeepAlive        time.Duration      // Interval of pings keeping idle connections alive
+	SessionTimeout   time.Duration      // Idle time after which the server drops sessions
+	Trace            string             // Trace sink name
+	traceSink        TraceSink          // Trace sink
+	Metrics          string             // Metrics sink name
+	metricsSink      MetricsSink        // Metrics sink
+	ReadTimeout      time.Duration      // I/O read timeout
+	RetryPolicy      RetryPolicy        // Retries of queries after transient failures
+	WriteTimeout     time.Duration      // I/O write timeout
+	typeCodecs       map[byte]TypeCodec // Type codecs registered for this Config
+	codecOrder       []byte             // Types of typeCodecs in ascending order
+	Interceptors     []Interceptor      // Hooks run around the operations of connections
+	Logger           StructuredLogger   // Logger, instead of the one set with SetLogger
 
 	AllowAllFiles           bool // Allow all files to be used with LOAD DATA LOCAL INFILE
 	AllowCleartextPasswords bool // Allows the cleartext client side plugin
@@ -59,6 +73,8 @@ type Config struct {
 	ClientFoundRows         bool // Return number of matching rows instead of rows changed
 	ColumnsWithAlias        bool // Prepend table alias to column names
 	InterpolateParams       bool // Interpolate placeholders into query string
+	LogParams               bool // Log the values bound to statements
+	LogProtocol             bool // Log statements, requests and responses at the debug level
 	MultiStatements         bool // Allow multiple statements in one query
 	ParseTime               bool // Parse time values to time.Time
 	RejectReadOnly          bool // Reject read-only connections
@@ -70,6 +86,8 @@ func NewConfig() *Config {
 		Collation:            defaultCollation,
 		Loc:                  time.UTC,
 		MaxAllowedPacket:     defaultMaxAllowedPacket,
+		LobChunkSize:         INT_CHUNK_SIZE,
+		LobMode:              lobModeLazy,
 		AllowNativePasswords: true,
 		CheckConnLiveness:    true,
 	}
@@ -92,6 +110,16 @@ func (cfg *Config) Clone() *Config {
 			E: cfg.pubKey.E,
 		}
 	}
+	if len(cfg.Interceptors) > 0 {
+		cp.Interceptors = append([]Interceptor(nil), cfg.Interceptors...)
+	}
+	if len(cfg.typeCodecs) > 0 {
+		cp.typeCodecs = make(map[byte]TypeCodec, len(cfg.typeCodecs))
+		for tp, codec := range cfg.typeCodecs {
+			cp.typeCodecs[tp] = codec
+		}
+		cp.codecOrder = append([]byte(nil), cfg.codecOrder...)
+	}
 	return &cp
 }
 
@@ -147,6 +175,20 @@ func (cfg *Config) normalize() error {
 		}
 	}
 
+	if cfg.Metrics != "" {
+		cfg.metricsSink = getMetricsSink(cfg.Metrics)
+		if cfg.metricsSink == nil {
+			return errors.New("invalid value / unknown metrics sink name: " + cfg.Metrics)
+		}
+	}
+
+	if cfg.Trace != "" {
+		cfg.traceSink = getTraceSink(cfg.Trace)
+		if cfg.traceSink == nil {
+			return errors.New("invalid value / unknown trace sink name: " + cfg.Trace)
+		}
+	}
+
 	return nil
 }
 
@@ -228,14 +270,42 @@ func (cfg *Config) FormatDSN() string {
 		writeDSNParam(&buf, &hasParam, "columnsWithAlias", "true")
 	}
 
+	if cfg.FetchSize > 0 {
+		writeDSNParam(&buf, &hasParam, "fetchSize", strconv.Itoa(cfg.FetchSize))
+	}
+
 	if cfg.InterpolateParams {
 		writeDSNParam(&buf, &hasParam, "interpolateParams", "true")
 	}
 
+	if cfg.KeepAlive > 0 {
+		writeDSNParam(&buf, &hasParam, "keepAliveInterval", cfg.KeepAlive.String())
+	}
+
+	if cfg.LobChunkSize != INT_CHUNK_SIZE && cfg.LobChunkSize > 0 {
+		writeDSNParam(&buf, &hasParam, "lobChunkSize", strconv.Itoa(cfg.LobChunkSize))
+	}
+
+	if cfg.LobMode != lobModeLazy && cfg.LobMode != "" {
+		writeDSNParam(&buf, &hasParam, "lobMode", cfg.LobMode)
+	}
+
 	if cfg.Loc != time.UTC && cfg.Loc != nil {
 		writeDSNParam(&buf, &hasParam, "loc", url.QueryEscape(cfg.Loc.String()))
 	}
 
+	if cfg.LogParams {
+		writeDSNParam(&buf, &hasParam, "logParams", "true")
+	}
+
+	if cfg.LogProtocol {
+		writeDSNParam(&buf, &hasParam, "logProtocol", "true")
+	}
+
+	if len(cfg.Metrics) > 0 {
+		writeDSNParam(&buf, &hasParam, "metrics", url.QueryEscape(cfg.Metrics))
+	}
+
 	if cfg.MultiStatements {
 		writeDSNParam(&buf, &hasParam, "multiStatements", "true")
 	}
@@ -252,10 +322,18 @@ func (cfg *Config) FormatDSN() string {
 		writeDSNParam(&buf, &hasParam, "rejectReadOnly", "true")
 	}
 
+	if cfg.RetryPolicy != (RetryPolicy{}) {
+		writeDSNParam(&buf, &hasParam, "retryPolicy", cfg.RetryPolicy.String())
+	}
+
 	if len(cfg.ServerPubKey) > 0 {
 		writeDSNParam(&buf, &hasParam, "serverPubKey", url.QueryEscape(cfg.ServerPubKey))
 	}
 
+	if cfg.SessionTimeout > 0 {
+		writeDSNParam(&buf, &hasParam, "sessionTimeout", cfg.SessionTimeout.String())
+	}
+
 	if cfg.Timeout > 0 {
 		writeDSNParam(&buf, &hasParam, "timeout", cfg.Timeout.String())
 	}
@@ -264,6 +342,10 @@ func (cfg *Config) FormatDSN() string {
 		writeDSNParam(&buf, &hasParam, "tls", url.QueryEscape(cfg.TLSConfig))
 	}
 
+	if len(cfg.Trace) > 0 {
+		writeDSNParam(&buf, &hasParam, "trace", url.QueryEscape(cfg.Trace))
+	}
+
 	if cfg.WriteTimeout > 0 {
 		writeDSNParam(&buf, &hasParam, "writeTimeout", cfg.WriteTimeout.String())
 	}
@@ -438,6 +520,16 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 		case "compress":
 			return errors.New("compression not implemented yet")
 
+		// Rows read per request by queries
+		case "fetchSize":
+			cfg.FetchSize, err = strconv.Atoi(value)
+			if err != nil {
+				return
+			}
+			if cfg.FetchSize < 0 {
+				return errors.New("invalid fetchSize value: " + value)
+			}
+
 		// Enable client side placeholder substitution
 		case "interpolateParams":
 			var isBool bool
@@ -446,6 +538,30 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 				return errors.New("invalid bool value: " + value)
 			}
 
+		// Interval of keepalive pings
+		case "keepAliveInterval":
+			cfg.KeepAlive, err = time.ParseDuration(value)
+			if err != nil {
+				return
+			}
+
+		// Chunk size for streaming LOB values
+		case "lobChunkSize":
+			cfg.LobChunkSize, err = strconv.Atoi(value)
+			if err != nil {
+				return
+			}
+			if cfg.LobChunkSize <= 0 {
+				return errors.New("invalid lobChunkSize value: " + value)
+			}
+
+		// How LOB values are returned
+		case "lobMode":
+			if value != lobModeLazy && value != lobModeEager {
+				return errors.New("invalid lobMode value: " + value)
+			}
+			cfg.LobMode = value
+
 		// Time Location
 		case "loc":
 			if value, err = url.QueryUnescape(value); err != nil {
@@ -456,6 +572,30 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 				return
 			}
 
+		// Log bound values
+		case "logParams":
+			var isBool bool
+			cfg.LogParams, isBool = readBool(value)
+			if !isBool {
+				return errors.New("invalid bool value: " + value)
+			}
+
+		// Log the protocol at the debug level
+		case "logProtocol":
+			var isBool bool
+			cfg.LogProtocol, isBool = readBool(value)
+			if !isBool {
+				return errors.New("invalid bool value: " + value)
+			}
+
+		// Metrics sink
+		case "metrics":
+			name, err := url.QueryUnescape(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for metrics sink name: %v", err)
+			}
+			cfg.Metrics = name
+
 		// multiple statements in one query
 		case "multiStatements":
 			var isBool bool
@@ -487,6 +627,13 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 				return errors.New("invalid bool value: " + value)
 			}
 
+		// Retries of queries after transient failures
+		case "retryPolicy":
+			cfg.RetryPolicy, err = parseRetryPolicy(value)
+			if err != nil {
+				return
+			}
+
 		// Server public key
 		case "serverPubKey":
 			name, err := url.QueryUnescape(value)
@@ -495,6 +642,13 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 			}
 			cfg.ServerPubKey = name
 
+		// Session timeout of the server
+		case "sessionTimeout":
+			cfg.SessionTimeout, err = time.ParseDuration(value)
+			if err != nil {
+				return
+			}
+
 		// Strict mode
 		case "strict":
 			panic("strict mode has been removed. See https://github.com/go-sql-driver/cloudwave/wiki/strict-mode")
@@ -525,6 +679,14 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 				cfg.TLSConfig = name
 			}
 
+		// Trace sink
+		case "trace":
+			name, err := url.QueryUnescape(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for trace sink name: %v", err)
+			}
+			cfg.Trace = name
+
 		// I/O write Timeout
 		case "writeTimeout":
 			cfg.WriteTimeout, err = time.ParseDuration(value)
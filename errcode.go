@@ -0,0 +1,157 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// ErrorCode classifies the errors of the server. The server only sends a
+// brief message and a message; the code is taken from the SQLSTATE they
+// carry, if any, or else derived from the message text, English or
+// Chinese, with the table of the driver.
+type ErrorCode string
+
+// Codes of server errors.
+const (
+	CodeDuplicateKey      ErrorCode = "DUPLICATE_KEY"
+	CodeNotNull           ErrorCode = "NOT_NULL"
+	CodeTableNotFound     ErrorCode = "TABLE_NOT_FOUND"
+	CodeTableExists       ErrorCode = "TABLE_EXISTS"
+	CodeColumnNotFound    ErrorCode = "COLUMN_NOT_FOUND"
+	CodeDatabaseNotFound  ErrorCode = "DATABASE_NOT_FOUND"
+	CodeSyntax            ErrorCode = "SYNTAX"
+	CodeDataTooLong       ErrorCode = "DATA_TOO_LONG"
+	CodeDivisionByZero    ErrorCode = "DIVISION_BY_ZERO"
+	CodeAccessDenied      ErrorCode = "ACCESS_DENIED"
+	CodeLockTimeout       ErrorCode = "LOCK_TIMEOUT"
+	CodeDeadlock          ErrorCode = "DEADLOCK"
+	CodeServerUnavailable ErrorCode = "SERVER_UNAVAILABLE"
+)
+
+// Sentinel server errors, to be used with errors.Is:
+//
+//	if errors.Is(err, cloudwave.ErrDuplicateKey) {
+//		// the row exists
+//	}
+var (
+	ErrDuplicateKey      = &CloudWaveError{Code: CodeDuplicateKey, SQLState: "23000", Message: "duplicate key"}
+	ErrNotNull           = &CloudWaveError{Code: CodeNotNull, SQLState: "23502", Message: "null value in a not null column"}
+	ErrTableNotFound     = &CloudWaveError{Code: CodeTableNotFound, SQLState: "42S02", Message: "table not found"}
+	ErrTableExists       = &CloudWaveError{Code: CodeTableExists, SQLState: "42S01", Message: "table already exists"}
+	ErrColumnNotFound    = &CloudWaveError{Code: CodeColumnNotFound, SQLState: "42S22", Message: "column not found"}
+	ErrDatabaseNotFound  = &CloudWaveError{Code: CodeDatabaseNotFound, SQLState: "3D000", Message: "database not found"}
+	ErrSyntax            = &CloudWaveError{Code: CodeSyntax, SQLState: "42000", Message: "syntax error"}
+	ErrDataTooLong       = &CloudWaveError{Code: CodeDataTooLong, SQLState: "22001", Message: "data too long"}
+	ErrDivisionByZero    = &CloudWaveError{Code: CodeDivisionByZero, SQLState: "22012", Message: "division by zero"}
+	ErrAccessDenied      = &CloudWaveError{Code: CodeAccessDenied, SQLState: "28000", Message: "access denied"}
+	ErrLockTimeout       = &CloudWaveError{Code: CodeLockTimeout, SQLState: "HYT00", Message: "lock wait timeout"}
+	ErrDeadlock          = &CloudWaveError{Code: CodeDeadlock, SQLState: "40001", Message: "deadlock"}
+	ErrServerUnavailable = &CloudWaveError{Code: CodeServerUnavailable, SQLState: "08006", Message: "server unavailable"}
+)
+
+// errorClasses maps server errors to codes. The first class listing the
+// SQLSTATE the error carries, or else whose pattern matches its text,
+// applies. Patterns match whole phrases of the messages of the server, so
+// that names and values quoted in messages don't classify them.
+var errorClasses = []struct {
+	err       *CloudWaveError
+	sqlStates []string // SQLSTATEs of the class besides the one of err
+	pattern   *regexp.Regexp
+	retryable bool
+}{
+	{ErrDuplicateKey, []string{"23505"}, regexp.MustCompile(`\bduplicate (entry|key|value)\b|\bunique (key|index|constraint) (violat|conflict)|违反唯一(约束|索引)|主键冲突|(主键|唯一键|键值)重复`), false},
+	{ErrNotNull, []string{"23502"}, regexp.MustCompile(`\b(can ?not|must not|may not) be null\b|\bviolates not[ -]null constraint|不能为空`), false},
+	{ErrTableExists, []string{"42P07"}, regexp.MustCompile(`\btable \S+ already exists\b|^表\s*\S+\s*已(经)?存在`), false},
+	{ErrTableNotFound, []string{"42P01"}, regexp.MustCompile(`\btable \S+ (not found|does ?n[o']t exist|not exist)|\b(unknown|no such) table\b|^表\s*\S+\s*不存在|找不到表`), false},
+	{ErrColumnNotFound, []string{"42703"}, regexp.MustCompile(`\bcolumn \S+ (not found|does ?n[o']t exist|not exist)|\b(unknown|no such) column\b|^(列|字段)\s*\S+\s*不存在|找不到列`), false},
+	{ErrDatabaseNotFound, []string{"3F000"}, regexp.MustCompile(`\b(database|schema) \S+ (not found|does ?n[o']t exist|not exist)|\bunknown (database|schema)\b|^(数据库|模式)\s*\S+\s*不存在`), false},
+	{ErrSyntax, []string{"42601"}, regexp.MustCompile(`\bsyntax error\b|\berror in (your )?sql syntax\b|\bparse error\b|语法错误`), false},
+	{ErrDataTooLong, nil, regexp.MustCompile(`\b(data|value|string) too long\b|\btoo (long|large) for (column|type)\b|超(出|过)(最大)?长度`), false},
+	{ErrDivisionByZero, nil, regexp.MustCompile(`\bdivi(de|sion) by zero\b|除数为(0|零)|除零`), false},
+	{ErrAccessDenied, []string{"28P01", "42501"}, regexp.MustCompile(`\b(access|permission) denied\b|\b(invalid|wrong|incorrect) (user ?name or )?password\b|\bpassword (is )?(invalid|wrong|incorrect)\b|\bauthentication failed\b|\bnot authori[sz]ed\b|权限不足|没有\S*权限|(用户名或)?密码错误|认证失败`), false},
+	{ErrDeadlock, []string{"40P01"}, regexp.MustCompile(`\bdeadlock (detected|found)\b|检测到死锁|发生死锁`), true},
+	{ErrLockTimeout, []string{"55P03"}, regexp.MustCompile(`\block (wait |request )?time(d)? ?out\b|(等待)?锁(等待)?超时`), true},
+	{ErrServerUnavailable, []string{"08001", "08004", "53300", "57P01"}, regexp.MustCompile(`\b(server|service|database) (is )?(temporarily )?(unavailable|busy|shutting down)\b|\btoo many connections\b|服务(器)?(不可用|繁忙|忙)|连接数(已满|过多|超过)`), true},
+}
+
+// sqlStatePattern finds a SQLSTATE mentioned in the text of an error.
+var sqlStatePattern = regexp.MustCompile(`(?i)sql ?state[^0-9A-Z]{0,3}([0-9A-Z]{5})`)
+
+// isSQLState reports whether s has the form of a SQLSTATE.
+func isSQLState(s string) bool {
+	if len(s) != 5 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// newCloudWaveError returns the error of an error packet, classified.
+func newCloudWaveError(brief, message string) *CloudWaveError {
+	e := &CloudWaveError{Brief: brief, Message: message}
+	if isSQLState(brief) {
+		e.SQLState = brief
+	} else if m := sqlStatePattern.FindStringSubmatch(brief + " " + message); m != nil {
+		e.SQLState = strings.ToUpper(m[1])
+	}
+	if e.SQLState != "" {
+		for _, c := range errorClasses {
+			if c.err.SQLState == e.SQLState {
+				e.Code = c.err.Code
+				return e
+			}
+			for _, state := range c.sqlStates {
+				if state == e.SQLState {
+					e.Code = c.err.Code
+					return e
+				}
+			}
+		}
+		return e
+	}
+	brief, message = strings.ToLower(brief), strings.ToLower(message)
+	for _, c := range errorClasses {
+		if c.pattern.MatchString(message) || c.pattern.MatchString(brief) {
+			e.Code, e.SQLState = c.err.Code, c.err.SQLState
+			return e
+		}
+	}
+	e.SQLState = "HY000"
+	return e
+}
+
+// IsRetryable reports whether err is transient, so that retrying the
+// operation may succeed: lock timeouts, deadlocks, an unavailable server,
+// and lost connections. Whether a statement that may have run can be run
+// again is up to the caller.
+func IsRetryable(err error) bool {
+	var cwErr *CloudWaveError
+	if errors.As(err, &cwErr) {
+		for _, c := range errorClasses {
+			if c.err.Code == cwErr.Code {
+				return c.retryable
+			}
+		}
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, ErrInvalidConn) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,90 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		brief, message string
+		sentinel       *CloudWaveError
+		sqlState       string
+		retryable      bool
+	}{
+		{"42S02", "table t not found", ErrTableNotFound, "42S02", false},
+		{"SQLException", "Duplicate entry '1' for key 'PRIMARY'", ErrDuplicateKey, "23000", false},
+		{"执行失败", "主键冲突: 1", ErrDuplicateKey, "23000", false},
+		{"SQLException", "表 t 不存在", ErrTableNotFound, "42S02", false},
+		{"SQLException", "表 t 已存在", ErrTableExists, "42S01", false},
+		{"SQLException", "Unknown column 'x' in 'field list'", ErrColumnNotFound, "42S22", false},
+		{"SQLException", "语法错误: near FROM", ErrSyntax, "42000", false},
+		{"SQLException", "failed (SQLState: 40001)", ErrDeadlock, "40001", true},
+		{"LockException", "Lock wait timeout exceeded", ErrLockTimeout, "HYT00", true},
+		{"Server", "服务器繁忙", ErrServerUnavailable, "08006", true},
+		{"SQLException", "something else", nil, "HY000", false},
+		{"23505", "violation", ErrDuplicateKey, "23505", false},
+		{"SQLException", "connection refused (SQLState: 08001)", ErrServerUnavailable, "08001", true},
+		{"SQLException", "Access denied for user 'u'", ErrAccessDenied, "28000", false},
+		{"SQLException", "用户名或密码错误", ErrAccessDenied, "28000", false},
+		{"SQLException", "Deadlock found when trying to get lock", ErrDeadlock, "40001", true},
+
+		// names and values in messages don't classify them
+		{"SQLException", "password column too long", nil, "HY000", false},
+		{"SQLException", "invalid value for column password", nil, "HY000", false},
+		{"SQLException", "密码字段为空", nil, "HY000", false},
+		{"SQLException", "重复的列名 a", nil, "HY000", false},
+		{"SQLException", "function unavailable_items not found", nil, "HY000", false},
+		{"SQLException", "table unavailable not found", ErrTableNotFound, "42S02", false},
+		{"SQLException", "column syntax_version: value out of range", nil, "HY000", false},
+		{"SQLException", "block read timeout", nil, "HY000", false},
+		{"SQLException", "table t: column x not found", ErrColumnNotFound, "42S22", false},
+		{"SQLException", "列表 t 不存在", nil, "HY000", false},
+		{"22003", "out of range", nil, "22003", false},
+	}
+	for _, tt := range tests {
+		e := newCloudWaveError(tt.brief, tt.message)
+		err := fmt.Errorf("wrapped: %w", e)
+		if e.SQLState != tt.sqlState {
+			t.Errorf("%s %s: got SQLSTATE %q, want %q", tt.brief, tt.message, e.SQLState, tt.sqlState)
+		}
+		if tt.sentinel != nil && (e.Code != tt.sentinel.Code || !errors.Is(err, tt.sentinel)) {
+			t.Errorf("%s %s: got code %q, want %q", tt.brief, tt.message, e.Code, tt.sentinel.Code)
+		}
+		if tt.sentinel == nil && e.Code != "" {
+			t.Errorf("%s %s: got code %q", tt.brief, tt.message, e.Code)
+		}
+		if tt.sentinel != ErrDuplicateKey && errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("%s %s: is a duplicate key error", tt.brief, tt.message)
+		}
+		if IsRetryable(err) != tt.retryable {
+			t.Errorf("%s %s: IsRetryable = %v", tt.brief, tt.message, !tt.retryable)
+		}
+	}
+
+	// errors without a code still compare by brief message
+	if !errors.Is(newCloudWaveError("E1", "a"), &CloudWaveError{Brief: "E1"}) {
+		t.Error("errors with the same brief message differ")
+	}
+	for _, err := range []error{driver.ErrBadConn, ErrInvalidConn} {
+		if !IsRetryable(err) {
+			t.Errorf("%v isn't retryable", err)
+		}
+	}
+	for _, err := range []error{context.Canceled, ErrMalformPkt, nil} {
+		if IsRetryable(err) {
+			t.Errorf("%v is retryable", err)
+		}
+	}
+}
@@ -47,8 +47,9 @@ type Logger interface {
 	Print(v ...interface{})
 }
 
-// SetLogger is used to set the logger for critical errors.
-// The initial logger is os.Stderr.
+// SetLogger is used to set the logger of the connections whose Config has
+// no Logger. Records are printed as the level, the message and key=value
+// pairs. The initial logger is os.Stderr.
 func SetLogger(logger Logger) error {
 	if logger == nil {
 		return errors.New("logger is nil")
@@ -57,19 +58,27 @@ func SetLogger(logger Logger) error {
 	return nil
 }
 
-// CloudWaveError is an error type which represents a single MySQL error
+// CloudWaveError is an error returned by the server. Code and SQLState
+// classify it, see ErrorCode.
 type CloudWaveError struct {
-	briefMessage string
-	Message      string
+	Brief    string    // brief message of the server
+	Message  string    // message of the server
+	Code     ErrorCode // classification of the error, empty if unknown
+	SQLState string    // SQLSTATE of the error, "HY000" if unknown
 }
 
 func (me *CloudWaveError) Error() string {
-	return fmt.Sprintf("Error %s: %s", me.briefMessage, me.Message)
+	return fmt.Sprintf("Error %s: %s", me.Brief, me.Message)
 }
 
+// Is reports whether err is a *CloudWaveError with the same code, such as
+// ErrDuplicateKey, or, for errors without a code, the same brief message.
 func (me *CloudWaveError) Is(err error) bool {
 	if merr, ok := err.(*CloudWaveError); ok {
-		return merr.briefMessage == me.briefMessage
+		if merr.Code != "" {
+			return merr.Code == me.Code
+		}
+		return merr.Brief == me.Brief
 	}
 	return false
 }
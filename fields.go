@@ -8,6 +8,13 @@
 
 package cloudwave
 
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"reflect"
+)
+
 /*
 	func (mf *cwField) typeDatabaseName() string {
 		switch mf.fieldType {
@@ -72,6 +79,17 @@ package cloudwave
 	}
 */
 func (mf *cwField) typeDatabaseName() string {
+	// The interval, calendar, JSON and CloudWave array types have no JDBC
+	// equivalent, their type is only known once a value has been read.
+	switch mf.fieldType {
+	case CLOUD_TYPE_INTS, CLOUD_TYPE_X_BYTES,
+		CLOUD_TYPE_DAY_TIME_INTERVAL, CLOUD_TYPE_YEAR_MONTH_INTERVAL, CLOUD_TYPE_TIME_INTERVAL,
+		CLOUD_TYPE_YEAR_MONTH, CLOUD_TYPE_YEAR_MONTH_DAY, CLOUD_TYPE_FISCAL_YEAR,
+		CLOUD_TYPE_FISCAL_QUARTER, CLOUD_TYPE_COMPACT_DATE,
+		CLOUD_TYPE_JSON_OBJECT, CLOUD_TYPE_JSON_ARRAY, CLOUD_TYPE_JSON_TEXT,
+		CLOUD_TYPE_JSON_BINARY, CLOUD_TYPE_JSON_KEYWORD, CLOUD_TYPE_JSON_BIGDECIMAL:
+		return getTypeName(byte(mf.fieldType))
+	}
 	switch mf.columnHeaderFieldType {
 	case BIT:
 		return "BOOLEAN"
@@ -365,6 +383,38 @@ func getTypeName(wisdomType byte) string {
 		return "VARBINARY"
 	case CLOUD_TYPE_VARCHAR:
 		return "VARCHAR"
+	case CLOUD_TYPE_DAY_TIME_INTERVAL:
+		return "DAY_TIME_INTERVAL"
+	case CLOUD_TYPE_YEAR_MONTH_INTERVAL:
+		return "YEAR_MONTH_INTERVAL"
+	case CLOUD_TYPE_TIME_INTERVAL:
+		return "TIME_INTERVAL"
+	case CLOUD_TYPE_YEAR_MONTH:
+		return "YEAR_MONTH"
+	case CLOUD_TYPE_YEAR_MONTH_DAY:
+		return "YEAR_MONTH_DAY"
+	case CLOUD_TYPE_FISCAL_YEAR:
+		return "FISCAL_YEAR"
+	case CLOUD_TYPE_FISCAL_QUARTER:
+		return "FISCAL_QUARTER"
+	case CLOUD_TYPE_COMPACT_DATE:
+		return "COMPACT_DATE"
+	case CLOUD_TYPE_INTS:
+		return "INTS"
+	case CLOUD_TYPE_X_BYTES:
+		return "X_BYTES"
+	case CLOUD_TYPE_JSON_OBJECT:
+		return "JSON_OBJECT"
+	case CLOUD_TYPE_JSON_ARRAY:
+		return "JSON_ARRAY"
+	case CLOUD_TYPE_JSON_TEXT:
+		return "JSON_TEXT"
+	case CLOUD_TYPE_JSON_BINARY:
+		return "JSON_BINARY"
+	case CLOUD_TYPE_JSON_KEYWORD:
+		return "JSON_KEYWORD"
+	case CLOUD_TYPE_JSON_BIGDECIMAL:
+		return "JSON_BIGDECIMAL"
 	}
 	return "OTHER"
 }
@@ -383,3 +433,137 @@ type cwField struct {
 	typeName              string
 	className             string
 }
+
+var (
+	scanTypeBytes             = reflect.TypeOf([]byte{})
+	scanTypeNullByte          = reflect.TypeOf(sql.NullByte{})
+	scanTypeNullInt32         = reflect.TypeOf(sql.NullInt32{})
+	scanTypeNullInt64         = reflect.TypeOf(sql.NullInt64{})
+	scanTypeNullFloat64       = reflect.TypeOf(sql.NullFloat64{})
+	scanTypeNullString        = reflect.TypeOf(sql.NullString{})
+	scanTypeNullTime          = reflect.TypeOf(sql.NullTime{})
+	scanTypeDayTimeInterval   = reflect.TypeOf(DayTimeInterval(0))
+	scanTypeYearMonthInterval = reflect.TypeOf(YearMonthInterval(0))
+	scanTypeYearMonth         = reflect.TypeOf(YearMonth{})
+	scanTypeFiscalQuarter     = reflect.TypeOf(FiscalQuarter{})
+	scanTypeBlob              = reflect.TypeOf(Blob{})
+	scanTypeClob              = reflect.TypeOf(Clob{})
+	scanTypeArray             = reflect.TypeOf(Array{})
+	scanTypeJSON              = reflect.TypeOf(json.RawMessage{})
+	scanTypeUnknown           = reflect.TypeOf(new(interface{})).Elem()
+)
+
+// classTypes maps the Java class names of the result header to CloudWave
+// types, for columns whose type is only known once a value has been read.
+var classTypes = map[string]byte{
+	"java.lang.Boolean":    CLOUD_TYPE_BOOLEAN,
+	"java.lang.Byte":       CLOUD_TYPE_INTEGER,
+	"java.lang.Short":      CLOUD_TYPE_INTEGER,
+	"java.lang.Integer":    CLOUD_TYPE_INTEGER,
+	"java.lang.Long":       CLOUD_TYPE_LONG,
+	"java.lang.Float":      CLOUD_TYPE_FLOAT,
+	"java.lang.Double":     CLOUD_TYPE_DOUBLE,
+	"java.lang.String":     CLOUD_TYPE_VARCHAR,
+	"java.math.BigDecimal": CLOUD_TYPE_BIG_DECIMAL,
+	"java.math.BigInteger": CLOUD_TYPE_BIG_INTEGER,
+	"java.sql.Date":        CLOUD_TYPE_DATE,
+	"java.sql.Time":        CLOUD_TYPE_TIME,
+	"java.sql.Timestamp":   CLOUD_TYPE_TIMESTAMP,
+	"java.sql.Blob":        CLOUD_TYPE_BLOB,
+	"java.sql.Clob":        CLOUD_TYPE_CLOB,
+	"[B":                   CLOUD_TYPE_VARBINARY,
+}
+
+// cloudType returns the CloudWave type of the column, falling back to the
+// Java class name of the header if the JDBC type has no CloudWave equivalent.
+func (mf *cwField) cloudType() byte {
+	tp := byte(mf.fieldType)
+	if tp == byte(CLOUD_TYPE_OTHER&0xff) {
+		if ct, ok := classTypes[mf.className]; ok {
+			return ct
+		}
+	}
+	return tp
+}
+
+// scanType returns the type of the values readRow returns for the column,
+// as nullable types where database/sql has one. LOB values are returned as
+// handles, or read into memory if eagerLOBs is set.
+func (mf *cwField) scanType(parseTime, eagerLOBs bool) reflect.Type {
+	switch mf.cloudType() {
+	case CLOUD_TYPE_BOOLEAN:
+		// BOOLEAN values are returned as 0 or 1
+		return scanTypeNullByte
+	case CLOUD_TYPE_INTEGER, CLOUD_TYPE_TINY_INTEGER:
+		return scanTypeNullInt32
+	case CLOUD_TYPE_LONG, CLOUD_TYPE_SMALL_INTEGER, CLOUD_TYPE_FISCAL_YEAR:
+		return scanTypeNullInt64
+	case CLOUD_TYPE_FLOAT, CLOUD_TYPE_DOUBLE:
+		return scanTypeNullFloat64
+	case CLOUD_TYPE_SINGLE_CHAR, CLOUD_TYPE_CHAR, CLOUD_TYPE_VARCHAR, CLOUD_TYPE_LONGVARCHAR,
+		CLOUD_TYPE_TINY_DECIMAL, CLOUD_TYPE_SMALL_DECIMAL, CLOUD_TYPE_BIG_DECIMAL,
+		CLOUD_TYPE_NUMBER, CLOUD_TYPE_BIG_INTEGER:
+		// decimals and big integers are returned as their text
+		return scanTypeNullString
+	case CLOUD_TYPE_SINGLE_BYTE, CLOUD_TYPE_BINARY, CLOUD_TYPE_VARBINARY, CLOUD_TYPE_LONGVARBINARY:
+		return scanTypeBytes
+	case CLOUD_TYPE_DATE, CLOUD_TYPE_TIME, CLOUD_TYPE_TIMESTAMP,
+		CLOUD_TYPE_YEAR_MONTH_DAY, CLOUD_TYPE_COMPACT_DATE:
+		if parseTime {
+			return scanTypeNullTime
+		}
+		return scanTypeNullString
+	case CLOUD_TYPE_DAY_TIME_INTERVAL, CLOUD_TYPE_TIME_INTERVAL:
+		return scanTypeDayTimeInterval
+	case CLOUD_TYPE_YEAR_MONTH_INTERVAL:
+		return scanTypeYearMonthInterval
+	case CLOUD_TYPE_YEAR_MONTH:
+		return scanTypeYearMonth
+	case CLOUD_TYPE_FISCAL_QUARTER:
+		return scanTypeFiscalQuarter
+	case CLOUD_TYPE_BLOB:
+		if eagerLOBs {
+			return scanTypeBytes
+		}
+		return scanTypeBlob
+	case CLOUD_TYPE_CLOB:
+		if eagerLOBs {
+			return scanTypeNullString
+		}
+		return scanTypeClob
+	case CLOUD_TYPE_ARRAY, CLOUD_TYPE_INTS, CLOUD_TYPE_X_BYTES:
+		return scanTypeArray
+	case CLOUD_TYPE_JSON_OBJECT, CLOUD_TYPE_JSON_ARRAY, CLOUD_TYPE_JSON_TEXT,
+		CLOUD_TYPE_JSON_BINARY, CLOUD_TYPE_JSON_KEYWORD, CLOUD_TYPE_JSON_BIGDECIMAL:
+		return scanTypeJSON
+	}
+	return scanTypeUnknown
+}
+
+// columnLength returns the maximum length of character and binary columns,
+// which the header sends as their precision. Character lengths count UTF-16
+// code units. LOB columns without a maximum length report math.MaxInt64.
+func (mf *cwField) columnLength() (int64, bool) {
+	switch mf.cloudType() {
+	case CLOUD_TYPE_CHAR, CLOUD_TYPE_VARCHAR, CLOUD_TYPE_LONGVARCHAR,
+		CLOUD_TYPE_BINARY, CLOUD_TYPE_VARBINARY, CLOUD_TYPE_LONGVARBINARY:
+		return int64(mf.length), true
+	case CLOUD_TYPE_BLOB, CLOUD_TYPE_CLOB:
+		if mf.length == 0 || mf.length == math.MaxInt32 {
+			return math.MaxInt64, true
+		}
+		return int64(mf.length), true
+	}
+	return 0, false
+}
+
+// precisionScale returns the precision and scale of decimal columns.
+func (mf *cwField) precisionScale() (int64, int64, bool) {
+	switch mf.cloudType() {
+	case CLOUD_TYPE_TINY_DECIMAL, CLOUD_TYPE_SMALL_DECIMAL, CLOUD_TYPE_BIG_DECIMAL, CLOUD_TYPE_NUMBER:
+		return int64(mf.length), int64(mf.colScale), true
+	case CLOUD_TYPE_BIG_INTEGER:
+		return int64(mf.length), 0, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,67 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestColumnTypes(t *testing.T) {
+	rows := &cwRows{rs: resultSet{columns: []cwField{
+		{name: "id", fieldType: CLOUD_TYPE_LONG, length: 19},
+		{name: "name", fieldType: CLOUD_TYPE_VARCHAR, length: 64},
+		{name: "price", fieldType: CLOUD_TYPE_BIG_DECIMAL, length: 10, colScale: 2},
+		{name: "doc", fieldType: CLOUD_TYPE_CLOB},
+		{name: "other", fieldType: fieldType(CLOUD_TYPE_OTHER & 0xff), className: "java.lang.Integer"},
+		{name: "created", fieldType: CLOUD_TYPE_TIMESTAMP},
+		{name: "ok", fieldType: CLOUD_TYPE_BOOLEAN},
+		{name: "data", fieldType: CLOUD_TYPE_BLOB},
+	}}}
+
+	scanTypes := []reflect.Type{scanTypeNullInt64, scanTypeNullString, scanTypeNullString,
+		scanTypeClob, scanTypeNullInt32, scanTypeNullString, scanTypeNullByte, scanTypeBlob}
+	for i, want := range scanTypes {
+		if got := rows.ColumnTypeScanType(i); got != want {
+			t.Errorf("column %d: scan type %v, want %v", i, got, want)
+		}
+	}
+	rows.eagerLOBs = true
+	if got := rows.ColumnTypeScanType(3); got != scanTypeNullString {
+		t.Errorf("expected %v for eager CLOBs, got %v", scanTypeNullString, got)
+	}
+	if got := rows.ColumnTypeScanType(7); got != scanTypeBytes {
+		t.Errorf("expected %v for eager BLOBs, got %v", scanTypeBytes, got)
+	}
+	rows.parseTime = true
+	if got := rows.ColumnTypeScanType(5); got != scanTypeNullTime {
+		t.Errorf("expected %v with parseTime, got %v", scanTypeNullTime, got)
+	}
+
+	if n, ok := rows.ColumnTypeLength(1); !ok || n != 64 {
+		t.Errorf("VARCHAR length %d, %v", n, ok)
+	}
+	if n, ok := rows.ColumnTypeLength(3); !ok || n != math.MaxInt64 {
+		t.Errorf("CLOB length %d, %v", n, ok)
+	}
+	if _, ok := rows.ColumnTypeLength(0); ok {
+		t.Error("expected no length for LONG")
+	}
+
+	if p, s, ok := rows.ColumnTypePrecisionScale(2); !ok || p != 10 || s != 2 {
+		t.Errorf("DECIMAL precision %d, scale %d, %v", p, s, ok)
+	}
+	if _, _, ok := rows.ColumnTypePrecisionScale(1); ok {
+		t.Error("expected no precision for VARCHAR")
+	}
+	if _, ok := rows.ColumnTypeNullable(0); ok {
+		t.Error("expected nullability to be unknown")
+	}
+}
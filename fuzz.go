@@ -16,9 +16,31 @@ import (
 
 func Fuzz(data []byte) int {
 	db, err := sql.Open("cloudwave", string(data))
-	if err != nil {
-		return 0
+	if err == nil {
+		db.Close()
 	}
-	db.Close()
-	return 1
+
+	// The packet decoders must reject malformed packets with an error and
+	// never panic.
+	mc := &cwConn{cfg: NewConfig()}
+	stmt := &cwStmt{mc: mc}
+	interesting := 0
+	if _, rows, err := stmt.parseResultSetHeader(data); err == nil && rows != nil {
+		interesting = 1
+	}
+	if _, err := stmt.parsePrepareResult(data); err == nil {
+		interesting = 1
+	}
+	mc.handleErrorPacket(data)
+
+	rows := &textRows{cwRows: cwRows{stmt: stmt}}
+	for b := data; len(b) > 0; {
+		_, _, _, n, err := rows.readObject(b)
+		if err != nil || n <= 0 {
+			break
+		}
+		interesting = 1
+		b = b[n:]
+	}
+	return interesting
 }
@@ -0,0 +1,194 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// Functions continuing an intercepted operation, passed to the hooks of an
+// Interceptor as next.
+type (
+	ConnectFunc func(ctx context.Context) (driver.Conn, error)
+	PrepareFunc func(ctx context.Context, query string) (driver.Stmt, error)
+	ExecFunc    func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error)
+	QueryFunc   func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error)
+	NextFunc    func(dest []driver.Value) error
+	BeginTxFunc func(ctx context.Context, opts driver.TxOptions) (driver.Tx, error)
+	TxFunc      func(ctx context.Context) error
+)
+
+// Interceptor hooks the operations of the connections of a Config. A hook
+// runs around the operation: it calls next to continue it, possibly with a
+// rewritten query or arguments, and returns its result. It can instead
+// return an error without calling next to reject the operation. Any hook
+// may be nil to leave the operation alone.
+//
+// The interceptors of Config.Interceptors are chained in order, the first
+// being the outermost. Queries with arguments are prepared unless
+// interpolateParams is set, so they run Prepare before Exec or Query; the
+// query given to those is then the prepared one and can't be rewritten.
+// A next returning driver.ErrSkip asks database/sql to retry the
+// operation differently, that error must be returned unchanged.
+type Interceptor struct {
+	// Connect runs around opening a connection, and opening it again
+	// after it was lost when the retry policy retries a statement. The
+	// connection returned to a reconnect is discarded: the driver keeps
+	// using the one database/sql holds.
+	Connect func(ctx context.Context, next ConnectFunc) (driver.Conn, error)
+
+	// Prepare runs around preparing a statement.
+	Prepare func(ctx context.Context, query string, next PrepareFunc) (driver.Stmt, error)
+
+	// Exec runs around executing a statement, directly or prepared.
+	Exec func(ctx context.Context, query string, args []driver.NamedValue, next ExecFunc) (driver.Result, error)
+
+	// Query runs around executing a query, directly or prepared.
+	Query func(ctx context.Context, query string, args []driver.NamedValue, next QueryFunc) (driver.Rows, error)
+
+	// Next runs around reading each row of a query into dest, and at its
+	// end, when next returns io.EOF. ctx is the context of the query.
+	Next func(ctx context.Context, query string, dest []driver.Value, next NextFunc) error
+
+	// BeginTx runs around starting a transaction.
+	BeginTx func(ctx context.Context, opts driver.TxOptions, next BeginTxFunc) (driver.Tx, error)
+
+	// Commit and Rollback run around ending a transaction. ctx is the
+	// context the transaction was started with.
+	Commit   func(ctx context.Context, next TxFunc) error
+	Rollback func(ctx context.Context, next TxFunc) error
+}
+
+// interceptorChain runs the hooks of its interceptors around an operation.
+type interceptorChain []Interceptor
+
+func (c interceptorChain) connect(ctx context.Context, final ConnectFunc) (driver.Conn, error) {
+	for len(c) > 0 && c[0].Connect == nil {
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		return final(ctx)
+	}
+	return c[0].Connect(ctx, func(ctx context.Context) (driver.Conn, error) {
+		return c[1:].connect(ctx, final)
+	})
+}
+
+func (c interceptorChain) prepare(ctx context.Context, query string, final PrepareFunc) (driver.Stmt, error) {
+	for len(c) > 0 && c[0].Prepare == nil {
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		return final(ctx, query)
+	}
+	return c[0].Prepare(ctx, query, func(ctx context.Context, query string) (driver.Stmt, error) {
+		return c[1:].prepare(ctx, query, final)
+	})
+}
+
+func (c interceptorChain) exec(ctx context.Context, query string, args []driver.NamedValue, final ExecFunc) (driver.Result, error) {
+	for len(c) > 0 && c[0].Exec == nil {
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		return final(ctx, query, args)
+	}
+	return c[0].Exec(ctx, query, args, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		return c[1:].exec(ctx, query, args, final)
+	})
+}
+
+func (c interceptorChain) query(ctx context.Context, query string, args []driver.NamedValue, final QueryFunc) (driver.Rows, error) {
+	for len(c) > 0 && c[0].Query == nil {
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		return final(ctx, query, args)
+	}
+	return c[0].Query(ctx, query, args, func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		return c[1:].query(ctx, query, args, final)
+	})
+}
+
+func (c interceptorChain) next(ctx context.Context, query string, dest []driver.Value, final NextFunc) error {
+	for len(c) > 0 && c[0].Next == nil {
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		return final(dest)
+	}
+	return c[0].Next(ctx, query, dest, func(dest []driver.Value) error {
+		return c[1:].next(ctx, query, dest, final)
+	})
+}
+
+func (c interceptorChain) beginTx(ctx context.Context, opts driver.TxOptions, final BeginTxFunc) (driver.Tx, error) {
+	for len(c) > 0 && c[0].BeginTx == nil {
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		return final(ctx, opts)
+	}
+	return c[0].BeginTx(ctx, opts, func(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+		return c[1:].beginTx(ctx, opts, final)
+	})
+}
+
+func (c interceptorChain) commit(ctx context.Context, final TxFunc) error {
+	for len(c) > 0 && c[0].Commit == nil {
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		return final(ctx)
+	}
+	return c[0].Commit(ctx, func(ctx context.Context) error {
+		return c[1:].commit(ctx, final)
+	})
+}
+
+func (c interceptorChain) rollback(ctx context.Context, final TxFunc) error {
+	for len(c) > 0 && c[0].Rollback == nil {
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		return final(ctx)
+	}
+	return c[0].Rollback(ctx, func(ctx context.Context) error {
+		return c[1:].rollback(ctx, final)
+	})
+}
+
+// intercepted is the state rows need to run the Next hooks.
+type intercepted struct {
+	ctx   context.Context
+	query string
+	chain interceptorChain
+}
+
+// skipsExec reports whether ExecContext and QueryContext return
+// driver.ErrSkip for args, so that database/sql prepares the statement.
+// Hooks aren't run for those calls.
+func (mc *cwConn) skipsExec(args []driver.NamedValue) bool {
+	if len(args) == 0 || mc.cfg.InterpolateParams {
+		return false
+	}
+	_, admin := args[0].Value.(AdminCommand)
+	return !admin
+}
+
+// intercept runs the Next hooks of chain when rows are read.
+func (rows *cwRows) intercept(ctx context.Context, query string, chain interceptorChain) {
+	for _, i := range chain {
+		if i.Next != nil {
+			rows.hooks = &intercepted{ctx: ctx, query: query, chain: chain}
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+func TestInterceptors(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Expect("/* tenant=a */ UPDATE t SET a = 1").WillReturnResult(2)
+	srv.Expect("/* tenant=a */ SELECT name FROM t WHERE id = ?").WithArgs(7).WillReturnRows(cloudwavetest.NewRows(
+		cloudwavetest.Column{Name: "name", Type: cloudwave.CLOUD_TYPE_VARCHAR},
+	).AddRow("x").AddRow("y"))
+
+	errDenied := errors.New("statement not allowed")
+	var calls []string
+	record := cloudwave.Interceptor{
+		Connect: func(ctx context.Context, next cloudwave.ConnectFunc) (driver.Conn, error) {
+			calls = append(calls, "connect")
+			return next(ctx)
+		},
+		Exec: func(ctx context.Context, query string, args []driver.NamedValue, next cloudwave.ExecFunc) (driver.Result, error) {
+			calls = append(calls, "exec "+query)
+			return next(ctx, query, args)
+		},
+		Query: func(ctx context.Context, query string, args []driver.NamedValue, next cloudwave.QueryFunc) (driver.Rows, error) {
+			calls = append(calls, "query "+query)
+			// the argument is rewritten
+			args[0].Value = int64(7)
+			return next(ctx, query, args)
+		},
+		Next: func(ctx context.Context, query string, dest []driver.Value, next cloudwave.NextFunc) error {
+			err := next(dest)
+			if err == nil {
+				calls = append(calls, "row")
+			} else if err == io.EOF {
+				calls = append(calls, "eof")
+			}
+			return err
+		},
+		BeginTx: func(ctx context.Context, opts driver.TxOptions, next cloudwave.BeginTxFunc) (driver.Tx, error) {
+			calls = append(calls, "begin")
+			return next(ctx, opts)
+		},
+		Rollback: func(ctx context.Context, next cloudwave.TxFunc) error {
+			calls = append(calls, "rollback")
+			return next(ctx)
+		},
+	}
+	tenant := func(query string) string { return "/* tenant=a */ " + query }
+	rewrite := cloudwave.Interceptor{
+		Prepare: func(ctx context.Context, query string, next cloudwave.PrepareFunc) (driver.Stmt, error) {
+			return next(ctx, tenant(query))
+		},
+		Exec: func(ctx context.Context, query string, args []driver.NamedValue, next cloudwave.ExecFunc) (driver.Result, error) {
+			if strings.HasPrefix(query, "DROP") {
+				return nil, errDenied
+			}
+			return next(ctx, tenant(query), args)
+		},
+	}
+
+	cfg, err := cloudwave.ParseDSN(srv.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Interceptors = []cloudwave.Interceptor{record, rewrite}
+	connector, err := cloudwave.NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	if _, err = db.Exec("UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("DROP TABLE t"); err != errDenied {
+		t.Errorf("got error %v, want %v", err, errDenied)
+	}
+	rows, err := db.Query("SELECT name FROM t WHERE id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "x,y" {
+		t.Errorf("got names %v", names)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"connect",
+		"exec UPDATE t SET a = 1",
+		"exec DROP TABLE t",
+		// the query with an argument was prepared, and rewritten by Prepare
+		"query /* tenant=a */ SELECT name FROM t WHERE id = ?",
+		"row", "row", "eof",
+		"begin", "rollback",
+	}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Errorf("got calls\n%q\nwant\n%q", calls, want)
+	}
+	if err = srv.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,110 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// placeholders returns the offsets of the ? placeholders of query. Question
+// marks in string literals, quoted identifiers and comments are skipped.
+func placeholders(query string) []int {
+	var pos []int
+	l := sqlLexer{query: query}
+	for tok := l.next(); tok.kind != tokEOF; tok = l.next() {
+		if tok.kind == tokPunct && tok.text == "?" {
+			pos = append(pos, tok.pos)
+		}
+	}
+	return pos
+}
+
+// appendLiteral appends arg as a CloudWave SQL literal. Strings are quoted
+// with embedded quotes doubled, as the server doesn't treat backslashes as
+// escapes. It returns driver.ErrSkip for values without a literal form, which
+// are then bound by a prepared statement.
+func appendLiteral(buf []byte, arg driver.Value, loc *time.Location) ([]byte, error) {
+	switch v := arg.(type) {
+	case nil:
+		return append(buf, "NULL"...), nil
+	case int64:
+		return strconv.AppendInt(buf, v, 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return buf, driver.ErrSkip
+		}
+		return strconv.AppendFloat(buf, v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return append(buf, "TRUE"...), nil
+		}
+		return append(buf, "FALSE"...), nil
+	case time.Time:
+		if loc != nil {
+			v = v.In(loc)
+		}
+		if year := v.Year(); year < 1 || year > 9999 {
+			return buf, fmt.Errorf("cloudwave: year %d of %v is out of range", year, v)
+		}
+		buf = append(buf, "TIMESTAMP '"...)
+		buf = v.AppendFormat(buf, "2006-01-02 15:04:05.999999999")
+		return append(buf, '\''), nil
+	case string:
+		return appendQuoted(buf, v)
+	case json.RawMessage:
+		return appendQuoted(buf, string(v))
+	case []byte:
+		if v == nil {
+			return append(buf, "NULL"...), nil
+		}
+		buf = append(buf, "X'"...)
+		const hex = "0123456789ABCDEF"
+		for _, b := range v {
+			buf = append(buf, hex[b>>4], hex[b&0x0f])
+		}
+		return append(buf, '\''), nil
+	case *big.Int:
+		return v.Append(buf, 10), nil
+	case *big.Rat:
+		d, exact := decimalFromRat(v, -1)
+		if !exact {
+			// rounded to the scale of the column when bound
+			return buf, driver.ErrSkip
+		}
+		return append(buf, d.String()...), nil
+	case Decimal:
+		return append(buf, v.String()...), nil
+	case *Decimal:
+		return append(buf, v.String()...), nil
+	}
+	return buf, driver.ErrSkip
+}
+
+func appendQuoted(buf []byte, s string) ([]byte, error) {
+	if !utf8.ValidString(s) {
+		return buf, errInvalidUTF8
+	}
+	buf = append(buf, '\'')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' {
+			buf = append(buf, '\'')
+		}
+		buf = append(buf, s[i])
+	}
+	return append(buf, '\''), nil
+}
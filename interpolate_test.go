@@ -0,0 +1,118 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newInterpolateConn() *cwConn {
+	cfg := NewConfig()
+	cfg.InterpolateParams = true
+	return &cwConn{
+		buf:              newBuffer(nil),
+		cfg:              cfg,
+		maxAllowedPacket: defaultMaxAllowedPacket,
+	}
+}
+
+func TestInterpolateParams(t *testing.T) {
+	mc := newInterpolateConn()
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	tests := []struct {
+		arg  driver.Value
+		want string
+	}{
+		{nil, "NULL"},
+		{int64(-42), "-42"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{1.5, "1.5"},
+		{true, "TRUE"},
+		{false, "FALSE"},
+		{ts, "TIMESTAMP '2023-01-02 03:04:05.6'"},
+		{time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), "TIMESTAMP '2023-01-02 00:00:00'"},
+		{"plain", "'plain'"},
+		{"it's", "'it''s'"},
+		{`back\slash`, `'back\slash'`},
+		{"'; DROP TABLE t; --", "'''; DROP TABLE t; --'"},
+		{"line\nbreak\x00", "'line\nbreak\x00'"},
+		{"h\U0001F600", "'h\U0001F600'"},
+		{json.RawMessage(`{"a":"b'c"}`), `'{"a":"b''c"}'`},
+		{[]byte{0x00, 0xab, '\''}, "X'00AB27'"},
+		{[]byte(nil), "NULL"},
+		{big.NewInt(-12345678901234), "-12345678901234"},
+		{big.NewRat(5, 4), "1.25"},
+		{NewDecimalFromInt64(1250, 2), "12.50"},
+	}
+	for _, tt := range tests {
+		got, err := mc.interpolateParams("SELECT ?", []driver.Value{tt.arg})
+		if err != nil {
+			t.Errorf("%#v: %v", tt.arg, err)
+		} else if got != "SELECT "+tt.want {
+			t.Errorf("%#v: got %q, want %q", tt.arg, got, "SELECT "+tt.want)
+		}
+	}
+}
+
+func TestInterpolateParamsSkip(t *testing.T) {
+	mc := newInterpolateConn()
+	for _, args := range [][]driver.Value{
+		{big.NewRat(1, 3)},
+		{math.NaN()},
+		{[]int64{1, 2}},
+		{int64(1), int64(2)},
+	} {
+		if _, err := mc.interpolateParams("SELECT ?", args); err != driver.ErrSkip {
+			t.Errorf("%#v: expected driver.ErrSkip, got %v", args, err)
+		}
+	}
+	if _, err := mc.interpolateParams("SELECT ?", []driver.Value{"\xff"}); err == nil || err == driver.ErrSkip {
+		t.Errorf("expected invalid UTF-8 error, got %v", err)
+	}
+	if _, err := mc.interpolateParams("SELECT ?", []driver.Value{time.Time{}.AddDate(-1, 0, 0)}); err == nil || err == driver.ErrSkip {
+		t.Errorf("expected year out of range error, got %v", err)
+	}
+
+	// Without interpolateParams, arguments are bound by a prepared statement.
+	mc.cfg.InterpolateParams = false
+	if _, err := mc.Exec("UPDATE t SET a = ?", []driver.Value{int64(1)}); err != driver.ErrSkip {
+		t.Errorf("Exec: expected driver.ErrSkip, got %v", err)
+	}
+	if _, err := mc.Query("SELECT ?", []driver.Value{int64(1)}); err != driver.ErrSkip {
+		t.Errorf("Query: expected driver.ErrSkip, got %v", err)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"SELECT ?, ?", []int{7, 10}},
+		{"SELECT '?', ?", []int{12}},
+		{"SELECT 'it''s?', ?", []int{17}},
+		{`SELECT "a?b", ?`, []int{14}},
+		{"SELECT ? -- why?\n, ?", []int{7, 19}},
+		{"SELECT /* ? */ ?", []int{15}},
+		{"SELECT 1 - ?", []int{11}},
+		{"SELECT 1 /* ?", nil},
+		{"SELECT '?", nil},
+	}
+	for _, tt := range tests {
+		if got := placeholders(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
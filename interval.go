@@ -0,0 +1,366 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Wire formats of the interval and calendar types, see JAVA JDBC
+// ObjectConverter.java:
+//
+//	DAY_TIME_INTERVAL, TIME_INTERVAL  int64 milliseconds
+//	YEAR_MONTH_INTERVAL               int32 months
+//	YEAR_MONTH                        int32 year, int32 month (1-12)
+//	FISCAL_YEAR                       int32 year
+//	FISCAL_QUARTER                    int32 year, int32 quarter (1-4)
+//
+// YEAR_MONTH_DAY and COMPACT_DATE use the DATE encoding and are handled by
+// readTemporal and appendTemporal.
+
+// DayTimeInterval is a CloudWave DAY_TIME_INTERVAL or TIME_INTERVAL value.
+// It is a time.Duration with millisecond precision on the wire.
+type DayTimeInterval time.Duration
+
+// Duration returns d as a time.Duration.
+func (d DayTimeInterval) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String formats d as "[-]D HH:MM:SS[.fff]".
+func (d DayTimeInterval) String() string {
+	v := time.Duration(d)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	day := 24 * time.Hour
+	s := fmt.Sprintf("%s%d %02d:%02d:%02d", sign, v/day, v%day/time.Hour, v%time.Hour/time.Minute, v%time.Minute/time.Second)
+	if ms := v % time.Second / time.Millisecond; ms != 0 {
+		s += fmt.Sprintf(".%03d", ms)
+	}
+	return s
+}
+
+// Scan implements the sql.Scanner interface.
+func (d *DayTimeInterval) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case DayTimeInterval:
+		*d = v
+		return nil
+	case int64:
+		*d = DayTimeInterval(v)
+		return nil
+	case string:
+		return d.parse(v)
+	case []byte:
+		return d.parse(string(v))
+	}
+	return fmt.Errorf("cloudwave: can't scan %T into DayTimeInterval", value)
+}
+
+// Value implements the driver.Valuer interface.
+func (d DayTimeInterval) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// parse accepts "[-]D HH:MM:SS[.fff]", "[-]HH:MM:SS[.fff]" and the
+// time.ParseDuration format.
+func (d *DayTimeInterval) parse(s string) error {
+	if v, err := time.ParseDuration(s); err == nil {
+		*d = DayTimeInterval(v)
+		return nil
+	}
+	str := s
+	neg := strings.HasPrefix(str, "-")
+	if neg {
+		str = str[1:]
+	}
+	var days int64
+	if i := strings.IndexByte(str, ' '); i >= 0 {
+		var err error
+		if days, err = strconv.ParseInt(str[:i], 10, 32); err != nil || days < 0 {
+			return fmt.Errorf("cloudwave: invalid DayTimeInterval %q", s)
+		}
+		str = str[i+1:]
+	}
+	t, err := time.Parse("15:04:05.999999999", str)
+	if err != nil {
+		return fmt.Errorf("cloudwave: invalid DayTimeInterval %q", s)
+	}
+	v := time.Duration(days)*24*time.Hour + t.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC))
+	if neg {
+		v = -v
+	}
+	*d = DayTimeInterval(v)
+	return nil
+}
+
+// YearMonthInterval is a CloudWave YEAR_MONTH_INTERVAL value, counted in
+// months.
+type YearMonthInterval int32
+
+// NewYearMonthInterval returns the interval of the given years and months.
+func NewYearMonthInterval(years, months int) YearMonthInterval {
+	return YearMonthInterval(years*12 + months)
+}
+
+// Years returns the whole years of i.
+func (i YearMonthInterval) Years() int {
+	return int(i) / 12
+}
+
+// Months returns the months of i not making up a whole year.
+func (i YearMonthInterval) Months() int {
+	return int(i) % 12
+}
+
+// String formats i as "[-]Y-M".
+func (i YearMonthInterval) String() string {
+	v := int(i)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d-%d", sign, v/12, v%12)
+}
+
+// Scan implements the sql.Scanner interface.
+func (i *YearMonthInterval) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case YearMonthInterval:
+		*i = v
+		return nil
+	case int64:
+		*i = YearMonthInterval(v)
+		return nil
+	case string:
+		return i.parse(v)
+	case []byte:
+		return i.parse(string(v))
+	}
+	return fmt.Errorf("cloudwave: can't scan %T into YearMonthInterval", value)
+}
+
+// Value implements the driver.Valuer interface.
+func (i YearMonthInterval) Value() (driver.Value, error) {
+	return i.String(), nil
+}
+
+func (i *YearMonthInterval) parse(s string) error {
+	str := s
+	neg := strings.HasPrefix(str, "-")
+	if neg {
+		str = str[1:]
+	}
+	ys, ms, ok := strings.Cut(str, "-")
+	years, err1 := strconv.Atoi(ys)
+	months, err2 := strconv.Atoi(ms)
+	if !ok || err1 != nil || err2 != nil || years < 0 || months < 0 || months > 11 {
+		return fmt.Errorf("cloudwave: invalid YearMonthInterval %q", s)
+	}
+	v := NewYearMonthInterval(years, months)
+	if neg {
+		v = -v
+	}
+	*i = v
+	return nil
+}
+
+// YearMonth is a CloudWave YEAR_MONTH value.
+type YearMonth struct {
+	Year  int
+	Month time.Month
+}
+
+// String formats ym as "2006-01".
+func (ym YearMonth) String() string {
+	return fmt.Sprintf("%04d-%02d", ym.Year, int(ym.Month))
+}
+
+// Scan implements the sql.Scanner interface.
+func (ym *YearMonth) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case YearMonth:
+		*ym = v
+		return nil
+	case time.Time:
+		ym.Year, ym.Month = v.Year(), v.Month()
+		return nil
+	case string:
+		return ym.parse(v)
+	case []byte:
+		return ym.parse(string(v))
+	}
+	return fmt.Errorf("cloudwave: can't scan %T into YearMonth", value)
+}
+
+// Value implements the driver.Valuer interface.
+func (ym YearMonth) Value() (driver.Value, error) {
+	return ym.String(), nil
+}
+
+func (ym *YearMonth) parse(s string) error {
+	t, err := time.Parse("2006-01", s)
+	if err != nil {
+		return fmt.Errorf("cloudwave: invalid YearMonth %q", s)
+	}
+	ym.Year, ym.Month = t.Year(), t.Month()
+	return nil
+}
+
+// FiscalQuarter is a CloudWave FISCAL_QUARTER value.
+type FiscalQuarter struct {
+	Year    int
+	Quarter int
+}
+
+// String formats q as "2006-Q1".
+func (q FiscalQuarter) String() string {
+	return fmt.Sprintf("%04d-Q%d", q.Year, q.Quarter)
+}
+
+// Scan implements the sql.Scanner interface.
+func (q *FiscalQuarter) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case FiscalQuarter:
+		*q = v
+		return nil
+	case string:
+		return q.parse(v)
+	case []byte:
+		return q.parse(string(v))
+	}
+	return fmt.Errorf("cloudwave: can't scan %T into FiscalQuarter", value)
+}
+
+// Value implements the driver.Valuer interface.
+func (q FiscalQuarter) Value() (driver.Value, error) {
+	return q.String(), nil
+}
+
+// parse accepts "2006-Q1" and "2006Q1".
+func (q *FiscalQuarter) parse(s string) error {
+	i := strings.LastIndexAny(s, "Qq")
+	if i > 0 && s[i-1] == '-' {
+		i--
+	}
+	if i <= 0 {
+		return fmt.Errorf("cloudwave: invalid FiscalQuarter %q", s)
+	}
+	year, err1 := strconv.Atoi(s[:i])
+	quarter, err2 := strconv.Atoi(strings.TrimLeft(s[i:], "-Qq"))
+	if err1 != nil || err2 != nil || quarter < 1 || quarter > 4 {
+		return fmt.Errorf("cloudwave: invalid FiscalQuarter %q", s)
+	}
+	q.Year, q.Quarter = year, quarter
+	return nil
+}
+
+// readInterval decodes a value of one of the interval or calendar types.
+func readInterval(b []byte, tp byte) (driver.Value, int, error) {
+	switch tp {
+	case CLOUD_TYPE_DAY_TIME_INTERVAL, CLOUD_TYPE_TIME_INTERVAL:
+		if len(b) < 8 {
+			return nil, 0, ErrMalformPkt
+		}
+		ms := int64(binary.BigEndian.Uint64(b))
+		return DayTimeInterval(time.Duration(ms) * time.Millisecond), 8, nil
+	case CLOUD_TYPE_YEAR_MONTH_INTERVAL:
+		if len(b) < 4 {
+			return nil, 0, ErrMalformPkt
+		}
+		return YearMonthInterval(int32(binary.BigEndian.Uint32(b))), 4, nil
+	case CLOUD_TYPE_FISCAL_YEAR:
+		if len(b) < 4 {
+			return nil, 0, ErrMalformPkt
+		}
+		return int64(int32(binary.BigEndian.Uint32(b))), 4, nil
+	case CLOUD_TYPE_YEAR_MONTH:
+		if len(b) < 8 {
+			return nil, 0, ErrMalformPkt
+		}
+		return YearMonth{
+			Year:  int(int32(binary.BigEndian.Uint32(b))),
+			Month: time.Month(int32(binary.BigEndian.Uint32(b[4:]))),
+		}, 8, nil
+	case CLOUD_TYPE_FISCAL_QUARTER:
+		if len(b) < 8 {
+			return nil, 0, ErrMalformPkt
+		}
+		return FiscalQuarter{
+			Year:    int(int32(binary.BigEndian.Uint32(b))),
+			Quarter: int(int32(binary.BigEndian.Uint32(b[4:]))),
+		}, 8, nil
+	}
+	return nil, 0, fmt.Errorf("cloudwave: %s is not an interval type", getTypeName(tp))
+}
+
+// appendInterval encodes arg for a column of one of the interval or calendar
+// types. Integers are taken as time.Duration for DAY_TIME_INTERVAL and
+// TIME_INTERVAL, as months for YEAR_MONTH_INTERVAL and as the year for
+// FISCAL_YEAR.
+func appendInterval(data []byte, tp byte, arg driver.Value) (int, error) {
+	switch tp {
+	case CLOUD_TYPE_DAY_TIME_INTERVAL, CLOUD_TYPE_TIME_INTERVAL:
+		var d DayTimeInterval
+		if err := d.Scan(arg); err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint64(data, uint64(time.Duration(d)/time.Millisecond))
+		return 8, nil
+	case CLOUD_TYPE_YEAR_MONTH_INTERVAL:
+		var i YearMonthInterval
+		if err := i.Scan(arg); err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint32(data, uint32(i))
+		return 4, nil
+	case CLOUD_TYPE_FISCAL_YEAR:
+		var year int64
+		switch v := arg.(type) {
+		case int64:
+			year = v
+		case string:
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil {
+				return 0, fmt.Errorf("cloudwave: invalid FISCAL_YEAR %q", v)
+			}
+			year = n
+		default:
+			return 0, fmt.Errorf("cloudwave: can't convert %T to FISCAL_YEAR", arg)
+		}
+		binary.BigEndian.PutUint32(data, uint32(int32(year)))
+		return 4, nil
+	case CLOUD_TYPE_YEAR_MONTH:
+		var ym YearMonth
+		if err := ym.Scan(arg); err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint32(data, uint32(int32(ym.Year)))
+		binary.BigEndian.PutUint32(data[4:], uint32(int32(ym.Month)))
+		return 8, nil
+	case CLOUD_TYPE_FISCAL_QUARTER:
+		var q FiscalQuarter
+		if err := q.Scan(arg); err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint32(data, uint32(int32(q.Year)))
+		binary.BigEndian.PutUint32(data[4:], uint32(int32(q.Quarter)))
+		return 8, nil
+	}
+	return 0, fmt.Errorf("cloudwave: %s is not an interval type", getTypeName(tp))
+}
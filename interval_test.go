@@ -0,0 +1,86 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var intervalTests = []struct {
+	tp   byte
+	wire []byte
+	v    interface{}
+	text string
+}{
+	{CLOUD_TYPE_DAY_TIME_INTERVAL, []byte{0, 0, 0, 0, 0x05, 0x97, 0x07, 0xf2}, DayTimeInterval(26*time.Hour + 3*time.Minute + 4*time.Second + 50*time.Millisecond), "1 02:03:04.050"},
+	{CLOUD_TYPE_TIME_INTERVAL, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 0xdb, 0x08}, DayTimeInterval(-75 * time.Second), "-0 00:01:15"},
+	{CLOUD_TYPE_YEAR_MONTH_INTERVAL, []byte{0, 0, 0, 0x1d}, YearMonthInterval(29), "2-5"},
+	{CLOUD_TYPE_YEAR_MONTH_INTERVAL, []byte{0xff, 0xff, 0xff, 0xf4}, YearMonthInterval(-12), "-1-0"},
+	{CLOUD_TYPE_YEAR_MONTH, []byte{0, 0, 0x07, 0xe7, 0, 0, 0, 0x07}, YearMonth{2023, time.July}, "2023-07"},
+	{CLOUD_TYPE_FISCAL_QUARTER, []byte{0, 0, 0x07, 0xe7, 0, 0, 0, 0x03}, FiscalQuarter{2023, 3}, "2023-Q3"},
+	{CLOUD_TYPE_FISCAL_YEAR, []byte{0, 0, 0x07, 0xe7}, int64(2023), "2023"},
+}
+
+func TestIntervalRoundTrip(t *testing.T) {
+	for i, tt := range intervalTests {
+		v, n, err := readInterval(tt.wire, tt.tp)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if n != len(tt.wire) || v != tt.v {
+			t.Errorf("%d: got %v (%d bytes), want %v", i, v, n, tt.v)
+		}
+
+		for _, arg := range []interface{}{tt.v, tt.text} {
+			if valuer, ok := arg.(interface{ Value() (interface{}, error) }); ok {
+				arg, _ = valuer.Value()
+			}
+			buf := make([]byte, 8)
+			n, err := appendInterval(buf, tt.tp, arg)
+			if err != nil {
+				t.Fatalf("%d: %T: %v", i, arg, err)
+			}
+			if !bytes.Equal(buf[:n], tt.wire) {
+				t.Errorf("%d: %T: got %x, want %x", i, arg, buf[:n], tt.wire)
+			}
+		}
+	}
+}
+
+func TestDayTimeIntervalScan(t *testing.T) {
+	want := DayTimeInterval(90 * time.Minute)
+	for _, src := range []interface{}{"0 01:30:00", "01:30:00", "1h30m", []byte("1h30m"), int64(90 * time.Minute)} {
+		var d DayTimeInterval
+		if err := d.Scan(src); err != nil {
+			t.Errorf("%v: %v", src, err)
+		} else if d != want || d.Duration() != 90*time.Minute {
+			t.Errorf("%v: got %v, want %v", src, d, want)
+		}
+	}
+	var d DayTimeInterval
+	if err := d.Scan("1 25:00:00"); err == nil {
+		t.Error("expected error for invalid interval")
+	}
+}
+
+func TestIntervalScanInvalid(t *testing.T) {
+	var i YearMonthInterval
+	var ym YearMonth
+	var q FiscalQuarter
+	for _, err := range []error{i.Scan("1-12"), i.Scan(1.5), ym.Scan("2023-13"), q.Scan("2023-Q5"), q.Scan("Q1")} {
+		if err == nil {
+			t.Error("expected error")
+		}
+	}
+	if err := q.Scan("2023Q1"); err != nil || q != (FiscalQuarter{2023, 1}) {
+		t.Errorf("got %v, %v", q, err)
+	}
+}
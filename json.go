@@ -0,0 +1,243 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// JSON binds a Go value to a JSON column, or scans a JSON column into one.
+//
+// When binding, V is marshalled with encoding/json; json.RawMessage, []byte
+// and string values must already hold valid JSON text. When scanning, the
+// JSON text is unmarshalled into V, which must then be a pointer; if V is
+// nil, it is set to the json.RawMessage read.
+type JSON struct {
+	V interface{}
+}
+
+// Value implements the driver.Valuer interface.
+func (j JSON) Value() (driver.Value, error) {
+	var b []byte
+	switch v := j.V.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		b = v
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	if !json.Valid(b) {
+		return nil, errInvalidJSON
+	}
+	return b, nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (j *JSON) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		b = []byte("null")
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cloudwave: can't scan %T into JSON", src)
+	}
+	if j.V == nil {
+		if src != nil {
+			j.V = json.RawMessage(append([]byte(nil), b...))
+		}
+		return nil
+	}
+	return json.Unmarshal(b, j.V)
+}
+
+var errInvalidJSON = errors.New("cloudwave: invalid JSON text")
+
+// isJSONType reports whether tp is one of the CLOUD_TYPE_JSON_* types.
+func isJSONType(tp byte) bool {
+	return tp >= CLOUD_TYPE_JSON_ARRAY && tp <= CLOUD_TYPE_JSON_BIGDECIMAL
+}
+
+// appendJSON encodes arg for a JSON column as a 4 byte length followed by
+// the UTF-8 JSON text, which the server parses into the column type.
+func appendJSON(data []byte, arg driver.Value) (int, error) {
+	var b []byte
+	switch v := arg.(type) {
+	case json.RawMessage:
+		b = v
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case int64:
+		b = strconv.AppendInt(nil, v, 10)
+	case uint64:
+		b = strconv.AppendUint(nil, v, 10)
+	case float64:
+		b = strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		b = strconv.AppendBool(nil, v)
+	default:
+		return 0, fmt.Errorf("cloudwave: can't convert %T to JSON", arg)
+	}
+	if !json.Valid(b) {
+		return 0, errInvalidJSON
+	}
+	if len(data) < 4+len(b) {
+		return 0, ErrPktTooLarge
+	}
+	binary.BigEndian.PutUint32(data, uint32(len(b)))
+	copy(data[4:], b)
+	return 4 + len(b), nil
+}
+
+// readJSON decodes a value of one of the JSON types into JSON text.
+//
+// JSON_OBJECT is an int32 member count followed by the members, each a
+// length-prefixed UTF-8 key and a value as written by readObject. JSON_ARRAY
+// is an int32 element count followed by the elements. JSON_TEXT and
+// JSON_KEYWORD are length-prefixed UTF-8 strings, JSON_BINARY is
+// length-prefixed bytes and JSON_BIGDECIMAL uses the BIG_DECIMAL encoding.
+func (rows *textRows) readJSON(b []byte, tp byte) ([]byte, int, error) {
+	r := NewPacketReader(b, getTypeName(tp))
+	switch tp {
+	case CLOUD_TYPE_JSON_OBJECT, CLOUD_TYPE_JSON_ARRAY:
+		count := r.Count(1)
+		open, close := byte('['), byte(']')
+		if tp == CLOUD_TYPE_JSON_OBJECT {
+			open, close = '{', '}'
+		}
+		dst := []byte{open}
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			r.SetContext(fmt.Sprintf("%s member %d", getTypeName(tp), i))
+			var err error
+			if tp == CLOUD_TYPE_JSON_OBJECT {
+				key := r.String()
+				if r.Err() != nil {
+					return nil, 0, r.Err()
+				}
+				if dst, err = appendJSONString(dst, key); err != nil {
+					return nil, 0, err
+				}
+				dst = append(dst, ':')
+			}
+			v, vtp, _, n, err := rows.readObject(r.Rest())
+			if r.advance(n, err); r.Err() != nil {
+				return nil, 0, r.Err()
+			}
+			if dst, err = appendJSONValue(dst, v, vtp); err != nil {
+				return nil, 0, err
+			}
+		}
+		return append(dst, close), r.Pos(), r.Err()
+
+	case CLOUD_TYPE_JSON_TEXT, CLOUD_TYPE_JSON_KEYWORD, CLOUD_TYPE_JSON_BINARY:
+		s := r.String()
+		if r.Err() != nil {
+			return nil, 0, r.Err()
+		}
+		var dst []byte
+		var err error
+		if tp == CLOUD_TYPE_JSON_BINARY {
+			dst, err = json.Marshal([]byte(s))
+		} else {
+			dst, err = appendJSONString(nil, s)
+		}
+		return dst, r.Pos(), err
+
+	case CLOUD_TYPE_JSON_BIGDECIMAL:
+		d, n, err := readDecimal(b, CLOUD_TYPE_BIG_DECIMAL)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []byte(d.String()), n, nil
+	}
+	return nil, 0, fmt.Errorf("cloudwave: %s is not a JSON type", getTypeName(tp))
+}
+
+func appendJSONString(dst []byte, s string) ([]byte, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, b...), nil
+}
+
+// appendJSONValue appends the JSON representation of v, a value of type tp
+// as returned by readObject.
+func appendJSONValue(dst []byte, v driver.Value, tp byte) ([]byte, error) {
+	if v == nil {
+		return append(dst, "null"...), nil
+	}
+	switch {
+	case isJSONType(tp):
+		return append(dst, v.([]byte)...), nil
+	case tp == CLOUD_TYPE_BOOLEAN:
+		return strconv.AppendBool(dst, v.(byte) != 0), nil
+	case tp == CLOUD_TYPE_TINY_DECIMAL, tp == CLOUD_TYPE_SMALL_DECIMAL, tp == CLOUD_TYPE_BIG_DECIMAL:
+		return append(dst, v.(string)...), nil
+	}
+	switch v := v.(type) {
+	case string:
+		return appendJSONString(dst, v)
+	case int32:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(dst, v, 10), nil
+	case float32:
+		return appendJSONNumber(dst, v)
+	case float64:
+		return appendJSONNumber(dst, v)
+	case []byte:
+		switch tp {
+		case CLOUD_TYPE_BINARY, CLOUD_TYPE_VARBINARY, CLOUD_TYPE_SINGLE_BYTE, CLOUD_TYPE_LONGVARBINARY:
+			// marshalled as base64 below
+		default:
+			return appendJSONString(dst, string(v))
+		}
+	case time.Time:
+		return appendJSONString(dst, v.Format(time.RFC3339Nano))
+	case fmt.Stringer:
+		return appendJSONString(dst, v.String())
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, b...), nil
+}
+
+func appendJSONNumber(dst []byte, v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, b...), nil
+}
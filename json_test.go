@@ -0,0 +1,109 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func jsonFixture(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestReadJSON(t *testing.T) {
+	nested := jsonFixture(
+		[]byte{0, 0, 0, 3}, // 3 elements
+		[]byte{0, CLOUD_TYPE_INTEGER, 0, 0, 0, 7},
+		[]byte{1}, // null
+		[]byte{0, CLOUD_TYPE_JSON_TEXT, 0, 0, 0, 2, 'h', 'i'}, // "hi"
+	)
+	object := jsonFixture(
+		[]byte{0, 0, 0, 4}, // 4 members
+		[]byte{0, 0, 0, 1, 'a'}, []byte{0, CLOUD_TYPE_VARCHAR, 0, 0, 0, 2, 0, 'x', 0, '"'},
+		[]byte{0, 0, 0, 1, 'b'}, []byte{0, CLOUD_TYPE_BOOLEAN, 1},
+		[]byte{0, 0, 0, 1, 'c'}, []byte{0, CLOUD_TYPE_JSON_ARRAY}, nested,
+		[]byte{0, 0, 0, 1, 'd'}, []byte{0, CLOUD_TYPE_JSON_BIGDECIMAL, 0, 0, 0, 0, 0, 0, 0, 0x04, 0xd2, 2},
+	)
+	tests := []struct {
+		tp   byte
+		wire []byte
+		want string
+	}{
+		{CLOUD_TYPE_JSON_OBJECT, object, `{"a":"x\"","b":true,"c":[7,null,"hi"],"d":12.34}`},
+		{CLOUD_TYPE_JSON_ARRAY, []byte{0, 0, 0, 0}, `[]`},
+		{CLOUD_TYPE_JSON_KEYWORD, []byte{0, 0, 0, 3, 'a', '\n', 'b'}, `"a\nb"`},
+		{CLOUD_TYPE_JSON_BINARY, []byte{0, 0, 0, 3, 1, 2, 3}, `"AQID"`},
+	}
+	rows := &textRows{}
+	for i, tt := range tests {
+		v, n, err := rows.readJSON(tt.wire, tt.tp)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if n != len(tt.wire) {
+			t.Errorf("%d: read %d bytes, want %d", i, n, len(tt.wire))
+		}
+		if string(v) != tt.want || !json.Valid(v) {
+			t.Errorf("%d: got %s, want %s", i, v, tt.want)
+		}
+	}
+}
+
+func TestJSONValuer(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{map[string]interface{}{"k": []int{1, 2}}, `{"k":[1,2]}`},
+		{point{1, 2}, `{"X":1,"Y":2}`},
+		{json.RawMessage(`{"a":1}`), `{"a":1}`},
+		{`[true]`, `[true]`},
+	}
+	for i, tt := range tests {
+		v, err := converter{}.ConvertValue(JSON{tt.v})
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		buf := make([]byte, 64)
+		n, err := appendJSON(buf, v)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if got := string(buf[4:n]); got != tt.want {
+			t.Errorf("%d: got %s, want %s", i, got, tt.want)
+		}
+	}
+
+	if _, err := (JSON{`{"a":`}).Value(); err != errInvalidJSON {
+		t.Errorf("expected errInvalidJSON, got %v", err)
+	}
+	if _, err := appendJSON(make([]byte, 64), "not json"); err != errInvalidJSON {
+		t.Errorf("expected errInvalidJSON, got %v", err)
+	}
+}
+
+func TestJSONScan(t *testing.T) {
+	var m map[string]int
+	if err := (&JSON{&m}).Scan([]byte(`{"a":1}`)); err != nil || m["a"] != 1 {
+		t.Errorf("got %v, %v", m, err)
+	}
+
+	var j JSON
+	if err := j.Scan([]byte(`[1]`)); err != nil {
+		t.Fatal(err)
+	}
+	if raw, ok := j.V.(json.RawMessage); !ok || string(raw) != `[1]` {
+		t.Errorf("got %#v", j.V)
+	}
+}
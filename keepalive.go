@@ -0,0 +1,60 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"context"
+	"time"
+)
+
+// A connection is idle from the time database/sql returns it to the pool,
+// calling IsValid, until it takes it out again, calling ResetSession, or the
+// connection is used otherwise. The watcher goroutine pings idle connections
+// every keepAliveInterval, holding idleMu so that the connection isn't used
+// or closed meanwhile. As Close takes idleMu, reads and writes failing
+// under it close the connection with cleanup.
+
+// ping sends B_REQ_PING and reads the response.
+func (mc *cwConn) ping() error {
+	if err := mc.writeCommandPacket(B_REQ_PING); err != nil {
+		return mc.markBadConn(err)
+	}
+	_, err := mc.readResultOK()
+	return err
+}
+
+// setIdle marks the connection idle, or in use, and reports whether its
+// session expired.
+func (mc *cwConn) setIdle(idle bool) (expired bool) {
+	mc.idleMu.Lock()
+	defer mc.idleMu.Unlock()
+	mc.idle = idle
+	return mc.sessionExpired()
+}
+
+// keepAlive pings the connection if it has been idle for keepAliveInterval.
+// A connection failing the ping is closed.
+func (mc *cwConn) keepAlive() {
+	mc.idleMu.Lock()
+	defer mc.idleMu.Unlock()
+	if !mc.idle || mc.closed.IsSet() || time.Since(mc.lastSent) < mc.cfg.KeepAlive {
+		return
+	}
+	if err := mc.ping(); err != nil {
+		mc.log(context.Background(), logLevelWarn, "keepalive failed", "err", err)
+		mc.cleanup()
+	}
+}
+
+// sessionExpired reports whether the server may be about to drop the
+// session, which was idle for most of the sessionTimeout.
+func (mc *cwConn) sessionExpired() bool {
+	timeout := mc.cfg.SessionTimeout
+	return timeout > 0 && time.Since(mc.lastSent) > timeout-timeout/10
+}
@@ -0,0 +1,162 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+// openPinged opens a database of one connection on srv, with the parameters
+// params, and counts the pings of the connection in pings.
+func openPinged(t *testing.T, srv *cloudwavetest.Server, params string, pings *int64) (*sql.DB, driver.Connector) {
+	t.Helper()
+	srv.Handle(cloudwave.B_REQ_PING, func([]byte) ([]byte, error) {
+		atomic.AddInt64(pings, 1)
+		return nil, nil
+	})
+	cfg, err := cloudwave.ParseDSN(srv.DSN() + params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector, err := cloudwave.NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(1)
+	return db, connector
+}
+
+func TestKeepAlive(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Expect("UPDATE t SET a = 1").WillReturnResult(1)
+
+	var pings int64
+	db, connector := openPinged(t, srv, "?keepAliveInterval=10ms", &pings)
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&pings); n != 1 {
+		t.Fatalf("Ping sent %d pings", n)
+	}
+
+	// the idle connection is pinged in the background
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt64(&pings); n < 3 {
+		t.Errorf("idle connection pinged %d times", n-1)
+	}
+	if _, err = db.Exec("UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cloudwave.ConnectorStats(connector); stats.Connects != 1 {
+		t.Errorf("connected %d times", stats.Connects)
+	}
+}
+
+func TestSessionTimeout(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var pings int64
+	db, connector := openPinged(t, srv, "?sessionTimeout=50ms", &pings)
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cloudwave.ConnectorStats(connector); stats.Connects != 1 {
+		t.Errorf("connected %d times before the session timed out", stats.Connects)
+	}
+
+	// the connection is replaced before the server would drop its session
+	time.Sleep(50 * time.Millisecond)
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cloudwave.ConnectorStats(connector); stats.Connects != 2 {
+		t.Errorf("connected %d times after the session timed out", stats.Connects)
+	}
+	if n := atomic.LoadInt64(&pings); n != 3 {
+		t.Errorf("sent %d pings", n)
+	}
+}
+
+func TestCloseWhileKeptAlive(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// run with -race: closing doesn't race with the keepalive pings
+	var pings int64
+	for i := 0; i < 20; i++ {
+		db, _ := openPinged(t, srv, "?keepAliveInterval=1ms", &pings)
+		if err = db.Ping(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Duration(i) * 100 * time.Microsecond)
+		if err = db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestKeepAliveDropped(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var pings int64
+	db, connector := openPinged(t, srv, "?keepAliveInterval=5ms", &pings)
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	// the server drops the idle session while it is kept alive
+	srv.Handle(cloudwave.B_REQ_PING, func([]byte) ([]byte, error) {
+		atomic.AddInt64(&pings, 1)
+		return nil, cloudwavetest.ErrCloseConnection
+	})
+	for atomic.LoadInt64(&pings) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- db.Close() }()
+	select {
+	case err = <-closed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on the failed keepalive")
+	}
+	if stats := cloudwave.ConnectorStats(connector); stats.Connects != 1 {
+		t.Errorf("connected %d times", stats.Connects)
+	}
+}
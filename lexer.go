@@ -0,0 +1,225 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import "strings"
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokWord             // keyword, identifier or number
+	tokString           // '...' literal
+	tokIdent            // "..." or `...` quoted identifier
+	tokPunct            // any other character, e.g. ( ) , ; ?
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// sqlLexer splits a query into tokens, skipping whitespace and comments.
+// Unterminated literals and comments extend to the end of the query.
+type sqlLexer struct {
+	query string
+	pos   int
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' ||
+		c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// next returns the next token, or a token of kind tokEOF at the end.
+func (l *sqlLexer) next() token {
+	q := l.query
+	for l.pos < len(q) {
+		start := l.pos
+		c := q[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			l.pos++
+		case c == '-' && strings.HasPrefix(q[l.pos:], "--"):
+			if end := strings.IndexByte(q[l.pos:], '\n'); end >= 0 {
+				l.pos += end + 1
+			} else {
+				l.pos = len(q)
+			}
+		case c == '/' && strings.HasPrefix(q[l.pos:], "/*"):
+			if end := strings.Index(q[l.pos+2:], "*/"); end >= 0 {
+				l.pos += end + 4
+			} else {
+				l.pos = len(q)
+			}
+		case c == '\'' || c == '"' || c == '`':
+			// an embedded quote is escaped by doubling it
+			for l.pos++; l.pos < len(q); l.pos++ {
+				if q[l.pos] == c {
+					if l.pos+1 < len(q) && q[l.pos+1] == c {
+						l.pos++
+						continue
+					}
+					l.pos++
+					break
+				}
+			}
+			kind := tokIdent
+			if c == '\'' {
+				kind = tokString
+			}
+			return token{kind, q[start:l.pos], start}
+		case isWordByte(c):
+			for l.pos < len(q) && isWordByte(q[l.pos]) {
+				l.pos++
+			}
+			return token{tokWord, q[start:l.pos], start}
+		default:
+			l.pos++
+			return token{tokPunct, q[start:l.pos], start}
+		}
+	}
+	return token{kind: tokEOF, pos: len(q)}
+}
+
+// stmtKind is the kind of an SQL statement.
+type stmtKind int
+
+const (
+	stmtOther   stmtKind = iota // statements not listed below
+	stmtQuery                   // statements returning rows
+	stmtUpdate                  // statements changing rows
+	stmtDDL                     // schema changes
+	stmtTx                      // transaction control
+	stmtSession                 // session and privilege settings
+	stmtCall                    // stored procedure calls
+)
+
+// stmtKinds maps the leading keyword of a statement to its kind.
+var stmtKinds = map[string]stmtKind{
+	"SELECT":   stmtQuery,
+	"VALUES":   stmtQuery,
+	"TABLE":    stmtQuery,
+	"EXPLAIN":  stmtQuery,
+	"SHOW":     stmtQuery,
+	"DESCRIBE": stmtQuery,
+	"DESC":     stmtQuery,
+
+	"INSERT":  stmtUpdate,
+	"UPDATE":  stmtUpdate,
+	"DELETE":  stmtUpdate,
+	"MERGE":   stmtUpdate,
+	"UPSERT":  stmtUpdate,
+	"REPLACE": stmtUpdate,
+
+	"CREATE":   stmtDDL,
+	"ALTER":    stmtDDL,
+	"DROP":     stmtDDL,
+	"TRUNCATE": stmtDDL,
+	"RENAME":   stmtDDL,
+	"COMMENT":  stmtDDL,
+
+	"BEGIN":     stmtTx,
+	"START":     stmtTx,
+	"COMMIT":    stmtTx,
+	"ROLLBACK":  stmtTx,
+	"SAVEPOINT": stmtTx,
+	"RELEASE":   stmtTx,
+
+	"SET":    stmtSession,
+	"USE":    stmtSession,
+	"GRANT":  stmtSession,
+	"REVOKE": stmtSession,
+
+	"CALL": stmtCall,
+}
+
+// classifyStatement returns the kind of the statement query. Leading
+// comments and parentheses are skipped, {call} escapes are calls, and the
+// kind of a WITH statement is the kind of the statement following its
+// common table expressions.
+func classifyStatement(query string) stmtKind {
+	l := sqlLexer{query: query}
+	tok := l.next()
+	// the escapes {call p(?)} and {? = call f(?)} call a procedure
+	if tok.kind == tokPunct && tok.text == "{" {
+		for tok = l.next(); tok.kind == tokPunct && (tok.text == "?" || tok.text == "="); tok = l.next() {
+		}
+		if tok.kind == tokWord && strings.EqualFold(tok.text, "CALL") {
+			return stmtCall
+		}
+		return stmtOther
+	}
+	for tok.kind == tokPunct && tok.text == "(" {
+		tok = l.next()
+	}
+	if tok.kind != tokWord {
+		return stmtOther
+	}
+	kw := strings.ToUpper(tok.text)
+	if kw == "EXPLAIN" {
+		return classifyExplained(query, &l)
+	}
+	if kw != "WITH" {
+		return stmtKinds[kw]
+	}
+	depth := 0
+	for tok = l.next(); tok.kind != tokEOF; tok = l.next() {
+		switch {
+		case tok.kind == tokPunct && tok.text == "(":
+			depth++
+		case tok.kind == tokPunct && tok.text == ")":
+			depth--
+		case tok.kind == tokWord && depth == 0:
+			switch kind := stmtKinds[strings.ToUpper(tok.text)]; kind {
+			case stmtQuery, stmtUpdate:
+				return kind
+			}
+		}
+	}
+	return stmtOther
+}
+
+// classifyExplained returns the kind of an EXPLAIN statement, whose options
+// l is positioned at. EXPLAIN returns rows, but EXPLAIN ANALYZE runs the
+// statement it explains: only the explanations of queries are queries, the
+// others aren't classified.
+func classifyExplained(query string, l *sqlLexer) stmtKind {
+	for tok := l.next(); tok.kind != tokEOF; tok = l.next() {
+		if tok.kind != tokWord {
+			continue
+		}
+		if kw := strings.ToUpper(tok.text); kw == "WITH" || stmtKinds[kw] != stmtOther {
+			if classifyStatement(query[tok.pos:]) == stmtQuery {
+				return stmtQuery
+			}
+			return stmtOther
+		}
+	}
+	return stmtOther
+}
+
+// idempotent reports whether statements of kind k can run twice with the
+// effect of running once.
+func (k stmtKind) idempotent() bool {
+	return k == stmtQuery
+}
+
+// execType returns the CLOUDWAVE_EXECUTE* type statements of kind k are
+// sent with.
+func (k stmtKind) execType() byte {
+	switch k {
+	case stmtQuery:
+		return CLOUDWAVE_EXECUTE_QUERY
+	case stmtUpdate:
+		return CLOUDWAVE_EXECUTE_UPDATE
+	}
+	return CLOUDWAVE_EXECUTE
+}
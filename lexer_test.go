@@ -0,0 +1,121 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		kind  stmtKind
+	}{
+		{"SELECT 1", stmtQuery},
+		{"  select * from t", stmtQuery},
+		{"-- leading comment\nSELECT 1", stmtQuery},
+		{"/* hint */ /* another */ SELECT 1", stmtQuery},
+		{"((SELECT 1) UNION (SELECT 2))", stmtQuery},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", stmtQuery},
+		{"WITH RECURSIVE x(n) AS (SELECT 1 UNION ALL SELECT n+1 FROM x) SELECT n FROM x", stmtQuery},
+		{"WITH x AS (SELECT 1 AS a) INSERT INTO t SELECT a FROM x", stmtUpdate},
+		{"with x as (select 'delete') delete from t where a in (select * from x)", stmtUpdate},
+		{"VALUES (1), (2)", stmtQuery},
+		{"EXPLAIN SELECT 1", stmtQuery},
+		{"explain analyze select * from t", stmtQuery},
+		{"EXPLAIN (ANALYZE, FORMAT JSON) WITH x AS (SELECT 1) SELECT * FROM x", stmtQuery},
+		{"EXPLAIN ANALYZE DELETE FROM t", stmtOther},
+		{"EXPLAIN ANALYZE WITH x AS (SELECT 1) UPDATE t SET a = 1", stmtOther},
+		{"EXPLAIN PLAN FOR INSERT INTO t VALUES (1)", stmtOther},
+		{"EXPLAIN t", stmtOther},
+		{"SHOW TABLES", stmtQuery},
+		{"DESCRIBE t", stmtQuery},
+		{"INSERT INTO t VALUES (1)", stmtUpdate},
+		{"Update t SET a = 1", stmtUpdate},
+		{"DELETE FROM t", stmtUpdate},
+		{"MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN UPDATE SET a = s.a", stmtUpdate},
+		{"CREATE TABLE t (a INT)", stmtDDL},
+		{"DROP TABLE t", stmtDDL},
+		{"COMMIT", stmtTx},
+		{"SET SCHEMA s", stmtSession},
+		{"{call p(?)}", stmtCall},
+		{"{ ? = CALL f(?) }", stmtCall},
+		{"{fn now()}", stmtOther},
+		{"CALL p(1)", stmtCall},
+		{"cloudwave_stats", stmtOther},
+		{"CloudWave", stmtOther},
+		{"", stmtOther},
+		{"-- only a comment", stmtOther},
+		{"'SELECT'", stmtOther},
+	}
+	for _, tt := range tests {
+		if got := classifyStatement(tt.query); got != tt.kind {
+			t.Errorf("%q: got kind %d, want %d", tt.query, got, tt.kind)
+		}
+	}
+
+	if got := classifyStatement("(SELECT 1)").execType(); got != CLOUDWAVE_EXECUTE_QUERY {
+		t.Errorf("expected query exec type, got %d", got)
+	}
+	if got := classifyStatement("MERGE INTO t USING s ON 1 = 1").execType(); got != CLOUDWAVE_EXECUTE_UPDATE {
+		t.Errorf("expected update exec type, got %d", got)
+	}
+	if got := classifyStatement("CREATE TABLE t (a INT)").execType(); got != CLOUDWAVE_EXECUTE {
+		t.Errorf("expected generic exec type, got %d", got)
+	}
+}
+
+func TestLexer(t *testing.T) {
+	l := sqlLexer{query: "SELECT 'it''s', \"a\"\"b\" -- c\n/* d */ FROM t WHERE x = ?"}
+	var got []token
+	for tok := l.next(); tok.kind != tokEOF; tok = l.next() {
+		got = append(got, tok)
+	}
+	want := []token{
+		{tokWord, "SELECT", 0},
+		{tokString, "'it''s'", 7},
+		{tokPunct, ",", 14},
+		{tokIdent, `"a""b"`, 16},
+		{tokWord, "FROM", 36},
+		{tokWord, "t", 41},
+		{tokWord, "WHERE", 43},
+		{tokWord, "x", 49},
+		{tokPunct, "=", 51},
+		{tokPunct, "?", 53},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAdminCommandArgs(t *testing.T) {
+	if _, ok := adminCommand([]driver.Value{int64(DATABASE_META_DATA_GET_TABLES)}); ok {
+		t.Error("int64 argument taken as admin command")
+	}
+	cmd, ok := adminCommand([]driver.Value{AdminCommand(DATABASE_META_DATA_GET_TABLES), "s"})
+	if !ok || cmd != DATABASE_META_DATA_GET_TABLES {
+		t.Errorf("unexpected admin command %d, %v", cmd, ok)
+	}
+
+	mc := &cwConn{cfg: NewConfig()}
+	nv := &driver.NamedValue{Ordinal: 1, Value: AdminCommand(DATABASE_META_DATA_GET_TABLES)}
+	if err := mc.CheckNamedValue(nv); err != nil || nv.Value != AdminCommand(DATABASE_META_DATA_GET_TABLES) {
+		t.Errorf("admin command converted to %T, %v", nv.Value, err)
+	}
+	nv = &driver.NamedValue{Ordinal: 2, Value: AdminCommand(1)}
+	if err := mc.CheckNamedValue(nv); err != nil || nv.Value != int64(1) {
+		t.Errorf("expected int64 for a later argument, got %T, %v", nv.Value, err)
+	}
+}
@@ -0,0 +1,350 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LOBReader binds a stream to a BLOB or CLOB parameter. The stream is sent
+// to the server in chunks and never buffered as a whole. For CLOB parameters
+// it must yield UTF-8 text.
+//
+// If Size is positive, exactly Size bytes are read from R, and a shorter
+// stream fails the statement. Otherwise R is read until io.EOF.
+type LOBReader struct {
+	R    io.Reader
+	Size int64
+}
+
+// isLOBValue reports whether v is a parameter that is streamed to a BLOB or
+// CLOB column.
+func isLOBValue(v interface{}) bool {
+	switch v.(type) {
+	case LOBReader, *LOBReader, io.Reader:
+		return true
+	}
+	return false
+}
+
+// uploadLOBs streams the parameters bound to BLOB and CLOB columns to the
+// server and returns args with these replaced by the resulting handles. It
+// must run before the execute packet is built, as the upload uses the
+// connection.
+func (stmt *cwStmt) uploadLOBs(args []driver.Value) ([]driver.Value, error) {
+	var out []driver.Value
+	for i, arg := range args {
+		if arg == nil || i >= len(stmt.paramType) {
+			continue
+		}
+		tp := stmt.paramType[i]
+		if tp != CLOUD_TYPE_BLOB && tp != CLOUD_TYPE_CLOB {
+			if isLOBValue(arg) {
+				return nil, fmt.Errorf("cloudwave: can't bind %T to %s parameter %d", arg, getTypeName(tp), i+1)
+			}
+			continue
+		}
+		lob, err := stmt.uploadLOB(arg, tp)
+		if err != nil {
+			return nil, fmt.Errorf("cloudwave: %s parameter %d: %w", getTypeName(tp), i+1, err)
+		}
+		if out == nil {
+			out = make([]driver.Value, len(args))
+			copy(out, args)
+		}
+		out[i] = lob
+	}
+	if out == nil {
+		return args, nil
+	}
+	return out, nil
+}
+
+func (stmt *cwStmt) uploadLOB(arg driver.Value, tp byte) (driver.Value, error) {
+	var r io.Reader
+	var size int64
+	switch v := arg.(type) {
+	case *CloudBlob:
+		if tp == CLOUD_TYPE_BLOB {
+			return v, nil
+		}
+		r = v
+	case *CloudClob:
+		if tp == CLOUD_TYPE_CLOB {
+			return v, nil
+		}
+		r = v
+	case LOBReader:
+		r, size = v.R, v.Size
+	case *LOBReader:
+		r, size = v.R, v.Size
+	case io.Reader:
+		r = v
+	case []byte:
+		r, size = bytes.NewReader(v), int64(len(v))
+	case string:
+		r, size = strings.NewReader(v), int64(len(v))
+	default:
+		return nil, fmt.Errorf("can't convert %T", arg)
+	}
+	if r == nil {
+		return nil, errors.New("nil reader")
+	}
+
+	if tp == CLOUD_TYPE_BLOB {
+		blob := getBlob(stmt.mc, -1, false)
+		if err := blob.resolveBinaryReader(r, size); err != nil {
+			if blob.id != -1 {
+				blob.Free()
+			}
+			return nil, err
+		}
+		return blob, nil
+	}
+	clob := getClob(stmt.mc, -1, false)
+	if err := clob.resolveCharacterReader(r, size); err != nil {
+		if clob.id != -1 {
+			clob.Free()
+		}
+		return nil, err
+	}
+	return clob, nil
+}
+
+// lobRequest sends the LOB command cmd for the LOB id of the given statement
+// and cursor, followed by payload, and returns the OK packet.
+func (mc *cwConn) lobRequest(cmd int, statementId, cursorId uint32, id int64, payload []byte) ([]byte, error) {
+	data := make([]byte, 25+4*2+8+len(payload))
+	pos := 25
+	binary.BigEndian.PutUint32(data[pos:], statementId)
+	pos += 4
+	binary.BigEndian.PutUint32(data[pos:], cursorId)
+	pos += 4
+	binary.BigEndian.PutUint64(data[pos:], uint64(id))
+	pos += 8
+	pos += copy(data[pos:], payload)
+	mc.setCommandPacket(cmd, pos, data[0:25])
+	// Send CMD packet
+	if err := mc.writePacket(data[0:pos]); err != nil {
+		return nil, err
+	}
+	return mc.readResultOK()
+}
+
+// readLOBPosition reads the result of a BLOB_POSITION_* or CLOB_POSITION_*
+// command, converting the 0-based position of the server to a 1-based one.
+func readLOBPosition(buf []byte) (int64, error) {
+	p, err := readLOBInt64(buf, "LOB position")
+	if err != nil {
+		return 0, err
+	}
+	if p < 0 {
+		return -1, nil
+	}
+	return p + 1, nil
+}
+
+// readLOBInt64 reads the 8-byte integer following the status byte of the
+// OK packet of a LOB command.
+func readLOBInt64(buf []byte, context string) (int64, error) {
+	r := NewPacketReader(buf, context)
+	r.Skip(1)
+	v := r.Int64()
+	return v, r.Err()
+}
+
+// lobHandle is a BLOB or CLOB handle returned by a query.
+type lobHandle interface {
+	Free() error
+	conn() *cwConn
+}
+
+func (blob *CloudBlob) conn() *cwConn { return blob.connection }
+func (clob *CloudClob) conn() *cwConn { return clob.connection }
+
+// resolveLOBs reads the LOB values of a row into memory in eager lobMode and
+// frees their handles. In lazy lobMode the handles are kept until the rows
+// are closed. The resulting values are then passed to the BLOB and CLOB type
+// codecs.
+func (rows *textRows) resolveLOBs(dest []driver.Value) error {
+	cfg := rows.stmt.mc.cfg
+	eager := cfg.LobMode == lobModeEager
+	for i, v := range dest {
+		h, ok := v.(lobHandle)
+		if !ok {
+			continue
+		}
+		tp := byte(CLOUD_TYPE_BLOB)
+		if _, ok := h.(*CloudClob); ok {
+			tp = CLOUD_TYPE_CLOB
+		}
+		if !eager {
+			rows.lobs = append(rows.lobs, h)
+		} else {
+			switch lob := h.(type) {
+			case *CloudBlob:
+				b, err := lob.GetBytes()
+				if err != nil {
+					return err
+				}
+				dest[i] = b
+			case *CloudClob:
+				s, err := lob.GetString()
+				if err != nil {
+					return err
+				}
+				dest[i] = string(s)
+			}
+			if err := h.Free(); err != nil {
+				return err
+			}
+		}
+		var err error
+		if dest[i], err = cfg.decodeValue(tp, dest[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freeLOBs frees the LOB handles returned by the rows. Handles of a closed
+// connection were released by the server already.
+func (rows *cwRows) freeLOBs() (err error) {
+	for _, h := range rows.lobs {
+		if mc := h.conn(); mc == nil || mc.closed.IsSet() {
+			continue
+		}
+		if ferr := h.Free(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	rows.lobs = nil
+	return err
+}
+
+// Blob scans a BLOB column in either lobMode. In lazy mode it holds the
+// handle bound to the cursor, which is valid until the rows are closed; in
+// eager mode it holds the value read into memory.
+type Blob struct {
+	Valid bool // Valid is true if the value is not NULL
+
+	handle *CloudBlob
+	data   []byte
+}
+
+// Scan implements the sql.Scanner interface.
+func (b *Blob) Scan(src interface{}) error {
+	*b = Blob{Valid: src != nil}
+	switch v := src.(type) {
+	case nil:
+	case *CloudBlob:
+		b.handle = v
+	case []byte:
+		b.data = append([]byte(nil), v...)
+	case string:
+		b.data = []byte(v)
+	default:
+		return fmt.Errorf("cloudwave: can't scan %T into Blob", src)
+	}
+	return nil
+}
+
+// Handle returns the live BLOB handle, or nil if the value was read eagerly.
+func (b *Blob) Handle() *CloudBlob {
+	return b.handle
+}
+
+// Len returns the length of the value in bytes.
+func (b *Blob) Len() (int64, error) {
+	if b.handle != nil {
+		return b.handle.Len()
+	}
+	return int64(len(b.data)), nil
+}
+
+// Bytes returns the whole value.
+func (b *Blob) Bytes() ([]byte, error) {
+	if b.handle != nil {
+		return b.handle.GetBytes()
+	}
+	return b.data, nil
+}
+
+// Reader returns a reader of the value, which streams it from the server in
+// lazy mode.
+func (b *Blob) Reader() io.Reader {
+	if b.handle != nil {
+		return b.handle
+	}
+	return bytes.NewReader(b.data)
+}
+
+// Clob scans a CLOB column in either lobMode. In lazy mode it holds the
+// handle bound to the cursor, which is valid until the rows are closed; in
+// eager mode it holds the value read into memory.
+type Clob struct {
+	Valid bool // Valid is true if the value is not NULL
+
+	handle *CloudClob
+	text   string
+}
+
+// Scan implements the sql.Scanner interface.
+func (c *Clob) Scan(src interface{}) error {
+	*c = Clob{Valid: src != nil}
+	switch v := src.(type) {
+	case nil:
+	case *CloudClob:
+		c.handle = v
+	case string:
+		c.text = v
+	case []byte:
+		c.text = string(v)
+	default:
+		return fmt.Errorf("cloudwave: can't scan %T into Clob", src)
+	}
+	return nil
+}
+
+// Handle returns the live CLOB handle, or nil if the value was read eagerly.
+func (c *Clob) Handle() *CloudClob {
+	return c.handle
+}
+
+// Len returns the length of the value in characters.
+func (c *Clob) Len() (int64, error) {
+	if c.handle != nil {
+		return c.handle.Len()
+	}
+	return utf16Len([]byte(c.text)), nil
+}
+
+// String returns the whole value.
+func (c *Clob) String() (string, error) {
+	if c.handle != nil {
+		s, err := c.handle.GetString()
+		return string(s), err
+	}
+	return c.text, nil
+}
+
+// Reader returns a reader of the UTF-8 text, which streams it from the
+// server in lazy mode.
+func (c *Clob) Reader() io.Reader {
+	if c.handle != nil {
+		return c.handle
+	}
+	return strings.NewReader(c.text)
+}
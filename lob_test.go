@@ -0,0 +1,92 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConvertLOBValues(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r := strings.NewReader("text")
+	for _, v := range []interface{}{f, r, LOBReader{R: r, Size: 4}, &LOBReader{R: r}} {
+		got, err := converter{}.ConvertValue(v)
+		if err != nil {
+			t.Errorf("%T: %v", v, err)
+		} else if got != v {
+			t.Errorf("%T: converted to %T", v, got)
+		}
+	}
+}
+
+func TestUploadLOBsRejectsNonLOBColumn(t *testing.T) {
+	stmt := &cwStmt{paramType: []byte{CLOUD_TYPE_LONG, CLOUD_TYPE_VARCHAR}}
+	args := []driver.Value{int64(1), strings.NewReader("text")}
+	if _, err := stmt.uploadLOBs(args); err == nil || !strings.Contains(err.Error(), "parameter 2") {
+		t.Errorf("expected error naming parameter 2, got %v", err)
+	}
+
+	args = []driver.Value{int64(1), "text"}
+	got, err := stmt.uploadLOBs(args)
+	if err != nil || &got[0] != &args[0] {
+		t.Errorf("expected args to be returned unchanged, got %v, %v", got, err)
+	}
+}
+
+func TestScanEagerLOBs(t *testing.T) {
+	var b Blob
+	if err := b.Scan([]byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := b.Bytes(); err != nil || !b.Valid || b.Handle() != nil || len(data) != 3 {
+		t.Errorf("unexpected Blob %v, %v", data, err)
+	}
+
+	var c Clob
+	if err := c.Scan("h\U0001F600"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Len(); err != nil || n != 3 {
+		t.Errorf("expected 3 characters, got %d, %v", n, err)
+	}
+	if s, err := c.String(); err != nil || s != "h\U0001F600" {
+		t.Errorf("unexpected Clob %q, %v", s, err)
+	}
+
+	if err := c.Scan(nil); err != nil || c.Valid {
+		t.Errorf("expected NULL Clob, got %+v, %v", c, err)
+	}
+	if err := b.Scan(int64(1)); err == nil {
+		t.Error("expected error scanning int64 into Blob")
+	}
+}
+
+func TestParseLobMode(t *testing.T) {
+	cfg, err := ParseDSN("user:pass@tcp(localhost:1978)/db?lobMode=eager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LobMode != lobModeEager {
+		t.Errorf("expected eager lobMode, got %q", cfg.LobMode)
+	}
+	if dsn := cfg.FormatDSN(); !strings.Contains(dsn, "lobMode=eager") {
+		t.Errorf("lobMode missing from %q", dsn)
+	}
+	if _, err := ParseDSN("user:pass@tcp(localhost:1978)/db?lobMode=never"); err == nil {
+		t.Error("expected error for invalid lobMode")
+	}
+}
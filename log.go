@@ -0,0 +1,146 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StructuredLogger is a leveled logger taking alternating keys and values
+// after the message. *slog.Logger implements it, so a Config can log to a
+// log/slog handler:
+//
+//	cfg.Logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+//
+// The driver logs errors at the error level. With logProtocol=true, it
+// also logs the statements it runs and the requests and responses it
+// exchanges at the debug level. Records carry the "conn" number and the
+// "session" of the server. Payloads aren't logged, and the values bound to
+// statements only with logParams=true.
+type StructuredLogger interface {
+	DebugContext(ctx context.Context, msg string, args ...interface{})
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+type logLevel int
+
+const (
+	logLevelDebug logLevel = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+var logLevelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// printLogger logs the records of a StructuredLogger to a Logger, as the
+// level, the message and key=value pairs.
+type printLogger struct {
+	Logger
+}
+
+func (l printLogger) print(level logLevel, msg string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString(logLevelNames[level])
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		b.WriteByte(' ')
+		if i+1 < len(args) {
+			fmt.Fprintf(&b, "%v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&b, "!BADKEY=%v", args[i])
+		}
+	}
+	l.Print(b.String())
+}
+
+func (l printLogger) DebugContext(_ context.Context, msg string, args ...interface{}) {
+	l.print(logLevelDebug, msg, args)
+}
+
+func (l printLogger) InfoContext(_ context.Context, msg string, args ...interface{}) {
+	l.print(logLevelInfo, msg, args)
+}
+
+func (l printLogger) WarnContext(_ context.Context, msg string, args ...interface{}) {
+	l.print(logLevelWarn, msg, args)
+}
+
+func (l printLogger) ErrorContext(_ context.Context, msg string, args ...interface{}) {
+	l.print(logLevelError, msg, args)
+}
+
+// logger returns the logger of the connection: the one of its Config, or
+// the one set with SetLogger.
+func (mc *cwConn) logger() StructuredLogger {
+	if mc.cfg.Logger != nil {
+		return mc.cfg.Logger
+	}
+	return printLogger{errLog}
+}
+
+// log logs msg and args at level, along with the connection and session.
+func (mc *cwConn) log(ctx context.Context, level logLevel, msg string, args ...interface{}) {
+	args = append(args, "conn", mc.connID, "session", mc.sessionSequence)
+	l := mc.logger()
+	switch level {
+	case logLevelDebug:
+		l.DebugContext(ctx, msg, args...)
+	case logLevelInfo:
+		l.InfoContext(ctx, msg, args...)
+	case logLevelWarn:
+		l.WarnContext(ctx, msg, args...)
+	default:
+		l.ErrorContext(ctx, msg, args...)
+	}
+}
+
+// logError logs err at the error level.
+func (mc *cwConn) logError(err error) {
+	mc.log(context.Background(), logLevelError, err.Error())
+}
+
+// logStatement logs the statement query run with args, if the protocol is
+// logged. Values are redacted unless logParams is set.
+func (mc *cwConn) logStatement(ctx context.Context, msg, query string, args []driver.NamedValue) {
+	if !mc.cfg.LogProtocol {
+		return
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		if mc.cfg.LogParams {
+			values[i] = arg.Value
+		} else {
+			values[i] = "[redacted]"
+		}
+	}
+	mc.log(ctx, logLevelDebug, msg, "query", query, "args", values)
+}
+
+// logRequest logs the request packet data, if the protocol is logged.
+func (mc *cwConn) logRequest(data []byte) {
+	if mc.cfg.LogProtocol {
+		mc.log(context.Background(), logLevelDebug, "request", "cmd", CommandName(mc.lastCmd), "bytes", len(data))
+	}
+}
+
+// logResponse logs the response body data, if the protocol is logged.
+func (mc *cwConn) logResponse(data []byte) {
+	if mc.cfg.LogProtocol {
+		mc.log(context.Background(), logLevelDebug, "response", "cmd", CommandName(mc.lastCmd), "bytes", len(data),
+			"elapsed", time.Since(mc.lastSent))
+	}
+}
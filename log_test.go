@@ -0,0 +1,138 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+// testLogger records the records logged, one line each.
+type testLogger struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (l *testLogger) record(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	l.records = append(l.records, fmt.Sprint(level, " ", msg, " ", args))
+	l.mu.Unlock()
+}
+
+func (l *testLogger) DebugContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("DEBUG", msg, args)
+}
+
+func (l *testLogger) InfoContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("INFO", msg, args)
+}
+
+func (l *testLogger) WarnContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("WARN", msg, args)
+}
+
+func (l *testLogger) ErrorContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("ERROR", msg, args)
+}
+
+// logStatements runs a statement with an argument on srv, logging to a
+// testLogger with the parameters of dsn.
+func logStatements(t *testing.T, srv *cloudwavetest.Server, params string) string {
+	t.Helper()
+	srv.Expect("UPDATE t SET a = ?").WithArgs("s3cret-value").WillReturnResult(1)
+	cfg, err := cloudwave.ParseDSN(srv.DSN() + params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &testLogger{}
+	cfg.Logger = logger
+	connector, err := cloudwave.NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err = db.Exec("UPDATE t SET a = ?", "s3cret-value"); err != nil {
+		t.Fatal(err)
+	}
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	return strings.Join(logger.records, "\n")
+}
+
+func TestStructuredLogging(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.SetCredentials("alice", "pa55word")
+
+	if records := logStatements(t, srv, ""); records != "" {
+		t.Errorf("logged without logProtocol:\n%s", records)
+	}
+
+	records := logStatements(t, srv, "?logProtocol=true")
+	for _, want := range []string{
+		"DEBUG request [cmd B_REQ_BUILD_CONNECTION bytes ",
+		"DEBUG response [cmd EXECUTE_PREPARED_STATEMENT bytes ",
+		"DEBUG exec [query UPDATE t SET a = ? args [[redacted]] conn ",
+		" session ",
+	} {
+		if !strings.Contains(records, want) {
+			t.Errorf("%q not logged:\n%s", want, records)
+		}
+	}
+	if strings.Contains(records, "pa55word") || strings.Contains(records, "s3cret-value") {
+		t.Errorf("secret logged:\n%s", records)
+	}
+
+	if records = logStatements(t, srv, "?logProtocol=true&logParams=true"); !strings.Contains(records, "args [s3cret-value]") {
+		t.Errorf("value not logged with logParams:\n%s", records)
+	}
+}
+
+func TestSetLoggerShim(t *testing.T) {
+	var lines []string
+	if err := cloudwave.SetLogger(printFunc(func(v ...interface{}) { lines = append(lines, fmt.Sprint(v...)) })); err != nil {
+		t.Fatal(err)
+	}
+	defer cloudwave.SetLogger(log.New(os.Stderr, "[cloudwave] ", log.Ldate|log.Ltime|log.Lshortfile))
+
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	db, err := sql.Open("cloudwave", srv.DSN()+"?logProtocol=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err = db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "DEBUG request cmd=B_REQ_BUILD_CONNECTION bytes=") ||
+		!strings.Contains(lines[0], " conn=") {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
+
+type printFunc func(v ...interface{})
+
+func (f printFunc) Print(v ...interface{}) { f(v...) }
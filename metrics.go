@@ -0,0 +1,298 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Names of the metrics passed to a MetricsSink.
+const (
+	MetricRoundTrips      = "round_trips"       // requests sent, labelled with the command name
+	MetricBytesRead       = "bytes_read"        // bytes read from the server
+	MetricBytesWritten    = "bytes_written"     // bytes written to the server
+	MetricRowsFetched     = "rows_fetched"      // rows read by queries
+	MetricLOBBytesRead    = "lob_bytes_read"    // bytes of BLOB and CLOB data read
+	MetricLOBBytesWritten = "lob_bytes_written" // bytes of BLOB and CLOB data written
+	MetricConnects        = "connects"          // connections opened
+	MetricCancellations   = "cancellations"     // operations canceled by their context
+	MetricBadConns        = "bad_conns"         // connections reported bad to database/sql
+	MetricRetries         = "retries"           // statements run again by the retry policy
+	MetricReconnects      = "reconnects"        // lost connections opened again by the retry policy
+	MetricQueryLatency    = "query_latency"     // duration of Exec and Query calls
+)
+
+// MetricsSink receives the metrics of connections opened with
+// metrics=<name>, to forward them to a monitoring system. It is called
+// concurrently by the connections and from the hot paths of the driver,
+// so it should be fast.
+type MetricsSink interface {
+	// Count adds n to the counter metric. label is the command name for
+	// MetricRoundTrips and empty otherwise.
+	Count(metric, label string, n uint64)
+
+	// Observe records an observation d of the histogram metric.
+	Observe(metric string, d time.Duration)
+}
+
+var (
+	metricsSinksLock sync.RWMutex
+	metricsSinks     map[string]MetricsSink
+)
+
+// RegisterMetricsSink registers a sink receiving the metrics of
+// connections, which can afterwards be used by adding metrics=<name> to
+// the DSN.
+func RegisterMetricsSink(name string, sink MetricsSink) {
+	metricsSinksLock.Lock()
+	if metricsSinks == nil {
+		metricsSinks = make(map[string]MetricsSink)
+	}
+	metricsSinks[name] = sink
+	metricsSinksLock.Unlock()
+}
+
+// DeregisterMetricsSink removes the metrics sink registered with the given
+// name.
+func DeregisterMetricsSink(name string) {
+	metricsSinksLock.Lock()
+	if metricsSinks != nil {
+		delete(metricsSinks, name)
+	}
+	metricsSinksLock.Unlock()
+}
+
+func getMetricsSink(name string) (sink MetricsSink) {
+	metricsSinksLock.RLock()
+	if v, ok := metricsSinks[name]; ok {
+		sink = v
+	}
+	metricsSinksLock.RUnlock()
+	return
+}
+
+// Stats are the protocol statistics of a connection, or of all the
+// connections of a connector.
+type Stats struct {
+	RoundTrips      map[string]uint64 // requests sent, by command name
+	BytesRead       uint64            // bytes read from the server
+	BytesWritten    uint64            // bytes written to the server
+	RowsFetched     uint64            // rows read by queries
+	LOBBytesRead    uint64            // bytes of BLOB and CLOB data read
+	LOBBytesWritten uint64            // bytes of BLOB and CLOB data written
+	Connects        uint64            // connections opened, including reconnects
+	Cancellations   uint64            // operations canceled by their context
+	BadConns        uint64            // connections reported bad to database/sql
+	Retries         uint64            // statements run again by the retry policy
+	Reconnects      uint64            // lost connections opened again by the retry policy
+	QueryLatency    Histogram         // duration of Exec and Query calls
+}
+
+// Histogram counts observed durations in buckets.
+type Histogram struct {
+	Bounds []time.Duration // upper bounds of the buckets but the last one
+	Counts []uint64        // observations per bucket, one more than Bounds
+	Count  uint64          // number of observations
+	Sum    time.Duration   // sum of the observations
+}
+
+// latencyBounds are the bucket bounds of latency histograms.
+var latencyBounds = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// ConnectorStats returns the statistics of the connections opened by c,
+// a connector returned by NewConnector or CloudWaveDriver.OpenConnector,
+// or any connector with a Stats method returning them. It returns zero
+// Stats for other connectors. Connectors of databases opened with sql.Open
+// aren't accessible, use sql.OpenDB to get them.
+func ConnectorStats(c driver.Connector) Stats {
+	if c, ok := c.(interface{ Stats() Stats }); ok {
+		return c.Stats()
+	}
+	return Stats{}
+}
+
+// Stats returns the statistics of the connections opened by the connector.
+func (c *connector) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// ConnStats returns the statistics of the connection c.
+func ConnStats(c *sql.Conn) (stats Stats, err error) {
+	err = c.Raw(func(driverConn interface{}) error {
+		mc, ok := driverConn.(*cwConn)
+		if !ok {
+			return errors.New("cloudwave: not a CloudWave connection")
+		}
+		stats = mc.stats.snapshot()
+		return nil
+	})
+	return
+}
+
+type counter int
+
+const (
+	counterBytesRead counter = iota
+	counterBytesWritten
+	counterRowsFetched
+	counterLOBBytesRead
+	counterLOBBytesWritten
+	counterConnects
+	counterCancellations
+	counterBadConns
+	counterRetries
+	counterReconnects
+	numCounters
+)
+
+var counterNames = [numCounters]string{
+	MetricBytesRead,
+	MetricBytesWritten,
+	MetricRowsFetched,
+	MetricLOBBytesRead,
+	MetricLOBBytesWritten,
+	MetricConnects,
+	MetricCancellations,
+	MetricBadConns,
+	MetricRetries,
+	MetricReconnects,
+}
+
+// metrics accumulates statistics. The zero value is ready to use.
+type metrics struct {
+	counters [numCounters]atomic.Uint64
+
+	latency      [len(latencyBounds) + 1]atomic.Uint64
+	latencyCount atomic.Uint64
+	latencySum   atomic.Int64
+
+	mu         sync.Mutex
+	roundTrips map[int]uint64
+}
+
+func (m *metrics) roundTrip(cmd int) {
+	m.mu.Lock()
+	if m.roundTrips == nil {
+		m.roundTrips = make(map[int]uint64)
+	}
+	m.roundTrips[cmd]++
+	m.mu.Unlock()
+}
+
+func (m *metrics) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	m.latency[i].Add(1)
+	m.latencyCount.Add(1)
+	m.latencySum.Add(int64(d))
+}
+
+func (m *metrics) snapshot() Stats {
+	s := Stats{
+		BytesRead:       m.counters[counterBytesRead].Load(),
+		BytesWritten:    m.counters[counterBytesWritten].Load(),
+		RowsFetched:     m.counters[counterRowsFetched].Load(),
+		LOBBytesRead:    m.counters[counterLOBBytesRead].Load(),
+		LOBBytesWritten: m.counters[counterLOBBytesWritten].Load(),
+		Connects:        m.counters[counterConnects].Load(),
+		Cancellations:   m.counters[counterCancellations].Load(),
+		BadConns:        m.counters[counterBadConns].Load(),
+		Retries:         m.counters[counterRetries].Load(),
+		Reconnects:      m.counters[counterReconnects].Load(),
+		QueryLatency: Histogram{
+			Bounds: append([]time.Duration(nil), latencyBounds[:]...),
+			Counts: make([]uint64, len(m.latency)),
+			Count:  m.latencyCount.Load(),
+			Sum:    time.Duration(m.latencySum.Load()),
+		},
+	}
+	for i := range m.latency {
+		s.QueryLatency.Counts[i] = m.latency[i].Load()
+	}
+	m.mu.Lock()
+	s.RoundTrips = make(map[string]uint64, len(m.roundTrips))
+	for cmd, n := range m.roundTrips {
+		s.RoundTrips[CommandName(cmd)] = n
+	}
+	m.mu.Unlock()
+	return s
+}
+
+// count adds n to the counter c of the connection and of its connector.
+func (mc *cwConn) count(c counter, n uint64) {
+	mc.stats.counters[c].Add(n)
+	if mc.pool != nil {
+		mc.pool.counters[c].Add(n)
+	}
+	if mc.metricsSink != nil {
+		mc.metricsSink.Count(counterNames[c], "", n)
+	}
+}
+
+func (mc *cwConn) countBytesRead(n int) {
+	mc.count(counterBytesRead, uint64(n))
+}
+
+// countRequest records the request packet data of the command cmd.
+func (mc *cwConn) countRequest(cmd int, data []byte) {
+	mc.stats.roundTrip(cmd)
+	if mc.pool != nil {
+		mc.pool.roundTrip(cmd)
+	}
+	if mc.metricsSink != nil {
+		mc.metricsSink.Count(MetricRoundTrips, CommandName(cmd), 1)
+	}
+	mc.count(counterBytesWritten, uint64(len(data)))
+	switch cmd {
+	case LOB_WRITE_BUFFER, CLOB_WRITE, BLOB_SET_BYTES, CLOB_SET_STRING:
+		// the payload starts with the statement, cursor and LOB id
+		if n := len(data) - 25 - 16; n > 0 {
+			mc.count(counterLOBBytesWritten, uint64(n))
+		}
+	}
+}
+
+// countResponse records the response body data.
+func (mc *cwConn) countResponse(data []byte) {
+	switch mc.lastCmd {
+	case LOB_READ_BUFFER, CLOB_READ, BLOB_GET_BYTES, CLOB_GET_SUB_STRING:
+		if len(data) > 1 && data[0] == 1 {
+			mc.count(counterLOBBytesRead, uint64(len(data)-1))
+		}
+	}
+}
+
+// observeQuery records the latency of a query started at start.
+func (mc *cwConn) observeQuery(start time.Time) {
+	d := time.Since(start)
+	mc.stats.observe(d)
+	if mc.pool != nil {
+		mc.pool.observe(d)
+	}
+	if mc.metricsSink != nil {
+		mc.metricsSink.Observe(MetricQueryLatency, d)
+	}
+}
@@ -0,0 +1,133 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+type testSink struct {
+	mu           sync.Mutex
+	counts       map[string]uint64
+	observations int
+}
+
+func (s *testSink) Count(metric, label string, n uint64) {
+	s.mu.Lock()
+	if label != "" {
+		metric += "/" + label
+	}
+	s.counts[metric] += n
+	s.mu.Unlock()
+}
+
+func (s *testSink) Observe(metric string, d time.Duration) {
+	s.mu.Lock()
+	s.observations++
+	s.mu.Unlock()
+}
+
+func TestMetrics(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Expect("UPDATE t SET a = 1").WillReturnResult(1)
+	srv.Expect("SELECT data FROM docs").WillReturnRows(cloudwavetest.NewRows(
+		cloudwavetest.Column{Name: "data", Type: cloudwave.CLOUD_TYPE_BLOB},
+	).AddRow([]byte("0123456789")).AddRow([]byte("abc")))
+	srv.Expect("SELECT sleep()").WillDelayFor(200 * time.Millisecond)
+
+	sink := &testSink{counts: make(map[string]uint64)}
+	cloudwave.RegisterMetricsSink("test", sink)
+	defer cloudwave.DeregisterMetricsSink("test")
+	cfg, err := cloudwave.ParseDSN(srv.DSN() + "?metrics=test&lobMode=eager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector, err := cloudwave.NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.ExecContext(context.Background(), "UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := conn.QueryContext(context.Background(), "SELECT data FROM docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			t.Fatal(err)
+		}
+		total += len(data)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if total != 13 {
+		t.Errorf("read %d bytes of BLOBs", total)
+	}
+	connStats, err := cloudwave.ConnStats(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = db.ExecContext(ctx, "SELECT sleep()"); err == nil {
+		t.Error("delayed statement didn't time out")
+	}
+
+	// USE runs on connecting, and the canceled statement reuses the connection
+	stats := cloudwave.ConnectorStats(connector)
+	if connStats.RowsFetched != 2 || connStats.LOBBytesRead < 13 || connStats.QueryLatency.Count != 2 ||
+		connStats.RoundTrips["EXECUTE_STATEMENT"] != 3 || connStats.Connects != 1 {
+		t.Errorf("unexpected connection stats %+v", connStats)
+	}
+	if stats.Connects != 1 || stats.Cancellations != 1 || stats.QueryLatency.Count != 3 ||
+		stats.BytesRead <= connStats.BytesRead || stats.BytesWritten <= connStats.BytesWritten {
+		t.Errorf("unexpected connector stats %+v", stats)
+	}
+	var buckets uint64
+	for _, n := range stats.QueryLatency.Counts {
+		buckets += n
+	}
+	if len(stats.QueryLatency.Counts) != len(stats.QueryLatency.Bounds)+1 || buckets != stats.QueryLatency.Count {
+		t.Errorf("inconsistent histogram %+v", stats.QueryLatency)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.counts[cloudwave.MetricRowsFetched] != stats.RowsFetched ||
+		sink.counts[cloudwave.MetricBytesWritten] != stats.BytesWritten ||
+		sink.counts[cloudwave.MetricRoundTrips+"/EXECUTE_STATEMENT"] != stats.RoundTrips["EXECUTE_STATEMENT"] ||
+		sink.observations != int(stats.QueryLatency.Count) {
+		t.Errorf("sink got %v and %d observations, stats are %+v", sink.counts, sink.observations, stats)
+	}
+}
@@ -0,0 +1,204 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import "strconv"
+
+// commandNames maps the request commands of the server to their names.
+var commandNames = map[int]string{
+	B_REQ_BUILD_CONNECTION:                             "B_REQ_BUILD_CONNECTION",
+	B_REQ_CLOSE_CONNECTION:                             "B_REQ_CLOSE_CONNECTION",
+	B_REQ_PING:                                         "B_REQ_PING",
+	B_REQ_STOP_SERVER:                                  "B_REQ_STOP_SERVER",
+	B_REQ_BFILE_WRITE:                                  "B_REQ_BFILE_WRITE",
+	B_REQ_BFILE_READ:                                   "B_REQ_BFILE_READ",
+	B_REQ_BFILE_CREATE:                                 "B_REQ_BFILE_CREATE",
+	B_REQ_BFILE_SYNC:                                   "B_REQ_BFILE_SYNC",
+	B_REQ_BFILE_GETBYNAME:                              "B_REQ_BFILE_GETBYNAME",
+	B_REQ_BFILE_GETALL:                                 "B_REQ_BFILE_GETALL",
+	B_REQ_BFILE_GET_SEGMENT_TABLET_SERVER:              "B_REQ_BFILE_GET_SEGMENT_TABLET_SERVER",
+	B_REQ_BFILE_DELETE:                                 "B_REQ_BFILE_DELETE",
+	B_REQ_TABLET_SERVER_CONNECT:                        "B_REQ_TABLET_SERVER_CONNECT",
+	B_REQ_BFILE_CLOSE:                                  "B_REQ_BFILE_CLOSE",
+	B_REQ_BFILE_GET_BY_ID:                              "B_REQ_BFILE_GET_BY_ID",
+	B_REQ_BFILE_BATCH_WRITE:                            "B_REQ_BFILE_BATCH_WRITE",
+	B_REQ_BFILE_BATCH_CREATE:                           "B_REQ_BFILE_BATCH_CREATE",
+	B_REQ_BFILE_NFSBFILE_CREATE:                        "B_REQ_BFILE_NFSBFILE_CREATE",
+	B_REQ_BFILE_GET_NFSBFILE_INPUTSTREAM:               "B_REQ_BFILE_GET_NFSBFILE_INPUTSTREAM",
+	B_REQ_GET_NEXT_TABLET_SERVER:                       "B_REQ_GET_NEXT_TABLET_SERVER",
+	B_REQ_AUTO_TABLET_INSERT_FILES:                     "B_REQ_AUTO_TABLET_INSERT_FILES",
+	B_REQ_AUTO_TABLET_SYNC:                             "B_REQ_AUTO_TABLET_SYNC",
+	CONNECTION_SET_AUTO_COMMIT:                         "CONNECTION_SET_AUTO_COMMIT",
+	CONNECTION_CREATE_STATEMENT:                        "CONNECTION_CREATE_STATEMENT",
+	CONNECTION_CREATE_BLOB:                             "CONNECTION_CREATE_BLOB",
+	CONNECTION_CREATE_CLOB:                             "CONNECTION_CREATE_CLOB",
+	CLOSE_STATEMENT:                                    "CLOSE_STATEMENT",
+	EXECUTE_STATEMENT:                                  "EXECUTE_STATEMENT",
+	EXECUTE_BATCH:                                      "EXECUTE_BATCH",
+	CONNECTION_PREPARED_STATEMENT:                      "CONNECTION_PREPARED_STATEMENT",
+	CLOSE_PREPARED_STATEMENT:                           "CLOSE_PREPARED_STATEMENT",
+	EXECUTE_PREPARED_STATEMENT:                         "EXECUTE_PREPARED_STATEMENT",
+	RESULT_SET_QUERY_NEXT:                              "RESULT_SET_QUERY_NEXT",
+	RESULT_SET_QUERY_PREV:                              "RESULT_SET_QUERY_PREV",
+	RESULT_SET_RESOVE_LARGE_STRING_REF:                 "RESULT_SET_RESOVE_LARGE_STRING_REF",
+	RESULT_SET_CLOSE:                                   "RESULT_SET_CLOSE",
+	DATABASE_META_DATA_GET_SCHEMAS:                     "DATABASE_META_DATA_GET_SCHEMAS",
+	DATABASE_META_DATA_GET_TABLESPACES:                 "DATABASE_META_DATA_GET_TABLESPACES",
+	DATABASE_META_DATA_GET_TABLES:                      "DATABASE_META_DATA_GET_TABLES",
+	DATABASE_META_DATA_GET_TABLE_PRIVILEGES:            "DATABASE_META_DATA_GET_TABLE_PRIVILEGES",
+	DATABASE_META_DATA_GET_USER_TABLE_PRIVILEGES:       "DATABASE_META_DATA_GET_USER_TABLE_PRIVILEGES",
+	DATABASE_META_DATA_GET_COLUMNS:                     "DATABASE_META_DATA_GET_COLUMNS",
+	DATABASE_META_DATA_GET_PRIMARY_KEYS:                "DATABASE_META_DATA_GET_PRIMARY_KEYS",
+	DATABASE_META_DATA_GET_EXPORTED_KEYS:               "DATABASE_META_DATA_GET_EXPORTED_KEYS",
+	DATABASE_META_DATA_GET_IMPORTED_KEYS:               "DATABASE_META_DATA_GET_IMPORTED_KEYS",
+	DATABASE_META_DATA_GET_CATALOGS:                    "DATABASE_META_DATA_GET_CATALOGS",
+	DATABASE_META_DATA_GET_USERS:                       "DATABASE_META_DATA_GET_USERS",
+	BLOB_GET_BINARY_STREAM:                             "BLOB_GET_BINARY_STREAM",
+	CONNECTION_COMMIT:                                  "CONNECTION_COMMIT",
+	CONNECTION_ROLLBACK:                                "CONNECTION_ROLLBACK",
+	LOB_READ_BUFFER:                                    "LOB_READ_BUFFER",
+	LOB_WRITE_BUFFER:                                   "LOB_WRITE_BUFFER",
+	LOB_GET_DATA_BLOCK_INFO:                            "LOB_GET_DATA_BLOCK_INFO",
+	BLOB_LENGTH:                                        "BLOB_LENGTH",
+	BLOB_GET_BYTES:                                     "BLOB_GET_BYTES",
+	BLOB_POSITION_BYTEARRAY_PATTERN:                    "BLOB_POSITION_BYTEARRAY_PATTERN",
+	BLOB_POSITION_BLOB_PATTERN:                         "BLOB_POSITION_BLOB_PATTERN",
+	BLOB_SET_BYTES:                                     "BLOB_SET_BYTES",
+	BLOB_SET_BINARY_STREAM:                             "BLOB_SET_BINARY_STREAM",
+	BLOB_TRUNCATE:                                      "BLOB_TRUNCATE",
+	BLOB_FREE:                                          "BLOB_FREE",
+	CLOB_FREE:                                          "CLOB_FREE",
+	CLOB_READ:                                          "CLOB_READ",
+	CLOB_WRITE:                                         "CLOB_WRITE",
+	CLOB_GET_ASCII_STREAM:                              "CLOB_GET_ASCII_STREAM",
+	CLOB_GET_CHARACTER_STREAM:                          "CLOB_GET_CHARACTER_STREAM",
+	CLOB_GET_SUB_STRING:                                "CLOB_GET_SUB_STRING",
+	CLOB_LENGTH:                                        "CLOB_LENGTH",
+	CLOB_POSITION_STRING:                               "CLOB_POSITION_STRING",
+	CLOB_POSITION_CLOB:                                 "CLOB_POSITION_CLOB",
+	CLOB_SET_ASCII_STREAM:                              "CLOB_SET_ASCII_STREAM",
+	CLOB_SET_CHARACTER_STREAM:                          "CLOB_SET_CHARACTER_STREAM",
+	CLOB_SET_STRING:                                    "CLOB_SET_STRING",
+	CLOB_TRUNCATE:                                      "CLOB_TRUNCATE",
+	DISPLAY:                                            "DISPLAY",
+	CONNECTION_SHUT_DOWN_SERVER:                        "CONNECTION_SHUT_DOWN_SERVER",
+	CLEAR_CACHE:                                        "CLEAR_CACHE",
+	DATABASE_META_DATA_LIST_SCHEMAS:                    "DATABASE_META_DATA_LIST_SCHEMAS",
+	DATABASE_META_DATA_LIST_TABLES:                     "DATABASE_META_DATA_LIST_TABLES",
+	EXECUTE_STATEMENT_BATCH_INSERT:                     "EXECUTE_STATEMENT_BATCH_INSERT",
+	CONNECTION_CREATE_BLOBS:                            "CONNECTION_CREATE_BLOBS",
+	CONNECTION_CREATE_CLOBS:                            "CONNECTION_CREATE_CLOBS",
+	CONNECTION_SET_TABLET_SPLIT_THRESHOLD:              "CONNECTION_SET_TABLET_SPLIT_THRESHOLD",
+	EXECUTE_BATCH_PREPARED:                             "EXECUTE_BATCH_PREPARED",
+	DATABASE_META_DATA_GET_TYPE_INFO:                   "DATABASE_META_DATA_GET_TYPE_INFO",
+	CREATE_FULL_TEXT_INDEX:                             "CREATE_FULL_TEXT_INDEX",
+	FULL_TEXT_SEARCH:                                   "FULL_TEXT_SEARCH",
+	DELETE_FULL_TEXT_INDEX:                             "DELETE_FULL_TEXT_INDEX",
+	HIGHLIGHT:                                          "HIGHLIGHT",
+	RESULT_SET_GET_RECORD_COUNT:                        "RESULT_SET_GET_RECORD_COUNT",
+	RESULT_SET_GET_EXECUTION_INFO:                      "RESULT_SET_GET_EXECUTION_INFO",
+	DATABASE_META_DATA_GET_SERVERS:                     "DATABASE_META_DATA_GET_SERVERS",
+	DATABASE_META_DATA_GET_TABLETS:                     "DATABASE_META_DATA_GET_TABLETS",
+	DATABASE_META_DATA_GET_SEQUENCES:                   "DATABASE_META_DATA_GET_SEQUENCES",
+	DATA_LOAD:                                          "DATA_LOAD",
+	CHECK_POINT:                                        "CHECK_POINT",
+	GET_TABLET_RESULT_SET:                              "GET_TABLET_RESULT_SET",
+	GET_INFO_FROM_HDFS:                                 "GET_INFO_FROM_HDFS",
+	GET_CPU_INFO:                                       "GET_CPU_INFO",
+	GET_HDFS_DATA_BLOCK_SIZE:                           "GET_HDFS_DATA_BLOCK_SIZE",
+	EXECUTE_GC:                                         "EXECUTE_GC",
+	GET_RUNNING_SQL:                                    "GET_RUNNING_SQL",
+	GET_RUNNING_TASK:                                   "GET_RUNNING_TASK",
+	GET_ONLINE_USER:                                    "GET_ONLINE_USER",
+	GET_CACHE:                                          "GET_CACHE",
+	SET_TRANSACTION_ISOLATION:                          "SET_TRANSACTION_ISOLATION",
+	CREATE_UDF:                                         "CREATE_UDF",
+	DELETE_UDF:                                         "DELETE_UDF",
+	GET_UDF_CLASS_NAME:                                 "GET_UDF_CLASS_NAME",
+	GET_UDF_METHOD_NAMES:                               "GET_UDF_METHOD_NAMES",
+	CONNECTION_CALLABLE_STATEMENT:                      "CONNECTION_CALLABLE_STATEMENT",
+	EXECUTE_CALLABLE_STATEMENT:                         "EXECUTE_CALLABLE_STATEMENT",
+	DATABASE_META_DATA_GET_RECORD_COUNT_OF_ALL_TABLETS: "DATABASE_META_DATA_GET_RECORD_COUNT_OF_ALL_TABLETS",
+	DATABASE_META_DATA_GET_TABLET_COUNT:                "DATABASE_META_DATA_GET_TABLET_COUNT",
+	GET_THREAD_INFO:                                    "GET_THREAD_INFO",
+	GET_FULLTEXTINDEX_INFO:                             "GET_FULLTEXTINDEX_INFO",
+	SET_AUTO_TABLET_RECORDCOUNT:                        "SET_AUTO_TABLET_RECORDCOUNT",
+	RESULT_SET_GET_TABLET_IDS:                          "RESULT_SET_GET_TABLET_IDS",
+	GET_INC_LOGS:                                       "GET_INC_LOGS",
+	REDO_INC_LOGS:                                      "REDO_INC_LOGS",
+	DATABASE_META_DATA_GET_DB_FILES:                    "DATABASE_META_DATA_GET_DB_FILES",
+	DATABASE_META_DATA_GET_DB_FILE_DATA:                "DATABASE_META_DATA_GET_DB_FILE_DATA",
+	INSERT_DB_FILE_DATA:                                "INSERT_DB_FILE_DATA",
+	CLOSE_DB_FILE_OUTPUT:                               "CLOSE_DB_FILE_OUTPUT",
+	GET_SERVER_VERSION:                                 "GET_SERVER_VERSION",
+	CONNECTION_CANCEL_STATEMENT:                        "CONNECTION_CANCEL_STATEMENT",
+	GET_TRANSACTION_ISOLATION:                          "GET_TRANSACTION_ISOLATION",
+	DATABASE_META_DATA_GET_SYSTEM_UTILIZATION:          "DATABASE_META_DATA_GET_SYSTEM_UTILIZATION",
+	DATABASE_META_DATA_GET_MEMORY_SIZE:                 "DATABASE_META_DATA_GET_MEMORY_SIZE",
+	GET_CONFIG_OPTIONS:                                 "GET_CONFIG_OPTIONS",
+	GET_SYSTEM_OVERVIEW:                                "GET_SYSTEM_OVERVIEW",
+	CONNECTION_SET_CLIENT_PROPERTIES:                   "CONNECTION_SET_CLIENT_PROPERTIES",
+	DATABASE_META_DATA_GET_SCHEMA_OWNER:                "DATABASE_META_DATA_GET_SCHEMA_OWNER",
+	DATABASE_UPDATE_PATCH:                              "DATABASE_UPDATE_PATCH",
+	DATABASE_RESTART_SERVER:                            "DATABASE_RESTART_SERVER",
+	DATABASE_META_DATA_GET_USER_PRIVILEGES:             "DATABASE_META_DATA_GET_USER_PRIVILEGES",
+	GET_INDEX_INFO:                                     "GET_INDEX_INFO",
+	DATABASE_UPDATE_LICENSE:                            "DATABASE_UPDATE_LICENSE",
+	GET_BFILE_TABLE_TOTAL_LENGTH:                       "GET_BFILE_TABLE_TOTAL_LENGTH",
+	GET_BFILE_CONTENT_TABLE_TOTAL_LENGTH:               "GET_BFILE_CONTENT_TABLE_TOTAL_LENGTH",
+	GET_BFILE_CONTENT_TABLE_EVERY_LENGTH:               "GET_BFILE_CONTENT_TABLE_EVERY_LENGTH",
+	GET_INFO_FOR_MAP_REDUCE:                            "GET_INFO_FOR_MAP_REDUCE",
+	IS_BFILE_UFS_STORE:                                 "IS_BFILE_UFS_STORE",
+	EXECUTE_STATEMENT_4_MR:                             "EXECUTE_STATEMENT_4_MR",
+	GET_TEXTINDEX_INFO:                                 "GET_TEXTINDEX_INFO",
+	DATABASE_META_DATA_GET_UNIQUE_KEYS:                 "DATABASE_META_DATA_GET_UNIQUE_KEYS",
+	RELOAD_CONFIGURATION:                               "RELOAD_CONFIGURATION",
+	CONNECTION_SET_CHECK_CONSTRAINTS:                   "CONNECTION_SET_CHECK_CONSTRAINTS",
+	TABLE_GET_RECORDS_BY_PKS:                           "TABLE_GET_RECORDS_BY_PKS",
+	DATABASE_GET_SQL_HISTORYS:                          "DATABASE_GET_SQL_HISTORYS",
+	DATABASE_SET_SHARE_QUERYAREA:                       "DATABASE_SET_SHARE_QUERYAREA",
+	DATABASE_GET_RUNTIME_REPORT:                        "DATABASE_GET_RUNTIME_REPORT",
+	UPDATE_CONFIG_OPTIONS:                              "UPDATE_CONFIG_OPTIONS",
+	DATABASE_META_DATA_GET_NETWORK_STATUS:              "DATABASE_META_DATA_GET_NETWORK_STATUS",
+	DATABASE_HEALTH_DIAGNOSTIC:                         "DATABASE_HEALTH_DIAGNOSTIC",
+	DATABASE_META_DATA_GET_COLUMNS_DEFAULT:             "DATABASE_META_DATA_GET_COLUMNS_DEFAULT",
+	DATABASE_GET_SQL_STATISTICS:                        "DATABASE_GET_SQL_STATISTICS",
+	RESULT_SET_GET_DISTRIBUTION:                        "RESULT_SET_GET_DISTRIBUTION",
+	RESULT_SET_GET_EXECUTION_STATISTICS:                "RESULT_SET_GET_EXECUTION_STATISTICS",
+	RESULT_SET_GET_TABLET_PARTITION_IDS:                "RESULT_SET_GET_TABLET_PARTITION_IDS",
+	AUTO_TABLET_APPEND:                                 "AUTO_TABLET_APPEND",
+	GET_SERVER_LOGGER:                                  "GET_SERVER_LOGGER",
+	DATABASE_COLLECT_LOGGER:                            "DATABASE_COLLECT_LOGGER",
+	DATABASE_META_DATA_GET_TABLE_TYPES:                 "DATABASE_META_DATA_GET_TABLE_TYPES",
+	SET_FULLTEXT_INDEX_IS_AND_OPERATOR:                 "SET_FULLTEXT_INDEX_IS_AND_OPERATOR",
+	GET_FULLTEXT_INDEX_IS_AND_OPERATOR:                 "GET_FULLTEXT_INDEX_IS_AND_OPERATOR",
+	DATABASE_META_DATA_GET_SYNONYM_COLUMNS:             "DATABASE_META_DATA_GET_SYNONYM_COLUMNS",
+	GET_SYNONYM_HINTS:                                  "GET_SYNONYM_HINTS",
+	CONNECTION_SET_ENABLE_SAME_COLUMN_LINK:             "CONNECTION_SET_ENABLE_SAME_COLUMN_LINK",
+	GET_ALL_DOWNLEVELS:                                 "GET_ALL_DOWNLEVELS",
+	DATABASE_META_DATA_GET_TABLE_SYNONYMS:              "DATABASE_META_DATA_GET_TABLE_SYNONYMS",
+	DATABASE_META_DATA_GET_TABLE_BASESEARCH_COLUMNS:    "DATABASE_META_DATA_GET_TABLE_BASESEARCH_COLUMNS",
+	DATABASE_META_DATA_GET_LINK_KEYS:                   "DATABASE_META_DATA_GET_LINK_KEYS",
+	GET_DOWNLEVEL_CHAINS:                               "GET_DOWNLEVEL_CHAINS",
+	DATABASE_META_DATA_GET_SCHEMA_FILES:                "DATABASE_META_DATA_GET_SCHEMA_FILES",
+	REFRESH:                                            "REFRESH",
+	SET_SCHEMA:                                         "SET_SCHEMA",
+	DATABASE_META_DATA_GET_SHARES:                      "DATABASE_META_DATA_GET_SHARES",
+	GET_ZONE_SERVERS:                                   "GET_ZONE_SERVERS",
+	CREATE_TABLET:                                      "CREATE_TABLET",
+}
+
+// CommandName returns the name of the request command cmd, such as
+// "EXECUTE_STATEMENT", or its number if it is unknown.
+func CommandName(cmd int) string {
+	if name, ok := commandNames[cmd]; ok {
+		return name
+	}
+	return strconv.Itoa(cmd)
+}
@@ -10,6 +10,7 @@ package cloudwave
 
 import (
 	//	"bytes"
+	"context"
 	"crypto/sha1"
 	"regexp"
 
@@ -62,6 +63,20 @@ func (mc *cwConn) requestServer1(requestType int, outdata []byte) ([]byte, error
 
 // Read packet to buffer 'data'
 func (mc *cwConn) readPacket() ([]byte, error) {
+	data, err := mc.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	mc.countResponse(data)
+	mc.logResponse(data)
+	if mc.trace != nil {
+		mc.traceResponse(data)
+	}
+	return data, err
+}
+
+// readFrame reads the body of a response.
+func (mc *cwConn) readFrame() ([]byte, error) {
 	var prevData []byte
 	for {
 		// read packet header
@@ -70,14 +85,19 @@ func (mc *cwConn) readPacket() ([]byte, error) {
 			if cerr := mc.canceled.Value(); cerr != nil {
 				return nil, cerr
 			}
-			errLog.Print(err)
-			mc.Close()
+			mc.logError(err)
+			mc.cleanup()
 			return nil, ErrInvalidConn
 		}
 
 		// packet length [24 bit]
 		//		pktLen := int(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16)
 		pktLen := int(binary.BigEndian.Uint32(data[0:])) - 4
+		if pktLen < 0 {
+			mc.logError(ErrMalformPkt)
+			mc.cleanup()
+			return nil, ErrInvalidConn
+		}
 
 		/*		// check packet sync [8 bit]
 				if data[3] != mc.sequence {
@@ -93,7 +113,7 @@ func (mc *cwConn) readPacket() ([]byte, error) {
 				if pktLen == 0 {
 					// there was no previous packet
 					if prevData == nil {
-						errLog.Print(ErrMalformPkt)
+						mc.logError(ErrMalformPkt)
 						mc.Close()
 						return nil, ErrInvalidConn
 					}
@@ -107,8 +127,8 @@ func (mc *cwConn) readPacket() ([]byte, error) {
 			if cerr := mc.canceled.Value(); cerr != nil {
 				return nil, cerr
 			}
-			errLog.Print(err)
-			mc.Close()
+			mc.logError(err)
+			mc.cleanup()
 			return nil, ErrInvalidConn
 		}
 
@@ -159,8 +179,9 @@ func (mc *cwConn) writePacket(data []byte) error {
 			err = connCheck(conn)
 		}
 		if err != nil {
-			errLog.Print("closing bad idle connection: ", err)
-			mc.Close()
+			mc.log(context.Background(), logLevelError, "closing bad idle connection", "err", err)
+			mc.count(counterBadConns, 1)
+			mc.cleanup()
 			return driver.ErrBadConn
 		}
 	}
@@ -177,10 +198,17 @@ func (mc *cwConn) writePacket(data []byte) error {
 		if err == nil {
 			if n == pktLen {
 				mc.sequence++
+				mc.lastCmd = requestCommand(data)
+				mc.lastSent = time.Now()
+				mc.countRequest(mc.lastCmd, data)
+				mc.logRequest(data)
+				if mc.trace != nil {
+					mc.traceRequest(data)
+				}
 				return nil
 			} else {
 				mc.cleanup()
-				errLog.Print(ErrMalformPkt)
+				mc.logError(ErrMalformPkt)
 			}
 		} else {
 			if cerr := mc.canceled.Value(); cerr != nil {
@@ -191,7 +219,7 @@ func (mc *cwConn) writePacket(data []byte) error {
 				return errBadConnNoWrite
 			}
 			mc.cleanup()
-			errLog.Print(err)
+			mc.logError(err)
 		}
 		return ErrInvalidConn
 	}
@@ -280,7 +308,7 @@ func (mc *cwConn) writeCommandPacket(command int) error {
 	data, err := mc.buf.takeSmallBuffer(25)
 	if err != nil {
 		// cannot take the buffer. Something must be wrong with the connection
-		errLog.Print(err)
+		mc.logError(err)
 		return errBadConnNoWrite
 	}
 
@@ -308,7 +336,7 @@ func (stmt *cwStmt) writeCommandPacketStr(command byte, arg string) error {
 	data, err := stmt.mc.buf.takeBuffer(pktLen)
 	if err != nil {
 		// cannot take the buffer. Something must be wrong with the connection
-		errLog.Print(err)
+		stmt.mc.logError(err)
 		return err
 	}
 
@@ -381,130 +409,110 @@ func (stmt *cwStmt) readResultSetHeaderPacket2() (int, *textRows, error) {
 	if err != nil {
 		return 0, nil, err
 	}
-	datalen := len(data)
-	if data[0] != iOK {
+	return stmt.parseResultSetHeader(data)
+}
+
+// parseResultSetHeader decodes the result set header packet data.
+func (stmt *cwStmt) parseResultSetHeader(data []byte) (int, *textRows, error) {
+	r := NewPacketReader(data, "result set header")
+	if status := r.Uint8(); status != iOK {
+		if err := r.Err(); err != nil {
+			return 0, nil, err
+		}
 		return 0, nil, stmt.mc.handleErrorPacket(data)
 	}
-	if datalen >= 29 {
-		var n int
-		rows := new(textRows)
-		rows.stmt = stmt
-		rows.rs.columns = []cwField{}
-		localSessionTime := binary.BigEndian.Uint64(data[1:])
-		localSessionSequence := binary.BigEndian.Uint64(data[9:])
-		sId := uint32(binary.BigEndian.Uint32(data[17:]))
-		//上边读到的三个值需要和 stmt 进行比较
-		if localSessionTime != stmt.mc.sessionTime || localSessionSequence != stmt.mc.sessionSequence {
-			//return 0, nil, errSReadResult
-		}
-		if sId != rows.stmt.id {
-			//return 0, nil, errSReadResult
-		}
-		rows.cursorId = int32(binary.BigEndian.Uint32(data[21:]))
-		rows.stmt.mc.affectedRows = uint64(binary.BigEndian.Uint32(data[25:]))
+	rows := new(textRows)
+	rows.stmt = stmt
+	rows.parseTime = stmt.mc.parseTime
+	rows.eagerLOBs = stmt.mc.cfg.LobMode == lobModeEager
+	rows.rs.columns = []cwField{}
+	localSessionTime := r.Uint64()
+	localSessionSequence := r.Uint64()
+	sId := r.Uint32()
+	rows.cursorId = r.Int32()
+	affectedRows := r.Uint32()
+	if err := r.Err(); err != nil {
+		return 0, nil, err
+	}
+	//上边读到的三个值需要和 stmt 进行比较
+	if localSessionTime != stmt.mc.sessionTime || localSessionSequence != stmt.mc.sessionSequence {
+		//return 0, nil, errSReadResult
+	}
+	if sId != rows.stmt.id {
+		//return 0, nil, errSReadResult
+	}
+	rows.stmt.mc.affectedRows = uint64(affectedRows)
 
-		pos := 29
-		if pos < datalen {
-			// read is query
-			rows.isQuery = data[pos]
-			pos++
-		} else {
-			return 0, rows, nil
-		}
-		if pos < datalen {
-			// read nullable correlation name
-			if data[pos] == 0 {
-				var correlationName []byte
-				correlationName, _, n, err = ReadLengthEncodedString(data[pos+1:])
-				if len(correlationName) > 0 {
+	if r.Len() == 0 {
+		return 0, rows, nil
+	}
+	// read is query
+	rows.isQuery = r.Uint8()
 
-				}
-				pos += n
-			}
-			pos++
-		} else {
-			return 0, rows, nil
-		}
-		if pos < datalen {
-			// read meta data of server cursor's all columns
-			// first read column count
-			columnSize := int(binary.BigEndian.Uint32(data[pos:]))
-			stmt.autokeyFields = make([]bool, columnSize+4)
-			autokeyFieldsNo := 0
-			pos += 4
-			// extend enough columns
-			//CI_set_num_fields(res->fields, columnSize, TRUE);
-			if pos < datalen {
-				columns := make([]cwField, columnSize)
-				// read datas into the column
-				j := 0
-				for i := 0; i < columnSize; i++ {
-					var bytes []byte
-					if data[pos] == 0 {
-						bytes, _, n, err = ReadLengthEncodedString(data[pos+1:])
-						//columns[j].name = string(bytes)
-						columns[j].tableName, columns[j].name = splitName(string(bytes))
-						pos += n
-					}
-					pos++
-					columns[j].columnHeaderFieldType = columnHeaderFieldType(binary.BigEndian.Uint32(data[pos:]))
-					columns[j].fieldType = fieldType(toCloudType(columns[j].columnHeaderFieldType))
-					pos += 4
-
-					if data[pos] == 0 {
-						bytes, _, n, err = ReadLengthEncodedString(data[pos+1:])
-						columns[j].typeName = string(bytes)
-						pos += n
-					}
-					pos++
+	if r.Len() == 0 {
+		return 0, rows, nil
+	}
+	// read nullable correlation name
+	r.SetContext("correlation name")
+	r.NullableString()
 
-					columns[j].decimals = byte(binary.BigEndian.Uint32(data[pos:]))
-					pos += 4
-					columns[j].colScale = binary.BigEndian.Uint32(data[pos:])
-					pos += 4
+	if r.Len() == 0 {
+		return 0, rows, r.Err()
+	}
+	// read meta data of server cursor's all columns
+	// first read column count
+	r.SetContext("column count")
+	columnSize := r.Count(1)
+	if err := r.Err(); err != nil {
+		return 0, nil, err
+	}
+	stmt.autokeyFields = make([]bool, columnSize+4)
+	if r.Len() == 0 {
+		return columnSize, rows, nil
+	}
+	// extend enough columns
+	//CI_set_num_fields(res->fields, columnSize, TRUE);
+	columns := make([]cwField, 0, columnSize)
+	for i := 0; i < columnSize; i++ {
+		var column cwField
+		r.SetContext(fmt.Sprintf("column %d name", i))
+		name, _ := r.NullableString()
+		column.tableName, column.name = splitName(name)
+
+		r.SetContext(fmt.Sprintf("column %d type", i))
+		column.columnHeaderFieldType = columnHeaderFieldType(r.Uint32())
+		column.fieldType = fieldType(toCloudType(column.columnHeaderFieldType))
+		column.typeName, _ = r.NullableString()
+
+		// precision, the maximum length of character and binary types
+		column.length = r.Uint32()
+		column.colScale = r.Uint32()
+		column.decimals = math.MaxUint8
+		if column.colScale < math.MaxUint8 {
+			column.decimals = byte(column.colScale)
+		}
+		column.className, _ = r.NullableString()
+		if err := r.Err(); err != nil {
+			return 0, nil, err
+		}
 
-					if data[pos] == 0 {
-						bytes, _, n, err = ReadLengthEncodedString(data[pos+1:])
-						columns[j].className = string(bytes)
-						pos += n
-					}
-					pos++
-					isautokay, _ := regexp.MatchString("__WISDOM_AUTO_KEY__$", columns[j].name)
-					if isautokay {
-						stmt.autokeyFields[autokeyFieldsNo] = true
-					} else {
-						stmt.autokeyFields[autokeyFieldsNo] = false
-						j++
-					}
-					autokeyFieldsNo++
-				}
-				if pos <= len(data) {
-					if j == columnSize {
-						rows.rs.columns = columns
-					} else {
-						columnsnew := make([]cwField, j)
-						columnsnew = columns[0:j]
-						rows.rs.columns = columnsnew
-					}
-					return columnSize, rows, nil
-				}
-				// column count
-				//			num, _, n := readLengthEncodedInteger(data)
-				return 0, nil, ErrMalformPkt
-			} else {
-				return columnSize, rows, nil
-			}
-		} else {
-			return 0, rows, nil
+		isautokay, _ := regexp.MatchString("__WISDOM_AUTO_KEY__$", column.name)
+		stmt.autokeyFields[i] = isautokay
+		if !isautokay {
+			columns = append(columns, column)
 		}
 	}
-	return 0, nil, err
+	rows.rs.columns = columns
+	return columnSize, rows, nil
 }
 
 // Result Set Header Packet
 // http://dev.cloudwave.com/doc/internals/en/com-query-response.html#packet-ProtocolText::Resultset
 func (mc *cwConn) readResultSetHeaderPacket() (int, error) {
 	data, err := mc.readPacket()
+	if err == nil && len(data) == 0 {
+		err = ErrMalformPkt
+	}
 	if err == nil {
 		switch data[0] {
 
@@ -530,7 +538,7 @@ func (mc *cwConn) readCloudResultSetPacket() ([]int64, error) {
 	data, err := mc.readPacket()
 	if err == nil {
 		datalen := len(data)
-		if data[0] == iOK && datalen >= 17 {
+		if datalen >= 17 && data[0] == iOK {
 			localSessionTime := binary.BigEndian.Uint64(data[1:])
 			localSessionSequence := binary.BigEndian.Uint64(data[9:])
 			if localSessionTime != mc.sessionTime || localSessionSequence != mc.sessionSequence {
@@ -554,27 +562,26 @@ func (mc *cwConn) readCloudResultSetPacket() ([]int64, error) {
 // Error Packet
 // http://dev.cloudwave.com/doc/internals/en/generic-response-packets.html#packet-ERR_Packet
 func (mc *cwConn) handleErrorPacket(data []byte) error {
-	if data[0] != iERR {
+	r := NewPacketReader(data, "error packet")
+	if r.Uint8() != iERR {
+		if err := r.Err(); err != nil {
+			return err
+		}
 		return ErrMalformPkt
 	}
-	pos := 1
 
 	// briefMessage
-	length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
-	pos += 4
-	briefmessage := data[pos : pos+length]
-	pos += length
+	r.SetContext("error brief message")
+	briefmessage := r.Bytes(r.Count(1))
 	// Message
-
-	length = int(binary.BigEndian.Uint32(data[pos : pos+4]))
-	pos += 4
-	message := data[pos : pos+length]
+	r.SetContext("error message")
+	message := r.Bytes(r.Count(1))
+	if err := r.Err(); err != nil {
+		return err
+	}
 
 	// Error Message [string]
-	return &CloudWaveError{
-		briefMessage: string(briefmessage),
-		Message:      string(message),
-	}
+	return newCloudWaveError(string(briefmessage), string(message))
 }
 
 func readStatus(b []byte) statusFlag {
@@ -724,93 +731,121 @@ func (rows *textRows) ResultSetRecordCount() int64 {
 	return int64(binary.BigEndian.Uint64(datain[1:9]))
 }
 
-// Read Packets as Field Packets until EOF-Packet or an Error appears
-// http://dev.cloudwave.com/doc/internals/en/com-query-response.html#packet-ProtocolText::ResultsetRow
-func (rows *textRows) readRow(dest []driver.Value) error {
+// fetch requests the next rows of the query. It returns a reader at the
+// first row, or nil at the end of the rows.
+func (rows *textRows) fetch() (*PacketReader, error) {
 	mc := rows.stmt.mc
-
-	if rows.rs.done {
-		return io.EOF
-	}
 	dataout, err := mc.buf.takeBuffer(25 + 4*3)
+	if err != nil {
+		return nil, err
+	}
 
 	pos := 25
 	binary.BigEndian.PutUint32(dataout[pos:], uint32(rows.stmt.id))
-	//	binary.BigEndian.PutUint32(dataout[pos:], uint32(rows.stmt.id))
 	pos += 4
 	binary.BigEndian.PutUint32(dataout[pos:], uint32(rows.cursorId))
-	//	binary.BigEndian.PutUint32(dataout[pos:], uint32(rows.cursorId))
 	pos += 4
-	binary.BigEndian.PutUint32(dataout[pos:], uint32(1))
+	binary.BigEndian.PutUint32(dataout[pos:], uint32(mc.fetchSize))
 	pos += 4
 	datain, err := mc.requestServer1(RESULT_SET_QUERY_NEXT, dataout[:pos])
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if datain == nil {
-		return io.EOF
+		return nil, io.EOF
 	}
 
-	if datain[0] != 1 {
+	r := NewPacketReader(datain, "row header")
+	if r.Uint8() != 1 {
+		if err := r.Err(); err != nil {
+			return nil, err
+		}
 		// server_status [2 bytes]
 		//		rows.mc.status = readStatus(data[3:])
 		rows.rs.done = true
 		if !rows.HasNextResultSet() {
 			rows.stmt.mc = nil
 		}
-		return io.EOF
+		return nil, io.EOF
 	}
 
 	// RowSet Packet
-	var n int
-	var tp byte
-	// Read bytes and convert to string
-	if datain[1] == 0 {
+	if r.Uint8() == 0 {
+		if err := r.Err(); err != nil {
+			return nil, err
+		}
 		rows.stmt.mc.status = statusNoIndexUsed
 		rows.rs.done = true
-		return io.EOF
+		return nil, io.EOF
+	}
+	size := int(r.Int32())
+	if err := r.Err(); err != nil {
+		return nil, err
 	}
-	pos = 2
-	size := int(binary.BigEndian.Uint32(datain[pos:]))
 	if size <= 0 {
+		return nil, io.EOF
+	}
+	if size == 1 {
+		return r, nil
+	}
+	// LOB requests reuse the read buffer, the rows left are kept aside
+	rows.pending = size - 1
+	return NewPacketReader(append([]byte(nil), r.Rest()...), "row"), nil
+}
+
+// Read Packets as Field Packets until EOF-Packet or an Error appears
+// http://dev.cloudwave.com/doc/internals/en/com-query-response.html#packet-ProtocolText::ResultsetRow
+func (rows *textRows) readRow(dest []driver.Value) error {
+	if rows.rs.done {
 		return io.EOF
 	}
-	pos += 4
+	var r *PacketReader
+	var err error
+	if rows.pending > 0 {
+		// the next row of the last response
+		r = NewPacketReader(rows.fetched, "row")
+		rows.pending--
+	} else if r, err = rows.fetch(); r == nil || err != nil {
+		return err
+	}
+
+	var v driver.Value
+	var n int
+	var tp byte
 	i := 0
-	autokeyFieldsNo := 0
-	for {
-		var scale int
-		if i >= len(dest) {
+	// hidden autokey columns are read and dropped, the ones ending the row
+	// included, so that r ends at the next row
+	for field := 0; i < len(dest) || rows.autokey(field); field++ {
+		r.SetContext(fmt.Sprintf("column %d", field))
+		v, tp, _, n, err = rows.readObject(r.Rest())
+		if r.advance(n, err); r.Err() != nil {
+			err = r.Err()
 			break
 		}
-		dest[i], tp, scale, n, err = rows.readObject(datain[pos:])
-		if err != nil {
-			break
+		if rows.autokey(field) {
+			continue
 		}
-
-		if tp != CLOUD_TYPE_ZONE_AUTO_SEQUENCE { // ??????
-			//i++
+		dest[i] = v
+		if dest[i] != nil && i < len(rows.rs.columns) && rows.rs.columns[i].fieldType == fieldType(CLOUD_TYPE_OTHER&0xff) {
+			rows.rs.columns[i].fieldType = fieldType(tp)
 		}
-		if !rows.stmt.autokeyFields[autokeyFieldsNo] {
-			i++
+		// LOB handles are decoded once resolved.
+		if _, ok := dest[i].(lobHandle); !ok {
+			if dest[i], err = rows.stmt.mc.cfg.decodeValue(tp, dest[i]); err != nil {
+				return err
+			}
 		}
-		autokeyFieldsNo++
-		pos += n
-		scale = scale
-		/*
-		   		// Parse time field
-		   //		rows.rs.columns[i].fieldType = tp
-		   		switch tp {
-		   		case CLOUD_TYPE_DATE,
-		   			CLOUD_TYPE_TIME,
-		   			CLOUD_TYPE_TIMESTAMP:
-		   			if dest[i], err = parseDateTime(dest[i].([]byte), mc.cfg.Loc); err != nil {
-		   				return err
-		   			}
-		   		}
-		*/
+		i++
 	}
-	return nil
+	if err != nil {
+		return err
+	}
+	if rows.pending > 0 {
+		rows.fetched = r.Rest()
+	}
+	// LOB requests reuse the read buffer, so they must wait until the
+	// whole row has been decoded.
+	return rows.resolveLOBs(dest)
 }
 
 // Reads Packets until EOF-Packet or an Error appears. Returns count of Packets read
@@ -845,17 +880,30 @@ func (stmt *cwStmt) readPrepareResultPacket() (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	if data[0] != iOK {
+	return stmt.parsePrepareResult(data)
+}
+
+// parsePrepareResult decodes the prepare result packet data.
+func (stmt *cwStmt) parsePrepareResult(data []byte) (int, error) {
+	r := NewPacketReader(data, "prepare result")
+	if r.Uint8() != iOK {
+		if err := r.Err(); err != nil {
+			return 0, err
+		}
 		return 0, stmt.mc.handleErrorPacket(data)
 	}
-	stmt.id = binary.BigEndian.Uint32(data[1:])
+	id := r.Uint32()
+	bindVarCount := r.Count(1)
+	paramType := append([]byte{}, r.Bytes(bindVarCount)...)
+	cursorId := r.Int32()
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+	stmt.id = id
 	stmt.executeSequence = 0
-	bindVarCount := int(binary.BigEndian.Uint32(data[5:]))
 	stmt.paramCount = bindVarCount
-	buf := make([]byte, bindVarCount)
-	copy(buf, data[9:9+bindVarCount])
-	stmt.paramType = buf
-	stmt.cursorId = int32(binary.BigEndian.Uint32(data[9+bindVarCount:]))
+	stmt.paramType = paramType
+	stmt.cursorId = cursorId
 	return bindVarCount, nil
 }
 
@@ -870,6 +918,15 @@ func (stmt *cwStmt) writeExecutePacket(executeType int, args []driver.Value) err
 		)
 	}
 
+	args, err := stmt.convertArgs(args)
+	if err != nil {
+		return err
+	}
+	args, err = stmt.uploadLOBs(args)
+	if err != nil {
+		return err
+	}
+
 	const minPktLen = 25 + 20
 	mc := stmt.mc
 
@@ -883,7 +940,6 @@ func (stmt *cwStmt) writeExecutePacket(executeType int, args []driver.Value) err
 	mc.sequence = 0
 
 	var data []byte
-	var err error
 
 	if len(args) == 0 {
 		data, err = mc.buf.takeBuffer(minPktLen)
@@ -893,7 +949,7 @@ func (stmt *cwStmt) writeExecutePacket(executeType int, args []driver.Value) err
 	}
 	if err != nil {
 		// cannot take the buffer. Something must be wrong with the connection
-		errLog.Print(err)
+		stmt.mc.logError(err)
 		return err
 	}
 
@@ -915,7 +971,7 @@ func (stmt *cwStmt) writeExecutePacket(executeType int, args []driver.Value) err
 		for i, arg := range args {
 			binary.BigEndian.PutUint32(data[pos:], uint32(i+1))
 			pos += 4
-			n, err := stmt.writeObject(arg, stmt.paramType[i], 10, data[pos:])
+			n, err := stmt.writeObject(arg, stmt.paramType[i], defaultDecimalScale, data[pos:])
 			if err != nil {
 				return err
 			}
@@ -938,6 +994,15 @@ func (stmt *cwStmt) writeTxBatchExecutePacket(args []driver.Value) error {
 		)
 	}
 
+	args, err := stmt.convertArgs(args)
+	if err != nil {
+		return err
+	}
+	args, err = stmt.uploadLOBs(args)
+	if err != nil {
+		return err
+	}
+
 	const minPktLen = 25 + 20
 	mc := stmt.mc
 
@@ -951,7 +1016,6 @@ func (stmt *cwStmt) writeTxBatchExecutePacket(args []driver.Value) error {
 	mc.sequence = 0
 
 	var data []byte
-	var err error
 
 	if len(args) == 0 {
 		data, err = mc.buf.takeBuffer(minPktLen)
@@ -961,7 +1025,7 @@ func (stmt *cwStmt) writeTxBatchExecutePacket(args []driver.Value) error {
 	}
 	if err != nil {
 		// cannot take the buffer. Something must be wrong with the connection
-		errLog.Print(err)
+		stmt.mc.logError(err)
 		return err
 	}
 
@@ -981,7 +1045,7 @@ func (stmt *cwStmt) writeTxBatchExecutePacket(args []driver.Value) error {
 
 	if len(args) > 0 {
 		for i, arg := range args {
-			n, err := stmt.writeObject(arg, stmt.paramType[i], 10, data[pos:])
+			n, err := stmt.writeObject(arg, stmt.paramType[i], defaultDecimalScale, data[pos:])
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,342 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+var (
+	// errParamOutOfRange is returned for numbers that don't fit the parameter
+	// type.
+	errParamOutOfRange = errors.New("value out of range")
+
+	// errUnsupportedType is returned for parameters of types the driver
+	// can't send, such as BFILE.
+	errUnsupportedType = errors.New("unsupported type")
+)
+
+// paramConverter converts the values bound to a parameter of a prepared
+// statement to its type.
+type paramConverter struct {
+	stmt *cwStmt
+	idx  int
+}
+
+func (c paramConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	tp := noParamType
+	if c.idx >= 0 && c.idx < len(c.stmt.paramType) {
+		tp = c.stmt.paramType[c.idx]
+	}
+	dv, err := c.stmt.mc.cfg.convertValue(v, tp)
+	if err != nil || tp == noParamType {
+		return dv, err
+	}
+	return c.stmt.convertParam(c.idx, dv)
+}
+
+// convertArgs converts args to the types of the parameters, returning a copy
+// if any of them changed.
+func (stmt *cwStmt) convertArgs(args []driver.Value) ([]driver.Value, error) {
+	var out []driver.Value
+	for i, arg := range args {
+		v, err := stmt.convertParam(i, arg)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			if sameValue(v, arg) {
+				continue
+			}
+			out = make([]driver.Value, len(args))
+			copy(out, args)
+		}
+		out[i] = v
+	}
+	if out == nil {
+		return args, nil
+	}
+	return out, nil
+}
+
+// sameValue reports whether convertParam returned its argument unchanged.
+func sameValue(a, b driver.Value) bool {
+	switch a.(type) {
+	case nil, int64, uint64, float64, bool, string, time.Time:
+		return a == b
+	}
+	return false
+}
+
+// convertParam converts arg, a value returned by converter.ConvertValue, to
+// the Go type writeObject encodes for the parameter idx (0-based). Values of
+// the temporal, interval, decimal, LOB, array and JSON types are checked when
+// they are encoded.
+func (stmt *cwStmt) convertParam(idx int, arg driver.Value) (driver.Value, error) {
+	if arg == nil || idx >= len(stmt.paramType) {
+		return arg, nil
+	}
+	tp := stmt.paramType[idx]
+	if isJSONType(tp) {
+		if err := stmt.mc.require(FeatureJSON); err != nil {
+			return nil, err
+		}
+	}
+	v, err := coerceParam(arg, tp, stmt.mc.cfg.Loc)
+	if err != nil {
+		return nil, fmt.Errorf("cloudwave: can't convert parameter %d from %T to %s: %w", idx+1, arg, getTypeName(tp), err)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("cloudwave: can't convert parameter %d from %T to %s", idx+1, arg, getTypeName(tp))
+	}
+	return v, nil
+}
+
+// coerceParam converts arg to the Go type of tp. It returns nil without an
+// error if the types are incompatible, and errUnsupportedType if tp can't be
+// sent.
+func coerceParam(arg driver.Value, tp byte, loc *time.Location) (driver.Value, error) {
+	switch tp {
+	case CLOUD_TYPE_TINY_DECIMAL, CLOUD_TYPE_SMALL_DECIMAL, CLOUD_TYPE_BIG_DECIMAL,
+		CLOUD_TYPE_DATE, CLOUD_TYPE_TIME, CLOUD_TYPE_TIMESTAMP,
+		CLOUD_TYPE_YEAR_MONTH_DAY, CLOUD_TYPE_COMPACT_DATE,
+		CLOUD_TYPE_DAY_TIME_INTERVAL, CLOUD_TYPE_TIME_INTERVAL, CLOUD_TYPE_YEAR_MONTH_INTERVAL,
+		CLOUD_TYPE_YEAR_MONTH, CLOUD_TYPE_FISCAL_YEAR, CLOUD_TYPE_FISCAL_QUARTER,
+		CLOUD_TYPE_BLOB, CLOUD_TYPE_CLOB, CLOUD_TYPE_ARRAY, CLOUD_TYPE_INTS, CLOUD_TYPE_X_BYTES,
+		CLOUD_TYPE_JSON_OBJECT, CLOUD_TYPE_JSON_ARRAY, CLOUD_TYPE_JSON_TEXT,
+		CLOUD_TYPE_JSON_BINARY, CLOUD_TYPE_JSON_KEYWORD, CLOUD_TYPE_JSON_BIGDECIMAL:
+		// checked by writeObject
+		return arg, nil
+
+	case CLOUD_TYPE_SINGLE_CHAR, CLOUD_TYPE_CHAR, CLOUD_TYPE_VARCHAR:
+		return paramString(arg, loc), nil
+
+	case CLOUD_TYPE_BINARY, CLOUD_TYPE_VARBINARY:
+		switch v := arg.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+		return nil, nil
+
+	case CLOUD_TYPE_SINGLE_BYTE:
+		switch v := arg.(type) {
+		case []byte:
+			if len(v) != 1 {
+				return nil, fmt.Errorf("%w: %d bytes", errParamOutOfRange, len(v))
+			}
+			return v, nil
+		case string:
+			if len(v) != 1 {
+				return nil, fmt.Errorf("%w: %d bytes", errParamOutOfRange, len(v))
+			}
+			return []byte(v), nil
+		}
+		n, ok, err := paramInt(arg)
+		if !ok || err != nil {
+			return nil, err
+		}
+		if n < 0 || n > math.MaxUint8 {
+			return nil, fmt.Errorf("%w: %d", errParamOutOfRange, n)
+		}
+		return []byte{byte(n)}, nil
+
+	case CLOUD_TYPE_INTEGER, CLOUD_TYPE_TINY_INTEGER:
+		n, ok, err := paramInt(arg)
+		if !ok || err != nil {
+			return nil, err
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("%w: %d", errParamOutOfRange, n)
+		}
+		return n, nil
+
+	case CLOUD_TYPE_LONG, CLOUD_TYPE_SMALL_INTEGER:
+		n, ok, err := paramInt(arg)
+		if !ok || err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case CLOUD_TYPE_FLOAT, CLOUD_TYPE_DOUBLE:
+		f, ok, err := paramFloat(arg)
+		if !ok || err != nil {
+			return nil, err
+		}
+		if tp == CLOUD_TYPE_FLOAT && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			return nil, fmt.Errorf("%w: %g", errParamOutOfRange, f)
+		}
+		return f, nil
+
+	case CLOUD_TYPE_BOOLEAN:
+		switch v := arg.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		case uint64:
+			return v != 0, nil
+		case float64:
+			return v != 0, nil
+		case string:
+			return strconv.ParseBool(v)
+		case []byte:
+			return strconv.ParseBool(string(v))
+		}
+		return nil, nil
+
+	case CLOUD_TYPE_BIG_INTEGER:
+		switch v := arg.(type) {
+		case uint64:
+			if v > math.MaxInt64 {
+				return strconv.FormatUint(v, 10), nil
+			}
+		case string, []byte:
+			var s string
+			if b, ok := v.([]byte); ok {
+				s = string(b)
+			} else {
+				s = v.(string)
+			}
+			if _, ok := new(big.Int).SetString(s, 10); !ok {
+				return nil, fmt.Errorf("invalid integer %q", s)
+			}
+			return s, nil
+		case *big.Int:
+			if v.IsInt64() {
+				return v.Int64(), nil
+			}
+			return v.String(), nil
+		case float64:
+			if v != math.Trunc(v) || math.IsInf(v, 0) || math.IsNaN(v) {
+				return nil, fmt.Errorf("%w: %g is not an integer", errParamOutOfRange, v)
+			}
+			if v >= math.MinInt64 && v < math.MaxInt64 {
+				return int64(v), nil
+			}
+			bi, _ := big.NewFloat(v).Int(nil)
+			return bi.String(), nil
+		}
+		n, ok, err := paramInt(arg)
+		if !ok || err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, errUnsupportedType
+}
+
+// paramString formats arg as text, or returns nil if it has no text form.
+func paramString(arg driver.Value, loc *time.Location) driver.Value {
+	switch v := arg.(type) {
+	case string, []byte:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if loc != nil {
+			v = v.In(loc)
+		}
+		b, err := appendDateTime(nil, v)
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	case *big.Int:
+		return v.String()
+	case *big.Rat:
+		return v.RatString()
+	}
+	return nil
+}
+
+// paramInt converts arg to an int64. ok is false if the types are
+// incompatible.
+func paramInt(arg driver.Value) (n int64, ok bool, err error) {
+	switch v := arg.(type) {
+	case int64:
+		return v, true, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, true, fmt.Errorf("%w: %d", errParamOutOfRange, v)
+		}
+		return int64(v), true, nil
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, true, fmt.Errorf("%w: %g is not an int64", errParamOutOfRange, v)
+		}
+		return int64(v), true, nil
+	case bool:
+		if v {
+			return 1, true, nil
+		}
+		return 0, true, nil
+	case string:
+		n, err = strconv.ParseInt(v, 10, 64)
+		return n, true, err
+	case []byte:
+		n, err = strconv.ParseInt(string(v), 10, 64)
+		return n, true, err
+	case *big.Int:
+		if !v.IsInt64() {
+			return 0, true, fmt.Errorf("%w: %s", errParamOutOfRange, v)
+		}
+		return v.Int64(), true, nil
+	case *big.Rat:
+		if !v.IsInt() || !v.Num().IsInt64() {
+			return 0, true, fmt.Errorf("%w: %s is not an int64", errParamOutOfRange, v.RatString())
+		}
+		return v.Num().Int64(), true, nil
+	}
+	return 0, false, nil
+}
+
+// paramFloat converts arg to a float64. ok is false if the types are
+// incompatible.
+func paramFloat(arg driver.Value) (f float64, ok bool, err error) {
+	switch v := arg.(type) {
+	case float64:
+		return v, true, nil
+	case int64:
+		return float64(v), true, nil
+	case uint64:
+		return float64(v), true, nil
+	case bool:
+		if v {
+			return 1, true, nil
+		}
+		return 0, true, nil
+	case string:
+		f, err = strconv.ParseFloat(v, 64)
+		return f, true, err
+	case []byte:
+		f, err = strconv.ParseFloat(string(v), 64)
+		return f, true, err
+	case *big.Int:
+		f, _ = new(big.Float).SetInt(v).Float64()
+		return f, true, nil
+	case *big.Rat:
+		f, _ = v.Float64()
+		return f, true, nil
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,155 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoerceParam(t *testing.T) {
+	ts := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		tp   byte
+		arg  driver.Value
+		want driver.Value
+	}{
+		{CLOUD_TYPE_VARCHAR, int64(-42), "-42"},
+		{CLOUD_TYPE_VARCHAR, uint64(math.MaxUint64), "18446744073709551615"},
+		{CLOUD_TYPE_CHAR, 1.5, "1.5"},
+		{CLOUD_TYPE_VARCHAR, true, "true"},
+		{CLOUD_TYPE_VARCHAR, ts, "2023-05-06 07:08:09"},
+		{CLOUD_TYPE_VARCHAR, big.NewInt(7), "7"},
+		{CLOUD_TYPE_VARBINARY, "ab", []byte("ab")},
+		{CLOUD_TYPE_SINGLE_BYTE, int64(255), []byte{255}},
+		{CLOUD_TYPE_INTEGER, "123", int64(123)},
+		{CLOUD_TYPE_INTEGER, 3.0, int64(3)},
+		{CLOUD_TYPE_LONG, uint64(9), int64(9)},
+		{CLOUD_TYPE_LONG, true, int64(1)},
+		{CLOUD_TYPE_DOUBLE, "2.5", 2.5},
+		{CLOUD_TYPE_DOUBLE, int64(2), 2.0},
+		{CLOUD_TYPE_FLOAT, big.NewRat(1, 4), 0.25},
+		{CLOUD_TYPE_BOOLEAN, int64(2), true},
+		{CLOUD_TYPE_BOOLEAN, "false", false},
+		{CLOUD_TYPE_BIG_INTEGER, uint64(math.MaxUint64), "18446744073709551615"},
+		{CLOUD_TYPE_BIG_INTEGER, 1e20, "100000000000000000000"},
+		{CLOUD_TYPE_BIG_INTEGER, []byte("-5"), "-5"},
+		{CLOUD_TYPE_BIG_INTEGER, int64(5), int64(5)},
+		{CLOUD_TYPE_TIMESTAMP, ts, ts},
+	}
+	for _, tt := range tests {
+		got, err := coerceParam(tt.arg, tt.tp, time.UTC)
+		if err != nil {
+			t.Errorf("%T to %s: %v", tt.arg, getTypeName(tt.tp), err)
+			continue
+		}
+		if b, ok := tt.want.([]byte); ok {
+			if gb, ok := got.([]byte); !ok || !bytes.Equal(gb, b) {
+				t.Errorf("%T to %s: got %#v, want %#v", tt.arg, getTypeName(tt.tp), got, tt.want)
+			}
+		} else if got != tt.want {
+			t.Errorf("%T to %s: got %#v, want %#v", tt.arg, getTypeName(tt.tp), got, tt.want)
+		}
+	}
+}
+
+func TestConvertArgsErrors(t *testing.T) {
+	stmt := &cwStmt{
+		mc:        &cwConn{cfg: NewConfig()},
+		paramType: []byte{CLOUD_TYPE_VARCHAR, CLOUD_TYPE_INTEGER, CLOUD_TYPE_DOUBLE},
+	}
+	tests := []struct {
+		args []driver.Value
+		msg  string
+	}{
+		{[]driver.Value{"a", "x", 1.0}, "parameter 2 from string to "},
+		{[]driver.Value{"a", int64(math.MaxInt32 + 1), 1.0}, "parameter 2 from int64"},
+		{[]driver.Value{"a", int64(1), time.Now()}, "parameter 3 from time.Time to "},
+		{[]driver.Value{"a", 1.5, 1.0}, "is not an int64"},
+	}
+	for _, tt := range tests {
+		_, err := stmt.convertArgs(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.args, tt.msg, err)
+		}
+	}
+	if _, err := stmt.convertArgs([]driver.Value{"a", int64(math.MaxInt32 + 1), 1.0}); !errors.Is(err, errParamOutOfRange) {
+		t.Errorf("expected errParamOutOfRange, got %v", err)
+	}
+
+	args := []driver.Value{"a", int64(1), 1.0}
+	got, err := stmt.convertArgs(args)
+	if err != nil || &got[0] != &args[0] {
+		t.Errorf("expected args to be returned unchanged, got %v, %v", got, err)
+	}
+	got, err = stmt.convertArgs([]driver.Value{int64(7), nil, "2"})
+	if err != nil || got[0] != "7" || got[1] != nil || got[2] != 2.0 {
+		t.Errorf("unexpected conversion %v, %v", got, err)
+	}
+
+	nv := &driver.NamedValue{Ordinal: 2, Value: "12"}
+	if err := stmt.CheckNamedValue(nv); err != nil || nv.Value != int64(12) {
+		t.Errorf("unexpected CheckNamedValue result %v, %v", nv.Value, err)
+	}
+	if v, err := stmt.ColumnConverter(0).ConvertValue(int32(3)); err != nil || v != "3" {
+		t.Errorf("unexpected ColumnConverter result %v, %v", v, err)
+	}
+}
+
+func TestWriteObjectTypeMismatch(t *testing.T) {
+	stmt := &cwStmt{mc: &cwConn{cfg: NewConfig()}}
+	data := make([]byte, 64)
+	for _, tt := range []struct {
+		arg driver.Value
+		tp  byte
+	}{
+		{"x", CLOUD_TYPE_DOUBLE},
+		{int64(1), CLOUD_TYPE_VARCHAR},
+		{[]byte{}, CLOUD_TYPE_SINGLE_BYTE},
+		{1.0, CLOUD_TYPE_LONG},
+	} {
+		if _, err := stmt.writeObject(tt.arg, tt.tp, 0, data); err == nil {
+			t.Errorf("%T to %s: expected error", tt.arg, getTypeName(tt.tp))
+		}
+	}
+}
+
+func TestUnsupportedParamType(t *testing.T) {
+	stmt := &cwStmt{
+		mc:        &cwConn{cfg: NewConfig()},
+		paramType: []byte{CLOUD_TYPE_VARCHAR, CLOUD_TYPE_BFILE, CLOUD_TYPE_NULL},
+	}
+	for i, arg := range []driver.Value{[]byte("file"), "file"} {
+		args := []driver.Value{"a", nil, nil}
+		args[1+i] = arg
+		_, err := stmt.convertArgs(args)
+		if !errors.Is(err, errUnsupportedType) || !strings.Contains(err.Error(), fmt.Sprintf("parameter %d ", 2+i)) {
+			t.Errorf("parameter %d of type %d: expected unsupported type error, got %v", 2+i, stmt.paramType[1+i], err)
+		}
+	}
+	// NULL can be bound to any parameter
+	if _, err := stmt.convertArgs([]driver.Value{"a", nil, nil}); err != nil {
+		t.Errorf("NULL parameters: %v", err)
+	}
+
+	data := make([]byte, 64)
+	for _, tp := range []byte{CLOUD_TYPE_BFILE, CLOUD_TYPE_NULL, 0xfe} {
+		n, err := stmt.writeObject([]byte("file"), tp, 0, data)
+		if !errors.Is(err, errUnsupportedType) || n != 0 {
+			t.Errorf("type %d: wrote %d bytes, %v", tp, n, err)
+		}
+	}
+}
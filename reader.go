@@ -0,0 +1,199 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// PacketReader decodes the big-endian fields of a packet sent by the server
+// with bounds checks. The first read past the end of the packet records an
+// error wrapping ErrMalformPkt, which names the offset and the field being
+// read. Later reads return zero values, so a decoder can read a sequence of
+// fields and check Err once.
+type PacketReader struct {
+	buf []byte
+	pos int
+	ctx string
+	err error
+}
+
+// NewPacketReader returns a PacketReader of buf. context describes the
+// packet in errors.
+func NewPacketReader(buf []byte, context string) *PacketReader {
+	return &PacketReader{buf: buf, ctx: context}
+}
+
+// SetContext sets the description of the field being read, used in errors.
+func (r *PacketReader) SetContext(context string) {
+	r.ctx = context
+}
+
+// Err returns the first error of the reader.
+func (r *PacketReader) Err() error {
+	return r.err
+}
+
+// Pos returns the offset of the next read.
+func (r *PacketReader) Pos() int {
+	return r.pos
+}
+
+// Len returns the number of unread bytes.
+func (r *PacketReader) Len() int {
+	return len(r.buf) - r.pos
+}
+
+// Rest returns the unread bytes without consuming them.
+func (r *PacketReader) Rest() []byte {
+	if r.err != nil {
+		return nil
+	}
+	return r.buf[r.pos:]
+}
+
+// fail records err, annotated with the context and offset of the read.
+func (r *PacketReader) fail(err error) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%s at offset %d: %w", r.ctx, r.pos, err)
+	}
+}
+
+// need reports whether n more bytes can be read, recording an error if not.
+func (r *PacketReader) need(n int) bool {
+	if r.err != nil {
+		return false
+	}
+	if n < 0 || n > len(r.buf)-r.pos {
+		r.fail(fmt.Errorf("%w: need %d bytes, have %d", ErrMalformPkt, n, len(r.buf)-r.pos))
+		return false
+	}
+	return true
+}
+
+// advance consumes the n bytes a decoder of Rest has read, or records its
+// error.
+func (r *PacketReader) advance(n int, err error) {
+	if err != nil {
+		r.fail(err)
+		return
+	}
+	if r.need(n) {
+		r.pos += n
+	}
+}
+
+// Skip consumes n bytes.
+func (r *PacketReader) Skip(n int) {
+	if r.need(n) {
+		r.pos += n
+	}
+}
+
+// Bytes consumes and returns n bytes. The result aliases the packet.
+func (r *PacketReader) Bytes(n int) []byte {
+	if !r.need(n) {
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// Uint8 consumes a byte.
+func (r *PacketReader) Uint8() byte {
+	if !r.need(1) {
+		return 0
+	}
+	v := r.buf[r.pos]
+	r.pos++
+	return v
+}
+
+// Uint16 consumes a 2-byte integer.
+func (r *PacketReader) Uint16() uint16 {
+	if !r.need(2) {
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.buf[r.pos:])
+	r.pos += 2
+	return v
+}
+
+// Uint32 consumes a 4-byte integer.
+func (r *PacketReader) Uint32() uint32 {
+	if !r.need(4) {
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf[r.pos:])
+	r.pos += 4
+	return v
+}
+
+// Uint64 consumes an 8-byte integer.
+func (r *PacketReader) Uint64() uint64 {
+	if !r.need(8) {
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.buf[r.pos:])
+	r.pos += 8
+	return v
+}
+
+// Int32 consumes a 4-byte signed integer.
+func (r *PacketReader) Int32() int32 {
+	return int32(r.Uint32())
+}
+
+// Int64 consumes an 8-byte signed integer.
+func (r *PacketReader) Int64() int64 {
+	return int64(r.Uint64())
+}
+
+// Float64 consumes an 8-byte IEEE 754 number.
+func (r *PacketReader) Float64() float64 {
+	return math.Float64frombits(r.Uint64())
+}
+
+// Count consumes a 4-byte element count. Counts that are negative, or that
+// exceed the unread bytes for elements of at least minSize bytes, record an
+// error, so that a corrupt count can't cause a huge allocation.
+func (r *PacketReader) Count(minSize int) int {
+	v := r.Int32()
+	if r.err != nil {
+		return 0
+	}
+	if v < 0 || (minSize > 0 && int64(v)*int64(minSize) > int64(r.Len())) {
+		r.pos -= 4
+		r.fail(fmt.Errorf("%w: invalid count %d", ErrMalformPkt, v))
+		return 0
+	}
+	return int(v)
+}
+
+// String consumes a string sent as a 4-byte length followed by its bytes.
+// Lengths of zero or less denote the empty string.
+func (r *PacketReader) String() string {
+	n := r.Int32()
+	if n <= 0 {
+		return ""
+	}
+	return string(r.Bytes(int(n)))
+}
+
+// NullableString consumes a null flag byte, followed by a string if the flag
+// is zero.
+func (r *PacketReader) NullableString() (string, bool) {
+	if r.Uint8() != 0 {
+		return "", false
+	}
+	return r.String(), r.err == nil
+}
@@ -0,0 +1,147 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestPacketReader(t *testing.T) {
+	r := NewPacketReader([]byte{1, 0, 0, 0, 2, 'h', 'i', 0, 0}, "test packet")
+	if v := r.Uint8(); v != 1 {
+		t.Errorf("Uint8: got %d", v)
+	}
+	if s := r.String(); s != "hi" {
+		t.Errorf("String: got %q", s)
+	}
+	if v := r.Uint32(); v != 0 || r.Err() == nil {
+		t.Fatalf("expected error reading past the end, got %d", v)
+	}
+	err := r.Err()
+	if !errors.Is(err, ErrMalformPkt) || !strings.Contains(err.Error(), "test packet at offset 7") {
+		t.Errorf("unexpected error %q", err)
+	}
+	// later reads keep the first error
+	r.Uint8()
+	if r.Err() != err {
+		t.Errorf("error changed to %q", r.Err())
+	}
+
+	r = NewPacketReader([]byte{0x7f, 0xff, 0xff, 0xff, 0}, "count")
+	if n := r.Count(1); n != 0 || !errors.Is(r.Err(), ErrMalformPkt) {
+		t.Errorf("expected error for huge count, got %d, %v", n, r.Err())
+	}
+}
+
+// truncations returns every proper prefix of b.
+func truncations(b []byte) [][]byte {
+	var out [][]byte
+	for i := 0; i < len(b); i++ {
+		out = append(out, b[:i])
+	}
+	return out
+}
+
+func TestReadObjectTruncated(t *testing.T) {
+	values := [][]byte{
+		{0, CLOUD_TYPE_INTEGER, 0, 0, 0, 7},
+		{0, CLOUD_TYPE_VARCHAR, 0, 0, 0, 2, 0, 'h', 0, 'i'},
+		{0, CLOUD_TYPE_VARBINARY, 0, 0, 0, 2, 1, 2},
+		{0, CLOUD_TYPE_BIG_INTEGER, 1, 2, 0x04, 0xd2},
+		{0, CLOUD_TYPE_BLOB, 0, 0, 0, 0, 0, 0, 0, 1},
+		{0, CLOUD_TYPE_INTS, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 2},
+		jsonFixture(
+			[]byte{0, CLOUD_TYPE_JSON_OBJECT, 0, 0, 0, 2},
+			[]byte{0, 0, 0, 1, 'a'}, []byte{0, CLOUD_TYPE_LONG, 0, 0, 0, 0, 0, 0, 0, 1},
+			[]byte{0, 0, 0, 1, 'b'}, []byte{0, CLOUD_TYPE_JSON_ARRAY, 0, 0, 0, 1, 0, CLOUD_TYPE_BOOLEAN, 1},
+		),
+	}
+	rows := &textRows{cwRows: cwRows{stmt: &cwStmt{mc: &cwConn{cfg: NewConfig()}}}}
+	for _, v := range values {
+		if _, _, _, n, err := rows.readObject(v); err != nil || n != len(v) {
+			t.Fatalf("% x: read %d bytes, %v", v, n, err)
+		}
+		for _, b := range truncations(v) {
+			if _, _, _, _, err := rows.readObject(b); !errors.Is(err, ErrMalformPkt) {
+				t.Errorf("% x: expected ErrMalformPkt, got %v", b, err)
+			}
+		}
+	}
+}
+
+func TestParsePacketsTruncated(t *testing.T) {
+	header := jsonFixture(
+		[]byte{iOK},
+		make([]byte, 8+8),                                          // session time and sequence
+		[]byte{0, 0, 0, 1}, []byte{0, 0, 0, 2}, []byte{0, 0, 0, 0}, // statement, cursor, affected rows
+		[]byte{1},          // is query
+		[]byte{1},          // null correlation name
+		[]byte{0, 0, 0, 1}, // 1 column
+		[]byte{0, 0, 0, 0, 4, 't', '.', 'i', 'd'}, []byte{0xff, 0xff, 0xff, 0xfb}, // t.id BIGINT
+		[]byte{0, 0, 0, 0, 6, 'B', 'I', 'G', 'I', 'N', 'T'},
+		[]byte{0, 0, 0, 19}, []byte{0, 0, 0, 0},
+		[]byte{1}, // null class name
+	)
+	prepare := []byte{iOK, 0, 0, 0, 1, 0, 0, 0, 2, CLOUD_TYPE_LONG, CLOUD_TYPE_VARCHAR, 0, 0, 0, 3}
+	errPacket := []byte{iERR, 0, 0, 0, 1, 'e', 0, 0, 0, 3, 'b', 'a', 'd'}
+
+	stmt := &cwStmt{mc: &cwConn{cfg: NewConfig()}}
+	n, rows, err := stmt.parseResultSetHeader(header)
+	if err != nil || n != 1 || len(rows.rs.columns) != 1 || rows.rs.columns[0].name != "id" {
+		t.Fatalf("unexpected header %d, %v, %v", n, rows, err)
+	}
+	if n, err := stmt.parsePrepareResult(prepare); err != nil || n != 2 || stmt.cursorId != 3 {
+		t.Fatalf("unexpected prepare result %d, %v", n, err)
+	}
+	var cwErr *CloudWaveError
+	if err := stmt.mc.handleErrorPacket(errPacket); !errors.As(err, &cwErr) || cwErr.Message != "bad" {
+		t.Fatalf("unexpected error packet result %v", err)
+	}
+
+	for _, b := range truncations(header) {
+		// a header may end after the affected rows, the is-query flag or the
+		// correlation name
+		if _, _, err := stmt.parseResultSetHeader(b); err != nil && !errors.Is(err, ErrMalformPkt) {
+			t.Errorf("% x: unexpected error %v", b, err)
+		} else if err == nil && len(b) > 31 {
+			t.Errorf("% x: expected ErrMalformPkt", b)
+		}
+	}
+	for _, b := range truncations(prepare) {
+		if _, err := stmt.parsePrepareResult(b); !errors.Is(err, ErrMalformPkt) {
+			t.Errorf("% x: expected ErrMalformPkt, got %v", b, err)
+		}
+	}
+	for _, b := range truncations(errPacket) {
+		if err := stmt.mc.handleErrorPacket(b); !errors.Is(err, ErrMalformPkt) {
+			t.Errorf("% x: expected ErrMalformPkt, got %v", b, err)
+		}
+	}
+}
+
+func TestDecodersRandomInput(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	stmt := &cwStmt{mc: &cwConn{cfg: NewConfig()}}
+	rows := &textRows{cwRows: cwRows{stmt: stmt}}
+	for i := 0; i < 20000; i++ {
+		b := make([]byte, rnd.Intn(48))
+		rnd.Read(b)
+		if len(b) > 1 && i%2 == 0 {
+			// favour valid types and status bytes
+			b[0], b[1] = byte(i%4)&1, byte(rnd.Intn(80))
+		}
+		stmt.parseResultSetHeader(b)
+		stmt.parsePrepareResult(b)
+		stmt.mc.handleErrorPacket(b)
+		rows.readObject(b)
+	}
+}
@@ -0,0 +1,121 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy makes connections run statements again after transient
+// failures, the ones IsRetryable reports, such as a lost connection during
+// a failover. Only statements the driver classifies as queries are retried,
+// as running them twice changes no data. Statements aren't retried inside
+// transactions begun with BeginTx, nor once the rows of a query were
+// returned.
+//
+// A lost connection is opened again before the retry, with the session set
+// up as on connecting: session state changed with statements is lost.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts, the first one included; below 2, statements aren't retried
+	Backoff     time.Duration // Wait before the first retry, doubled before each next one
+}
+
+// String returns the policy as in a DSN: the attempts and the backoff.
+func (p RetryPolicy) String() string {
+	return strconv.Itoa(p.MaxAttempts) + "," + p.Backoff.String()
+}
+
+// parseRetryPolicy parses a policy formatted by RetryPolicy.String.
+func parseRetryPolicy(value string) (p RetryPolicy, err error) {
+	attempts, backoff, ok := strings.Cut(value, ",")
+	if !ok {
+		return p, errors.New("invalid retryPolicy value: " + value)
+	}
+	if p.MaxAttempts, err = strconv.Atoi(attempts); err != nil || p.MaxAttempts < 1 {
+		return p, errors.New("invalid retryPolicy value: " + value)
+	}
+	if p.Backoff, err = time.ParseDuration(backoff); err != nil || p.Backoff < 0 {
+		return p, errors.New("invalid retryPolicy value: " + value)
+	}
+	return p, nil
+}
+
+// retry runs op, the statement query, and runs it again while it fails
+// with a transient error, as the retry policy allows.
+func (mc *cwConn) retry(ctx context.Context, query string, op func() error) error {
+	err := op()
+	policy := mc.cfg.RetryPolicy
+	if err == nil || policy.MaxAttempts < 2 || !classifyStatement(query).idempotent() {
+		return err
+	}
+	backoff := policy.Backoff
+	for attempt := 2; attempt <= policy.MaxAttempts && !mc.inTx && ctx.Err() == nil && IsRetryable(err); attempt++ {
+		mc.log(ctx, logLevelWarn, "retrying statement", "attempt", attempt, "err", err)
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+			backoff *= 2
+		}
+		mc.count(counterRetries, 1)
+		if mc.closed.IsSet() {
+			if err = mc.reconnect(ctx); err != nil {
+				continue
+			}
+		}
+		err = op()
+	}
+	return err
+}
+
+// reconnect opens the closed connection again, through the Connect hooks
+// of the interceptors. Statements prepared before are prepared again on
+// their next use.
+func (mc *cwConn) reconnect(ctx context.Context) error {
+	_, err := interceptorChain(mc.cfg.Interceptors).connect(ctx, func(ctx context.Context) (driver.Conn, error) {
+		mc.init()
+		if err := mc.open(ctx); err != nil {
+			// open leaves the connection closed but when dialing fails
+			mc.cleanup()
+			return nil, err
+		}
+		return mc, nil
+	})
+	if err != nil {
+		// a hook may fail the connection once it was opened
+		mc.cleanup()
+		return err
+	}
+	mc.generation++
+	mc.count(counterReconnects, 1)
+	return nil
+}
+
+// reprepare prepares the statement again if its connection was opened again
+// since it was prepared.
+func (stmt *cwStmt) reprepare(ctx context.Context) error {
+	if stmt.generation == stmt.mc.generation {
+		return nil
+	}
+	prepared, err := stmt.mc.prepareContext(ctx, stmt.sql)
+	if err != nil {
+		return err
+	}
+	*stmt = *prepared.(*cwStmt)
+	return nil
+}
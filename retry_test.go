@@ -0,0 +1,156 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	rows := func() *cloudwavetest.Rows {
+		return cloudwavetest.NewRows(cloudwavetest.Column{Name: "a", Type: cloudwave.CLOUD_TYPE_INTEGER}).AddRow(int32(7))
+	}
+	srv.Expect("SELECT a FROM t").WillCloseConnection()
+	srv.Expect("SELECT a FROM t").WillReturnError("LockException", "Lock wait timeout exceeded")
+	srv.Expect("SELECT a FROM t").WillReturnRows(rows())
+	srv.Expect("SELECT a FROM t WHERE b = ?").WithArgs(int64(1)).WillCloseConnection()
+	srv.Expect("SELECT a FROM t WHERE b = ?").WithArgs(int64(1)).WillReturnRows(rows())
+	srv.Expect("UPDATE t SET a = 1").WillCloseConnection()
+	srv.Expect("SELECT a FROM t").WillReturnError("LockException", "Lock wait timeout exceeded")
+
+	cfg, err := cloudwave.ParseDSN(srv.DSN() + "?retryPolicy=3,1ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RetryPolicy != (cloudwave.RetryPolicy{MaxAttempts: 3, Backoff: 1e6}) {
+		t.Fatalf("unexpected policy %+v", cfg.RetryPolicy)
+	}
+	// reconnects run the Connect hooks
+	connects := 0
+	cfg.Interceptors = []cloudwave.Interceptor{{
+		Connect: func(ctx context.Context, next cloudwave.ConnectFunc) (driver.Conn, error) {
+			connects++
+			return next(ctx)
+		},
+	}}
+	connector, err := cloudwave.NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stmt, err := conn.PrepareContext(ctx, "SELECT a FROM t WHERE b = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	// reconnects, then runs into a lock timeout, then succeeds
+	var a int
+	if err = conn.QueryRowContext(ctx, "SELECT a FROM t").Scan(&a); err != nil || a != 7 {
+		t.Fatalf("query returned %d, %v", a, err)
+	}
+	// the statement is prepared again on the new connection
+	if err = stmt.QueryRowContext(ctx, 1).Scan(&a); err != nil || a != 7 {
+		t.Fatalf("prepared query returned %d, %v", a, err)
+	}
+	stats, err := cloudwave.ConnStats(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Retries != 3 || stats.Reconnects != 2 || stats.Connects != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	pool := connector.(interface{ Stats() cloudwave.Stats }).Stats()
+	if pool.Retries != 3 || pool.Reconnects != 2 || pool.Connects != 3 {
+		t.Errorf("unexpected connector stats %+v", pool)
+	}
+	if connects != 3 {
+		t.Errorf("Connect hook ran %d times", connects)
+	}
+
+	// statements changing data aren't run twice
+	if _, err = conn.ExecContext(ctx, "UPDATE t SET a = 1"); !errors.Is(err, cloudwave.ErrInvalidConn) {
+		t.Errorf("update returned %v", err)
+	}
+
+	// neither are statements in transactions
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err = tx.QueryRow("SELECT a FROM t").Scan(&a); !errors.Is(err, cloudwave.ErrLockTimeout) {
+		t.Errorf("query in a transaction returned %v", err)
+	}
+	if err = srv.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetryAfterFailedCommit(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Expect("UPDATE t SET a = 1").WillCloseConnection()
+	srv.Expect("SELECT a FROM t").WillReturnRows(
+		cloudwavetest.NewRows(cloudwavetest.Column{Name: "a", Type: cloudwave.CLOUD_TYPE_INTEGER}).AddRow(int32(7)))
+
+	db, err := sql.Open("cloudwave", srv.DSN()+"?retryPolicy=2,1ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec("UPDATE t SET a = 1"); err == nil {
+		t.Fatal("update succeeded on a dropped connection")
+	}
+	if err = tx.Commit(); err == nil {
+		t.Fatal("commit succeeded on a dropped connection")
+	}
+
+	// the transaction is over, so the query reconnects
+	var a int
+	if err = conn.QueryRowContext(ctx, "SELECT a FROM t").Scan(&a); err != nil || a != 7 {
+		t.Fatalf("query returned %d, %v", a, err)
+	}
+	if err = srv.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -11,8 +11,7 @@ package cloudwave
 import (
 	"database/sql/driver"
 	"io"
-	"math"
-	//	"reflect"
+	"reflect"
 )
 
 type resultSet struct {
@@ -30,6 +29,12 @@ type cwRows struct {
 	cursorId    int32
 	isQuery     byte
 	resultCount int64
+
+	lobs      []lobHandle // LOB handles returned in lazy lobMode
+	eagerLOBs bool        // LOB values are read into memory
+	parseTime bool        // temporal values are returned as time.Time
+
+	hooks *intercepted // set when rows are read through Next hooks
 }
 
 type binaryRows struct {
@@ -38,6 +43,16 @@ type binaryRows struct {
 
 type textRows struct {
 	cwRows
+
+	fetched []byte // undecoded rows of the last response
+	pending int    // rows left in fetched
+}
+
+// autokey reports whether the column field of the rows as sent by the
+// server is a hidden __WISDOM_AUTO_KEY__ column.
+func (rows *cwRows) autokey(field int) bool {
+	keys := rows.stmt.autokeyFields
+	return field < len(keys) && keys[field]
 }
 
 func (rows *cwRows) Columns() []string {
@@ -69,33 +84,21 @@ func (rows *cwRows) ColumnTypeDatabaseTypeName(i int) string {
 }
 
 func (rows *cwRows) ColumnTypeLength(i int) (length int64, ok bool) {
-	return int64(rows.rs.columns[i].length), true
+	return rows.rs.columns[i].columnLength()
 }
 
+// ColumnTypeNullable reports ok false, as the result header of CloudWave
+// doesn't tell whether a column is nullable.
 func (rows *cwRows) ColumnTypeNullable(i int) (nullable, ok bool) {
-	return rows.rs.columns[i].flags&flagNotNULL == 0, true
+	return false, false
 }
 
 func (rows *cwRows) ColumnTypePrecisionScale(i int) (int64, int64, bool) {
-	column := rows.rs.columns[i]
-	decimals := int64(column.decimals)
-
-	switch column.fieldType {
-	case CLOUD_TYPE_TINY_DECIMAL, CLOUD_TYPE_SMALL_DECIMAL, CLOUD_TYPE_BIG_DECIMAL:
-		if decimals > 0 {
-			return int64(column.length) - 2, decimals, true
-		}
-		return int64(column.length) - 1, decimals, true
-	case CLOUD_TYPE_TIMESTAMP, CLOUD_TYPE_TIME:
-		return decimals, decimals, true
-	case CLOUD_TYPE_FLOAT, CLOUD_TYPE_DOUBLE:
-		if decimals == 0x1f {
-			return math.MaxInt64, math.MaxInt64, true
-		}
-		return math.MaxInt64, decimals, true
-	}
+	return rows.rs.columns[i].precisionScale()
+}
 
-	return 0, 0, false
+func (rows *cwRows) ColumnTypeScanType(i int) reflect.Type {
+	return rows.rs.columns[i].scanType(rows.parseTime, rows.eagerLOBs)
 }
 
 func (rows *cwRows) Close() (err error) {
@@ -104,10 +107,12 @@ func (rows *cwRows) Close() (err error) {
 		rows.finish = nil
 	}
 
+	ferr := rows.freeLOBs()
+
 	mc := rows.stmt.mc
 	if mc == nil {
 		rows.stmt.Close()
-		return nil
+		return ferr
 	}
 	if err := mc.error(); err != nil {
 		return err
@@ -132,6 +137,9 @@ func (rows *cwRows) Close() (err error) {
 
 	rows.stmt.Close()
 	rows.stmt.mc = nil
+	if err == nil {
+		err = ferr
+	}
 	return err
 }
 
@@ -192,13 +200,24 @@ func (rows *binaryRows) NextResultSet() error {
 }
 
 func (rows *binaryRows) Next(dest []driver.Value) error {
+	if h := rows.hooks; h != nil {
+		return h.chain.next(h.ctx, h.query, dest, rows.next)
+	}
+	return rows.next(dest)
+}
+
+func (rows *binaryRows) next(dest []driver.Value) error {
 	if mc := rows.stmt.mc; mc != nil {
 		if err := mc.error(); err != nil {
 			return err
 		}
 
 		// Fetch next row from stream
-		return rows.readRow(dest)
+		err := rows.readRow(dest)
+		if err == nil {
+			mc.count(counterRowsFetched, 1)
+		}
+		return err
 	}
 	return io.EOF
 }
@@ -214,13 +233,24 @@ func (rows *textRows) NextResultSet() (err error) {
 }
 
 func (rows *textRows) Next(dest []driver.Value) error {
+	if h := rows.hooks; h != nil {
+		return h.chain.next(h.ctx, h.query, dest, rows.next)
+	}
+	return rows.next(dest)
+}
+
+func (rows *textRows) next(dest []driver.Value) error {
 	if mc := rows.stmt.mc; mc != nil {
 		if err := mc.error(); err != nil {
 			return err
 		}
 
 		// Fetch next row from stream
-		return rows.readRow(dest)
+		err := rows.readRow(dest)
+		if err == nil {
+			mc.count(counterRowsFetched, 1)
+		}
+		return err
 	}
 	return io.EOF
 }
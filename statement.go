@@ -14,12 +14,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"reflect"
 )
 
 type cwStmt struct {
 	mc              *cwConn
+	sql             string // query of a prepared statement
+	generation      int    // generation of the connection it was prepared on
 	stmtType        byte
+	execType        byte
 	id              uint32
 	executeSequence int
 	cursorId        int32
@@ -36,6 +40,10 @@ func (stmt *cwStmt) Close() error {
 		//errLog.Print(ErrInvalidConn)
 		return driver.ErrBadConn
 	}
+	if stmt.generation != stmt.mc.generation {
+		// prepared on the connection before it was opened again
+		return nil
+	}
 
 	var err error
 	pktLen := 25 + 4
@@ -75,24 +83,32 @@ func (stmt *cwStmt) NumInput() int {
 }
 
 func (stmt *cwStmt) ColumnConverter(idx int) driver.ValueConverter {
-	return converter{}
+	return paramConverter{stmt: stmt, idx: idx}
 }
 
 func (stmt *cwStmt) CheckNamedValue(nv *driver.NamedValue) (err error) {
-	nv.Value, err = converter{}.ConvertValue(nv.Value)
+	tp := noParamType
+	if nv.Ordinal > 0 && nv.Ordinal <= len(stmt.paramType) {
+		tp = stmt.paramType[nv.Ordinal-1]
+	}
+	if nv.Value, err = stmt.mc.cfg.convertValue(nv.Value, tp); err != nil {
+		return err
+	}
+	if nv.Ordinal > 0 {
+		nv.Value, err = stmt.convertParam(nv.Ordinal-1, nv.Value)
+	}
 	return
 }
 
 func (stmt *cwStmt) Exec(args []driver.Value) (driver.Result, error) {
 	if stmt.mc.closed.IsSet() {
-		errLog.Print(ErrInvalidConn)
+		stmt.mc.logError(ErrInvalidConn)
 		return nil, driver.ErrBadConn
 	}
 	var err error
 	// Send command
 	if stmt.mc.txBatchFlag {
 		err = stmt.writeTxBatchExecutePacket(args)
-		fmt.Println("txBatchFlag true")
 		if err != nil {
 			return nil, stmt.mc.markBadConn(err)
 		}
@@ -128,8 +144,7 @@ func (stmt *cwStmt) Exec(args []driver.Value) (driver.Result, error) {
 			insertId:     int64(mc.insertId),
 		}, nil
 	} else {
-		err = stmt.writeExecutePacket(int(stmt.mc.execType), args)
-		fmt.Println("txBatchFlag false")
+		err = stmt.writeExecutePacket(int(stmt.execType), args)
 		if err != nil {
 			return nil, stmt.mc.markBadConn(err)
 		}
@@ -174,7 +189,7 @@ func (stmt *cwStmt) Query(args []driver.Value) (driver.Rows, error) {
 // func (stmt *cwStmt) query(args []driver.Value) (*binaryRows, error) {
 func (stmt *cwStmt) query(args []driver.Value) (*textRows, error) {
 	if stmt.mc.closed.IsSet() {
-		errLog.Print(ErrInvalidConn)
+		stmt.mc.logError(ErrInvalidConn)
 		return nil, driver.ErrBadConn
 	}
 	// Send command
@@ -229,8 +244,23 @@ func (c converter) ConvertValue(v interface{}) (driver.Value, error) {
 		if u, ok := sv.(uint64); ok {
 			return u, nil
 		}
+		if isArrayValue(sv) {
+			return sv, nil
+		}
 		return nil, fmt.Errorf("non-Value type %T returned from Value", sv)
 	}
+
+	// Exact numbers are passed through and encoded by writeObject, which
+	// knows the scale of the target column. So are array parameters, and
+	// streams for LOB parameters, which are uploaded by uploadLOBs.
+	switch v.(type) {
+	case *big.Rat, *big.Int, []int64, []string, []float64, []interface{}:
+		return v, nil
+	}
+	if isLOBValue(v) {
+		return v, nil
+	}
+
 	rv := reflect.ValueOf(v)
 	switch rv.Kind() {
 	case reflect.Ptr:
@@ -0,0 +1,184 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Directions of trace records.
+const (
+	TraceSend = "send" // request sent to the server
+	TraceRecv = "recv" // response read from the server
+)
+
+// redactedPassword replaces the password of traced login requests.
+const redactedPassword = "<redacted>"
+
+// TraceRecord is a frame exchanged with the server.
+type TraceRecord struct {
+	Time      time.Time     `json:"time"`
+	Conn      uint64        `json:"conn"`              // connection number, unique within the process
+	Session   uint64        `json:"session"`           // session number assigned by the server
+	Direction string        `json:"dir"`               // TraceSend or TraceRecv
+	Opcode    int           `json:"opcode"`            // command of the request, or of the request answered
+	Name      string        `json:"name"`              // name of the command
+	Elapsed   time.Duration `json:"elapsed,omitempty"` // time since the request, for responses
+	Payload   []byte        `json:"payload"`           // request following its header, or response body
+}
+
+// TraceSink receives the frames of connections opened with trace=<name>.
+// Trace is called from the goroutine using the connection and owns rec.
+type TraceSink interface {
+	Trace(rec *TraceRecord)
+}
+
+var (
+	traceSinksLock sync.RWMutex
+	traceSinks     map[string]TraceSink
+)
+
+// RegisterTraceSink registers a sink recording the request and response
+// frames of connections, which can afterwards be used by adding
+// trace=<name> to the DSN. The password of the login request is redacted.
+//
+//	f, err := os.Create("cloudwave.trace")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	cloudwave.RegisterTraceSink("file", cloudwave.NewTraceWriter(f))
+//	db, err := sql.Open("cloudwave", "user:password@tcp(localhost:1978)/db?trace=file")
+func RegisterTraceSink(name string, sink TraceSink) {
+	traceSinksLock.Lock()
+	if traceSinks == nil {
+		traceSinks = make(map[string]TraceSink)
+	}
+	traceSinks[name] = sink
+	traceSinksLock.Unlock()
+}
+
+// DeregisterTraceSink removes the trace sink registered with the given name.
+func DeregisterTraceSink(name string) {
+	traceSinksLock.Lock()
+	if traceSinks != nil {
+		delete(traceSinks, name)
+	}
+	traceSinksLock.Unlock()
+}
+
+func getTraceSink(name string) (sink TraceSink) {
+	traceSinksLock.RLock()
+	if v, ok := traceSinks[name]; ok {
+		sink = v
+	}
+	traceSinksLock.RUnlock()
+	return
+}
+
+type traceWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewTraceWriter returns a TraceSink writing records to w as
+// newline-delimited JSON, one object per frame. Payloads are base64
+// encoded. It is safe for use by several connections.
+func NewTraceWriter(w io.Writer) TraceSink {
+	return &traceWriter{enc: json.NewEncoder(w)}
+}
+
+func (tw *traceWriter) Trace(rec *TraceRecord) {
+	tw.mu.Lock()
+	// a trace is a debugging aid, it doesn't fail the connection
+	tw.enc.Encode(rec)
+	tw.mu.Unlock()
+}
+
+// ReadTrace reads the records written by a trace writer.
+func ReadTrace(r io.Reader) ([]TraceRecord, error) {
+	var recs []TraceRecord
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var rec TraceRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			return recs, nil
+		} else if err != nil {
+			return recs, err
+		}
+		recs = append(recs, rec)
+	}
+}
+
+// requestCommand returns the command of the request packet data.
+func requestCommand(data []byte) int {
+	if len(data) < 9 {
+		return 0
+	}
+	return int(int32(binary.BigEndian.Uint32(data[5:])))
+}
+
+// traceRequest records the request packet data.
+func (mc *cwConn) traceRequest(data []byte) {
+	var payload []byte
+	switch {
+	case mc.lastCmd == B_REQ_BUILD_CONNECTION && len(data) >= 9:
+		payload = redactLogin(data[9:])
+	case len(data) >= 25:
+		payload = append([]byte{}, data[25:]...)
+	}
+	mc.trace.Trace(&TraceRecord{
+		Time:      mc.lastSent,
+		Conn:      mc.connID,
+		Session:   mc.sessionSequence,
+		Direction: TraceSend,
+		Opcode:    mc.lastCmd,
+		Name:      CommandName(mc.lastCmd),
+		Payload:   payload,
+	})
+}
+
+// traceResponse records the response body data.
+func (mc *cwConn) traceResponse(data []byte) {
+	now := time.Now()
+	mc.trace.Trace(&TraceRecord{
+		Time:      now,
+		Conn:      mc.connID,
+		Session:   mc.sessionSequence,
+		Direction: TraceRecv,
+		Opcode:    mc.lastCmd,
+		Name:      CommandName(mc.lastCmd),
+		Elapsed:   now.Sub(mc.lastSent),
+		Payload:   append([]byte{}, data...),
+	})
+}
+
+// redactLogin returns the payload of a login request, the user, password
+// and time zone, with the password replaced.
+func redactLogin(p []byte) []byte {
+	if len(p) < 4 {
+		return nil
+	}
+	userEnd := 4 + int(binary.BigEndian.Uint32(p))
+	if userEnd+4 > len(p) {
+		return nil
+	}
+	pwdEnd := userEnd + 4 + int(binary.BigEndian.Uint32(p[userEnd:]))
+	if pwdEnd > len(p) {
+		return nil
+	}
+	out := append([]byte{}, p[:userEnd]...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(redactedPassword)))
+	out = append(out, redactedPassword...)
+	return append(out, p[pwdEnd:]...)
+}
@@ -0,0 +1,138 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+// queryNames runs the statements of the trace tests on dsn.
+func queryNames(t *testing.T, dsn string) []string {
+	t.Helper()
+	db, err := sql.Open("cloudwave", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("UPDATE t SET a = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT name FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestTraceAndReplay(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.SetCredentials("alice", "s3cret")
+	srv.Expect("UPDATE t SET a = ?").WithArgs(1).WillReturnResult(1)
+	srv.Expect("SELECT name FROM t").WillReturnRows(cloudwavetest.NewRows(
+		cloudwavetest.Column{Name: "name", Type: cloudwave.CLOUD_TYPE_VARCHAR},
+	).AddRow("x").AddRow("y"))
+
+	var buf bytes.Buffer
+	cloudwave.RegisterTraceSink("test", cloudwave.NewTraceWriter(&buf))
+	defer cloudwave.DeregisterTraceSink("test")
+	want := queryNames(t, srv.DSN()+"?trace=test")
+	if strings.Join(want, ",") != "x,y" {
+		t.Fatalf("got names %v", want)
+	}
+
+	if strings.Contains(buf.String(), "s3cret") || strings.Count(buf.String(), "\n") < 8 {
+		t.Fatalf("unexpected trace\n%s", buf.String())
+	}
+	recs, err := cloudwave.ReadTrace(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := recs[0]
+	if login.Direction != cloudwave.TraceSend || login.Name != "B_REQ_BUILD_CONNECTION" ||
+		!bytes.Contains(login.Payload, []byte("<redacted>")) || !bytes.Contains(login.Payload, []byte("alice")) {
+		t.Errorf("unexpected login record %+v", login)
+	}
+	var sent, received int
+	for _, rec := range recs {
+		switch rec.Direction {
+		case cloudwave.TraceSend:
+			sent++
+		case cloudwave.TraceRecv:
+			received++
+			if rec.Session == 0 && rec.Opcode != cloudwave.B_REQ_BUILD_CONNECTION {
+				t.Errorf("response without session %+v", rec)
+			}
+		}
+		if rec.Name != cloudwave.CommandName(rec.Opcode) || rec.Conn != login.Conn {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	}
+	if sent == 0 || received == 0 {
+		t.Errorf("%d requests and %d responses traced", sent, received)
+	}
+
+	replay, err := cloudwavetest.NewReplayServer(recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close()
+	got := queryNames(t, replay.DSN())
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("replay returned %v, want %v", got, want)
+	}
+	if err = replay.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnknownTraceSink(t *testing.T) {
+	if _, err := cloudwave.ParseDSN("u:p@tcp(localhost:1978)/db?trace=missing"); err == nil {
+		t.Error("unknown trace sink accepted")
+	}
+	cloudwave.RegisterTraceSink("known", cloudwave.NewTraceWriter(&bytes.Buffer{}))
+	defer cloudwave.DeregisterTraceSink("known")
+	cfg, err := cloudwave.ParseDSN("u:p@tcp(localhost:1978)/db?trace=known")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dsn := cfg.FormatDSN(); !strings.Contains(dsn, "trace=known") {
+		t.Errorf("trace missing from %q", dsn)
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	if name := cloudwave.CommandName(cloudwave.EXECUTE_STATEMENT); name != "EXECUTE_STATEMENT" {
+		t.Errorf("got %q", name)
+	}
+	if name := cloudwave.CommandName(-12345); name != "-12345" {
+		t.Errorf("got %q for an unknown command", name)
+	}
+}
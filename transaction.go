@@ -8,30 +8,54 @@
 
 package cloudwave
 
+import "context"
+
 type cwTx struct {
-	mc *cwConn
+	mc  *cwConn
+	ctx context.Context // context the transaction was started with
 }
 
 func (tx *cwTx) Commit() (err error) {
-	if tx.mc == nil || tx.mc.closed.IsSet() {
+	if tx.mc == nil {
+		return ErrInvalidConn
+	}
+	defer tx.end()
+	if tx.mc.closed.IsSet() {
 		return ErrInvalidConn
 	}
+	return interceptorChain(tx.mc.cfg.Interceptors).commit(tx.ctx, tx.commit)
+}
+
+func (tx *cwTx) commit(ctx context.Context) (err error) {
 	if err = tx.mc.writeCommandPacket(CONNECTION_COMMIT); err != nil {
 		return tx.mc.markBadConn(err)
 	}
 	_, err = tx.mc.readResultOK()
-	tx.mc = nil
 	return
 }
 
 func (tx *cwTx) Rollback() (err error) {
-	if tx.mc == nil || tx.mc.closed.IsSet() {
+	if tx.mc == nil {
+		return ErrInvalidConn
+	}
+	defer tx.end()
+	if tx.mc.closed.IsSet() {
 		return ErrInvalidConn
 	}
+	return interceptorChain(tx.mc.cfg.Interceptors).rollback(tx.ctx, tx.rollback)
+}
+
+func (tx *cwTx) rollback(ctx context.Context) (err error) {
 	if err = tx.mc.writeCommandPacket(CONNECTION_ROLLBACK); err != nil {
 		return tx.mc.markBadConn(err)
 	}
 	_, err = tx.mc.readResultOK()
-	tx.mc = nil
 	return
 }
+
+// end ends the transaction however committing or rolling it back went, so
+// that statements on the connection are retried again.
+func (tx *cwTx) end() {
+	tx.mc.inTx = false
+	tx.mc = nil
+}
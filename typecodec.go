@@ -0,0 +1,181 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// TypeCodec customizes the conversion of the values of a CloudWave type.
+// Either function may be nil to keep the default conversion.
+type TypeCodec struct {
+	// Decode converts a non-NULL value read from a column of the type, as
+	// decoded by the driver, into the value returned to database/sql.
+	Decode func(v driver.Value) (driver.Value, error)
+
+	// Encode converts a parameter value into a value the driver can send.
+	// It returns handled false to leave v to the default conversion, so it
+	// can be used to add support for custom Go types.
+	Encode func(v interface{}) (value driver.Value, handled bool, err error)
+}
+
+// noParamType is the parameter type of values bound without a prepared
+// statement, whose parameter types are unknown.
+const noParamType byte = 0xff
+
+var (
+	typeCodecsLock sync.RWMutex
+	typeCodecs     map[byte]TypeCodec
+	typeCodecOrder []byte // types of typeCodecs in ascending order, replaced on change
+)
+
+// RegisterTypeCodec registers codec for the CloudWave type cloudType, one of
+// the CLOUD_TYPE_* constants, for all connections. Codecs registered with
+// Config.RegisterTypeCodec take precedence.
+func RegisterTypeCodec(cloudType byte, codec TypeCodec) {
+	typeCodecsLock.Lock()
+	defer typeCodecsLock.Unlock()
+	if typeCodecs == nil {
+		typeCodecs = make(map[byte]TypeCodec)
+	}
+	typeCodecs[cloudType] = codec
+	typeCodecOrder = withType(typeCodecOrder, cloudType)
+}
+
+// DeregisterTypeCodec removes the codec registered for cloudType with
+// RegisterTypeCodec.
+func DeregisterTypeCodec(cloudType byte) {
+	typeCodecsLock.Lock()
+	defer typeCodecsLock.Unlock()
+	delete(typeCodecs, cloudType)
+	typeCodecOrder = withoutType(typeCodecOrder, cloudType)
+}
+
+// RegisterTypeCodec registers codec for the CloudWave type cloudType, one of
+// the CLOUD_TYPE_* constants, for the connections of cfg only. It must be
+// called before cfg is passed to NewConnector.
+func (cfg *Config) RegisterTypeCodec(cloudType byte, codec TypeCodec) {
+	if cfg.typeCodecs == nil {
+		cfg.typeCodecs = make(map[byte]TypeCodec)
+	}
+	cfg.typeCodecs[cloudType] = codec
+	cfg.codecOrder = withType(cfg.codecOrder, cloudType)
+}
+
+// withType returns a copy of the ascending types tps with tp added.
+func withType(tps []byte, tp byte) []byte {
+	i := sort.Search(len(tps), func(i int) bool { return tps[i] >= tp })
+	if i < len(tps) && tps[i] == tp {
+		return tps
+	}
+	out := make([]byte, 0, len(tps)+1)
+	out = append(out, tps[:i]...)
+	out = append(out, tp)
+	return append(out, tps[i:]...)
+}
+
+// withoutType returns a copy of the ascending types tps without tp.
+func withoutType(tps []byte, tp byte) []byte {
+	i := sort.Search(len(tps), func(i int) bool { return tps[i] >= tp })
+	if i == len(tps) || tps[i] != tp {
+		return tps
+	}
+	out := make([]byte, 0, len(tps)-1)
+	out = append(out, tps[:i]...)
+	return append(out, tps[i+1:]...)
+}
+
+// typeCodec returns the codec of tp.
+func (cfg *Config) typeCodec(tp byte) (TypeCodec, bool) {
+	if cfg != nil {
+		if codec, ok := cfg.typeCodecs[tp]; ok {
+			return codec, true
+		}
+	}
+	typeCodecsLock.RLock()
+	codec, ok := typeCodecs[tp]
+	typeCodecsLock.RUnlock()
+	return codec, ok
+}
+
+// decodeValue applies the codec of tp to the non-NULL value v.
+func (cfg *Config) decodeValue(tp byte, v driver.Value) (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	codec, ok := cfg.typeCodec(tp)
+	if !ok || codec.Decode == nil {
+		return v, nil
+	}
+	v, err := codec.Decode(v)
+	if err != nil {
+		return nil, fmt.Errorf("cloudwave: decoding %s: %w", getTypeName(tp), err)
+	}
+	return v, nil
+}
+
+// convertValue converts the value v of a parameter of type tp, which is
+// noParamType if unknown. The codec of tp is tried, or if tp is unknown the
+// codecs of cfg and then the ones for all connections in ascending order of
+// their types, then the default conversion.
+func (cfg *Config) convertValue(v interface{}, tp byte) (driver.Value, error) {
+	if tp != noParamType {
+		if codec, ok := cfg.typeCodec(tp); ok {
+			if dv, handled, err := encodeValue(codec, v, tp); handled || err != nil {
+				return dv, err
+			}
+		}
+		return converter{}.ConvertValue(v)
+	}
+	if cfg != nil {
+		for _, t := range cfg.codecOrder {
+			if dv, handled, err := encodeValue(cfg.typeCodecs[t], v, t); handled || err != nil {
+				return dv, err
+			}
+		}
+	}
+	typeCodecsLock.RLock()
+	order := typeCodecOrder
+	typeCodecsLock.RUnlock()
+	for _, t := range order {
+		if cfg != nil {
+			if _, ok := cfg.typeCodecs[t]; ok {
+				continue
+			}
+		}
+		typeCodecsLock.RLock()
+		codec, ok := typeCodecs[t]
+		typeCodecsLock.RUnlock()
+		if !ok {
+			continue
+		}
+		if dv, handled, err := encodeValue(codec, v, t); handled || err != nil {
+			return dv, err
+		}
+	}
+	return converter{}.ConvertValue(v)
+}
+
+func encodeValue(codec TypeCodec, v interface{}, tp byte) (driver.Value, bool, error) {
+	if codec.Encode == nil {
+		return nil, false, nil
+	}
+	dv, handled, err := codec.Encode(v)
+	if err != nil {
+		return nil, true, fmt.Errorf("cloudwave: encoding %T as %s: %w", v, getTypeName(tp), err)
+	}
+	if !handled {
+		return nil, false, nil
+	}
+	dv, err = converter{}.ConvertValue(dv)
+	return dv, true, err
+}
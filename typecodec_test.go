@@ -0,0 +1,166 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+type celsius float64
+
+var bigIntCodec = TypeCodec{
+	Decode: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case int64:
+			return big.NewInt(v), nil
+		case string:
+			if bi, ok := new(big.Int).SetString(v, 10); ok {
+				return bi, nil
+			}
+		}
+		return nil, fmt.Errorf("unexpected %T", v)
+	},
+	Encode: func(v interface{}) (driver.Value, bool, error) {
+		if bi, ok := v.(*big.Int); ok {
+			return bi.String(), true, nil
+		}
+		return nil, false, nil
+	},
+}
+
+func TestTypeCodecDecode(t *testing.T) {
+	cfg := NewConfig()
+	cfg.RegisterTypeCodec(CLOUD_TYPE_BIG_INTEGER, bigIntCodec)
+
+	v, err := cfg.decodeValue(CLOUD_TYPE_BIG_INTEGER, "123456789012345678901234567890")
+	if bi, ok := v.(*big.Int); err != nil || !ok || bi.String() != "123456789012345678901234567890" {
+		t.Errorf("unexpected decoded value %v, %v", v, err)
+	}
+	if v, err := cfg.decodeValue(CLOUD_TYPE_BIG_INTEGER, nil); err != nil || v != nil {
+		t.Errorf("expected NULL to bypass the codec, got %v, %v", v, err)
+	}
+	if v, err := cfg.decodeValue(CLOUD_TYPE_LONG, int64(1)); err != nil || v != int64(1) {
+		t.Errorf("expected LONG to be unchanged, got %v, %v", v, err)
+	}
+	if _, err := cfg.decodeValue(CLOUD_TYPE_BIG_INTEGER, 1.5); err == nil || !strings.Contains(err.Error(), getTypeName(CLOUD_TYPE_BIG_INTEGER)) {
+		t.Errorf("expected error naming the type, got %v", err)
+	}
+
+	// Registrations are scoped to the Config and its clones.
+	if _, ok := NewConfig().typeCodec(CLOUD_TYPE_BIG_INTEGER); ok {
+		t.Error("codec leaked into a new Config")
+	}
+	clone := cfg.Clone()
+	clone.RegisterTypeCodec(CLOUD_TYPE_LONG, TypeCodec{})
+	if _, ok := clone.typeCodec(CLOUD_TYPE_BIG_INTEGER); !ok {
+		t.Error("codec missing from clone")
+	}
+	if _, ok := cfg.typeCodec(CLOUD_TYPE_LONG); ok {
+		t.Error("clone registration leaked into the original Config")
+	}
+}
+
+func TestTypeCodecEncode(t *testing.T) {
+	errNoCelsius := errors.New("no celsius below absolute zero")
+	RegisterTypeCodec(CLOUD_TYPE_DOUBLE, TypeCodec{
+		Encode: func(v interface{}) (driver.Value, bool, error) {
+			c, ok := v.(celsius)
+			if !ok {
+				return nil, false, nil
+			}
+			if c < -273.15 {
+				return nil, true, errNoCelsius
+			}
+			return float64(c), true, nil
+		},
+	})
+	defer DeregisterTypeCodec(CLOUD_TYPE_DOUBLE)
+
+	cfg := NewConfig()
+	cfg.RegisterTypeCodec(CLOUD_TYPE_BIG_INTEGER, bigIntCodec)
+	stmt := &cwStmt{mc: &cwConn{cfg: cfg}, paramType: []byte{CLOUD_TYPE_BIG_INTEGER, CLOUD_TYPE_DOUBLE}}
+
+	nv := &driver.NamedValue{Ordinal: 1, Value: big.NewInt(42)}
+	if err := stmt.CheckNamedValue(nv); err != nil || nv.Value != "42" {
+		t.Errorf("unexpected *big.Int conversion %v, %v", nv.Value, err)
+	}
+	nv = &driver.NamedValue{Ordinal: 2, Value: celsius(21.5)}
+	if err := stmt.CheckNamedValue(nv); err != nil || nv.Value != 21.5 {
+		t.Errorf("unexpected celsius conversion %v, %v", nv.Value, err)
+	}
+	nv = &driver.NamedValue{Ordinal: 2, Value: celsius(-300)}
+	if err := stmt.CheckNamedValue(nv); !errors.Is(err, errNoCelsius) {
+		t.Errorf("expected codec error, got %v", err)
+	}
+
+	// Without parameter types, every registered codec is tried.
+	mc := &cwConn{cfg: cfg}
+	nv = &driver.NamedValue{Ordinal: 1, Value: celsius(10)}
+	if err := mc.CheckNamedValue(nv); err != nil || nv.Value != 10.0 {
+		t.Errorf("unexpected celsius conversion %v, %v", nv.Value, err)
+	}
+	nv = &driver.NamedValue{Ordinal: 1, Value: int64(7)}
+	if err := mc.CheckNamedValue(nv); err != nil || nv.Value != int64(7) {
+		t.Errorf("expected default conversion, got %v, %v", nv.Value, err)
+	}
+}
+
+func TestTypeCodecLookup(t *testing.T) {
+	var calls []byte
+	counting := func(tp byte) TypeCodec {
+		return TypeCodec{Encode: func(v interface{}) (driver.Value, bool, error) {
+			calls = append(calls, tp)
+			return nil, false, nil
+		}}
+	}
+	RegisterTypeCodec(CLOUD_TYPE_LONG, counting(CLOUD_TYPE_LONG))
+	defer DeregisterTypeCodec(CLOUD_TYPE_LONG)
+	RegisterTypeCodec(CLOUD_TYPE_BOOLEAN, counting(CLOUD_TYPE_BOOLEAN))
+	defer DeregisterTypeCodec(CLOUD_TYPE_BOOLEAN)
+	cfg := NewConfig()
+	cfg.RegisterTypeCodec(CLOUD_TYPE_VARCHAR, counting(CLOUD_TYPE_VARCHAR))
+	cfg.RegisterTypeCodec(CLOUD_TYPE_INTEGER, counting(CLOUD_TYPE_INTEGER))
+	cfg.RegisterTypeCodec(CLOUD_TYPE_LONG, counting(100))
+
+	// a parameter of known type only goes through the codec of its type
+	if _, err := cfg.convertValue(int64(1), CLOUD_TYPE_VARCHAR); err != nil || string(calls) != string([]byte{CLOUD_TYPE_VARCHAR}) {
+		t.Errorf("VARCHAR parameter tried codecs %v, %v", calls, err)
+	}
+	calls = nil
+	if _, err := cfg.convertValue(int64(1), CLOUD_TYPE_DOUBLE); err != nil || len(calls) != 0 {
+		t.Errorf("DOUBLE parameter tried codecs %v, %v", calls, err)
+	}
+
+	// without a type, the codecs of cfg are tried first, in ascending order
+	// of their types, and LONG is taken by the one of cfg
+	want := []byte{CLOUD_TYPE_INTEGER, CLOUD_TYPE_VARCHAR, 100, CLOUD_TYPE_BOOLEAN}
+	calls = nil
+	if _, err := cfg.convertValue(int64(1), noParamType); err != nil || string(calls) != string(want) {
+		t.Errorf("untyped parameter tried codecs %v, want %v, %v", calls, want, err)
+	}
+	calls = nil
+	if _, err := cfg.Clone().convertValue(int64(1), noParamType); err != nil || string(calls) != string(want) {
+		t.Errorf("untyped parameter of a clone tried codecs %v, want %v, %v", calls, want, err)
+	}
+
+	calls = make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		calls = calls[:0]
+		cfg.convertValue(int64(1), CLOUD_TYPE_INTEGER)
+		cfg.convertValue(int64(1), noParamType)
+	})
+	if allocs != 0 {
+		t.Errorf("converting parameters allocated %v times", allocs)
+	}
+}
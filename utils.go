@@ -18,8 +18,6 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"math/big"
-	"os"
 
 	//	"math/big"
 	"strconv"
@@ -27,6 +25,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // Registry for custom tls.Configs
@@ -498,6 +498,134 @@ func formatBinaryTime(src []byte, length uint8) (driver.Value, error) {
 	return appendMicrosecs(dst, src[8:], int(length)-9), nil
 }
 
+// CloudWave encodes DATE values as year*10000 + (month-1)*100 + day, with a
+// zero-based month as in java.util.Calendar, and TIME and TIMESTAMP values as
+// milliseconds since the Unix epoch as in java.sql.Time and
+// java.sql.Timestamp. TIME values are anchored on 1970-01-01.
+
+// isDateType reports whether tp uses the DATE encoding.
+func isDateType(tp byte) bool {
+	return tp == CLOUD_TYPE_DATE || tp == CLOUD_TYPE_YEAR_MONTH_DAY || tp == CLOUD_TYPE_COMPACT_DATE
+}
+
+func encodeDate(t time.Time, loc *time.Location) uint32 {
+	year, month, day := t.In(loc).Date()
+	return uint32(int32(year*10000 + (int(month)-1)*100 + day))
+}
+
+func decodeDate(d int32, loc *time.Location) time.Time {
+	v := int(d)
+	return time.Date(v/10000, time.Month((v%10000)/100+1), v%100, 0, 0, 0, 0, loc)
+}
+
+// clockOf returns the time of day of t on 1970-01-01 in loc.
+func clockOf(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	hour, min, sec := t.Clock()
+	return time.Date(1970, 1, 1, hour, min, sec, t.Nanosecond(), loc)
+}
+
+// appendMillis appends ".mmm" if t has a non-zero millisecond part.
+func appendMillis(dst []byte, t time.Time) []byte {
+	ms := t.Nanosecond() / int(time.Millisecond)
+	if ms == 0 {
+		return dst
+	}
+	return append(dst, '.', byte('0'+ms/100), digits01[(ms/10)%10], digits01[ms%10])
+}
+
+// formatTemporal formats t as the text representation of a tp column:
+// "2006-01-02", "15:04:05[.000]" or "2006-01-02 15:04:05[.000]".
+func formatTemporal(t time.Time, tp byte) []byte {
+	switch {
+	case isDateType(tp):
+		return t.AppendFormat(make([]byte, 0, 10), "2006-01-02")
+	case tp == CLOUD_TYPE_TIME:
+		return appendMillis(t.AppendFormat(make([]byte, 0, 12), "15:04:05"), t)
+	default:
+		return appendMillis(t.AppendFormat(make([]byte, 0, 23), "2006-01-02 15:04:05"), t)
+	}
+}
+
+// parseTemporal parses the text representation of a tp value in loc.
+// DATE and TIMESTAMP accept "YYYY-MM-DD[ HH:MM:SS[.ffffff]]" and RFC 3339,
+// TIME additionally accepts "HH:MM:SS[.fff]".
+func parseTemporal(s string, tp byte, loc *time.Location) (time.Time, error) {
+	if tp == CLOUD_TYPE_TIME {
+		if t, err := time.ParseInLocation("15:04:05.999999999", s, loc); err == nil {
+			return clockOf(t, loc), nil
+		}
+	}
+	t, err := parseDateTime([]byte(s), loc)
+	if err != nil {
+		var err2 error
+		if t, err2 = time.Parse(time.RFC3339Nano, s); err2 != nil {
+			return time.Time{}, fmt.Errorf("cloudwave: invalid %s value %q: %v", getTypeName(tp), s, err)
+		}
+	}
+	if tp == CLOUD_TYPE_TIME {
+		return clockOf(t, loc), nil
+	}
+	return t, nil
+}
+
+// toTime converts a bound parameter to a time.Time for a tp column.
+func toTime(arg driver.Value, tp byte, loc *time.Location) (time.Time, error) {
+	switch v := arg.(type) {
+	case time.Time:
+		if tp == CLOUD_TYPE_TIME {
+			return clockOf(v, loc), nil
+		}
+		return v, nil
+	case string:
+		return parseTemporal(v, tp, loc)
+	case []byte:
+		return parseTemporal(string(v), tp, loc)
+	}
+	return time.Time{}, fmt.Errorf("cloudwave: can't convert %T to %s", arg, getTypeName(tp))
+}
+
+// appendTemporal encodes arg for a DATE, TIME or TIMESTAMP column, or for a
+// column of one of the types using the DATE encoding.
+// Sub-millisecond precision is truncated.
+func appendTemporal(data []byte, tp byte, arg driver.Value, loc *time.Location) (int, error) {
+	t, err := toTime(arg, tp, loc)
+	if err != nil {
+		return 0, err
+	}
+	if isDateType(tp) {
+		binary.BigEndian.PutUint32(data, encodeDate(t, loc))
+		return 4, nil
+	}
+	binary.BigEndian.PutUint64(data, uint64(t.UnixMilli()))
+	return 8, nil
+}
+
+// readTemporal decodes a DATE, TIME or TIMESTAMP value, or a value of one of
+// the types using the DATE encoding. It returns a
+// time.Time in loc if parseTime is set, else the text representation.
+func readTemporal(b []byte, tp byte, parseTime bool, loc *time.Location) (driver.Value, int, error) {
+	var t time.Time
+	var n int
+	if isDateType(tp) {
+		if len(b) < 4 {
+			return nil, 0, ErrMalformPkt
+		}
+		t = decodeDate(int32(binary.BigEndian.Uint32(b)), loc)
+		n = 4
+	} else {
+		if len(b) < 8 {
+			return nil, 0, ErrMalformPkt
+		}
+		t = time.UnixMilli(int64(binary.BigEndian.Uint64(b))).In(loc)
+		n = 8
+	}
+	if parseTime {
+		return t, n, nil
+	}
+	return formatTemporal(t, tp), n, nil
+}
+
 /******************************************************************************
 *                       Convert from and to bytes                             *
 ******************************************************************************/
@@ -547,77 +675,101 @@ func stringToInt(b []byte) int {
 	return val
 }
 
+var (
+	errInvalidUTF8  = errors.New("cloudwave: invalid UTF-8 text")
+	errInvalidUTF16 = errors.New("cloudwave: invalid UTF-16 text")
+)
+
+// Ucs2ToUtf8 decodes count big-endian UTF-16 code units from ucs2, the way
+// the server sends strings, into UTF-8. It returns the text and the number
+// of bytes consumed. Surrogate pairs are decoded into supplementary
+// characters, while unpaired surrogates are reported as errors.
 func Ucs2ToUtf8(ucs2 []byte, count int) ([]byte, int, error) {
 	if count <= 0 {
 		return nil, 0, nil
 	}
-	utf8 := make([]byte, count*3)
-	pos_ucs2 := 0
-	pos_utf8 := 0
-	ucs2size := len(ucs2)
+	if len(ucs2) < 2*count {
+		return nil, 0, ErrMalformPkt
+	}
+	// a code unit takes at most 3 bytes in UTF-8, a surrogate pair 4
+	out := make([]byte, 0, count*3)
 	for i := 0; i < count; i++ {
-		if ucs2[pos_ucs2] == 0 && (ucs2[pos_ucs2+1]&0x80) == 0 {
-			utf8[pos_utf8] = ucs2[pos_ucs2+1]
-			pos_ucs2 += 2
-			pos_utf8++
-		} else if (ucs2[pos_ucs2] & 0xf8) == 0 {
-			utf8[pos_utf8] = 0xc0 | ((ucs2[pos_ucs2] & 0x07) << 2) | ((ucs2[pos_ucs2+1] & 0xc0) >> 6)
-			utf8[pos_utf8+1] = 0x80 | (ucs2[pos_ucs2+1] & 0x3f)
-			pos_ucs2 += 2
-			pos_utf8 += 2
-		} else {
-			utf8[pos_utf8] = 0xe0 | (ucs2[pos_ucs2]&0xf0)>>4
-			utf8[pos_utf8+1] = 0x80 | ((ucs2[pos_ucs2] & 0x0f) << 2) | ((ucs2[pos_ucs2+1] & 0xc0) >> 6)
-			utf8[pos_utf8+2] = 0x80 | (ucs2[pos_ucs2+1] & 0x3f)
-			pos_ucs2 += 2
-			pos_utf8 += 3
-		}
-		if pos_ucs2 > ucs2size {
-			return utf8, pos_ucs2, io.EOF
+		r := rune(binary.BigEndian.Uint16(ucs2[2*i:]))
+		if utf16.IsSurrogate(r) {
+			if i+1 < count {
+				r = utf16.DecodeRune(r, rune(binary.BigEndian.Uint16(ucs2[2*i+2:])))
+			} else {
+				r = utf8.RuneError
+			}
+			if r == utf8.RuneError {
+				return nil, 0, fmt.Errorf("%w: unpaired surrogate at code unit %d", errInvalidUTF16, i)
+			}
+			i++
 		}
+		out = utf8.AppendRune(out, r)
 	}
-	return utf8[0:pos_utf8], pos_ucs2, nil
+	return out, 2 * count, nil
 }
 
-func Utf8ToUcs2(utf8 []byte, utf8len int) ([]byte, int, int, error) {
-	if utf8len <= 0 {
-		utf8len = len(utf8)
+// Utf8ToUcs2 encodes the first utf8len bytes of utf8, or all of it if
+// utf8len is not positive, as big-endian UTF-16. Supplementary characters
+// are encoded as surrogate pairs. It returns the encoded text, the number of
+// code units, which is the length of the string as counted by the server,
+// and the number of bytes consumed. An incomplete character at the end is
+// not consumed, so that streams can complete it with their next chunk.
+func Utf8ToUcs2(utf8Text []byte, utf8len int) ([]byte, int, int, error) {
+	if utf8len <= 0 || utf8len > len(utf8Text) {
+		utf8len = len(utf8Text)
 		if utf8len <= 0 {
 			return nil, 0, 0, nil
 		}
 	}
-	ucs2 := make([]byte, utf8len*2)
+	b := utf8Text[:utf8len]
+	// a character of n UTF-8 bytes takes at most 2n bytes in UTF-16
+	out := make([]byte, 0, 2*utf8len)
 	count := 0
-	pos_ucs2 := 0
-	pos_utf8 := 0
-	for pos_utf8 = 0; pos_utf8 < utf8len; pos_utf8++ {
-		if (utf8[pos_utf8] & 0x80) == 0 {
-			ucs2[pos_ucs2] = 0
-			ucs2[pos_ucs2+1] = utf8[pos_utf8]
-			pos_ucs2 += 2
-		} else if (utf8[pos_utf8] & 0xe0) == 0xc0 {
-			if (pos_utf8 + 1) >= utf8len {
+	pos := 0
+	for pos < utf8len {
+		r, size := utf8.DecodeRune(b[pos:])
+		if r == utf8.RuneError && size <= 1 {
+			if !utf8.FullRune(b[pos:]) {
 				break
 			}
-			ucs2[pos_ucs2] = ((utf8[pos_utf8] & 0x1f) >> 2)
-			ucs2[pos_ucs2+1] = ((utf8[pos_utf8] & 0x03) << 6) | (utf8[pos_utf8+1] & 0x3f)
-			pos_utf8++
-			pos_ucs2 += 2
-		} else {
-			if (pos_utf8 + 2) >= utf8len {
-				break
-			}
-			ucs2[pos_ucs2] = ((utf8[pos_utf8] & 0x0f) << 4) | ((utf8[pos_utf8+1] & 0x3f) >> 2)
-			ucs2[pos_ucs2+1] = ((utf8[pos_utf8+1] & 0x03) << 6) | (utf8[pos_utf8+2] & 0x3f)
-			pos_utf8 += 2
-			pos_ucs2 += 2
+			return nil, 0, 0, fmt.Errorf("%w at byte %d", errInvalidUTF8, pos)
 		}
-		count++
-		if pos_utf8 > utf8len {
-			//			return ucs2, pos_utf8, io.EOF
+		if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+			out = binary.BigEndian.AppendUint16(out, uint16(r1))
+			out = binary.BigEndian.AppendUint16(out, uint16(r2))
+			count += 2
+		} else {
+			out = binary.BigEndian.AppendUint16(out, uint16(r))
+			count++
 		}
+		pos += size
 	}
-	return ucs2[0:pos_ucs2], count, pos_utf8, nil
+	return out, count, pos, nil
+}
+
+// appendUcs2 encodes the whole UTF-8 text b into data as a code unit count
+// followed by its UTF-16 encoding and returns the number of bytes written.
+func appendUcs2(data []byte, b []byte) (int, error) {
+	ucs2, count, consumed, err := Utf8ToUcs2(b, len(b))
+	if err != nil {
+		return 0, err
+	}
+	if consumed < len(b) {
+		return 0, fmt.Errorf("%w: incomplete character at byte %d", errInvalidUTF8, consumed)
+	}
+	if len(data) < 4+len(ucs2) {
+		return 0, ErrPktTooLarge
+	}
+	binary.BigEndian.PutUint32(data, uint32(count))
+	return 4 + copy(data[4:], ucs2), nil
+}
+
+// errConvert reports an argument writeObject can't encode as tp.
+func errConvert(arg driver.Value, tp byte) error {
+	return fmt.Errorf("cloudwave: can't convert %T to %s", arg, getTypeName(tp))
 }
 
 func (stmt *cwStmt) writeObject(arg driver.Value, tp byte, scale int, data []byte) (int, error) {
@@ -627,10 +779,7 @@ func (stmt *cwStmt) writeObject(arg driver.Value, tp byte, scale int, data []byt
 	var v_byte []byte
 	var v_string string
 	var v_bool bool
-	var v_time time.Time
-	var v_file os.FileInfo
 
-	v_file = nil
 	pos := 1
 	if arg == nil {
 		data[pos-1] = 1
@@ -662,11 +811,8 @@ func (stmt *cwStmt) writeObject(arg driver.Value, tp byte, scale int, data []byt
 		v_string = string(v)
 	case time.Time:
 		t = CLOUD_TYPE_TIME
-		v_time = time.Time(v)
 	case json.RawMessage:
 		t = 0xff
-	case os.FileInfo:
-		v_file = os.FileInfo(v)
 	default:
 		t = 0xff
 	}
@@ -674,11 +820,7 @@ func (stmt *cwStmt) writeObject(arg driver.Value, tp byte, scale int, data []byt
 	data[pos] = tp
 	pos++
 	switch tp {
-	case CLOUD_TYPE_SINGLE_CHAR:
-		data[pos] = 0
-		data[pos+1] = v_byte[0]
-		pos += 2
-	case CLOUD_TYPE_CHAR, CLOUD_TYPE_VARCHAR:
+	case CLOUD_TYPE_SINGLE_CHAR, CLOUD_TYPE_CHAR, CLOUD_TYPE_VARCHAR:
 		var byt []byte
 		switch t {
 		case CLOUD_TYPE_CHAR:
@@ -686,14 +828,27 @@ func (stmt *cwStmt) writeObject(arg driver.Value, tp byte, scale int, data []byt
 		case CLOUD_TYPE_BINARY:
 			byt = v_byte
 		default:
-			byt = []byte("?????")
+			return pos, errConvert(arg, tp)
+		}
+		if tp == CLOUD_TYPE_SINGLE_CHAR {
+			// a single UTF-16 code unit
+			r, size := utf8.DecodeRune(byt)
+			if size != len(byt) || (r == utf8.RuneError && size <= 1) || r > 0xffff {
+				return pos, fmt.Errorf("cloudwave: can't convert %q to %s", byt, getTypeName(tp))
+			}
+			binary.BigEndian.PutUint16(data[pos:], uint16(r))
+			pos += 2
+			break
+		}
+		n, err := appendUcs2(data[pos:], byt)
+		if err != nil {
+			return pos, err
 		}
-		tmp, n, _, _ := Utf8ToUcs2(byt, len(byt))
-		binary.BigEndian.PutUint32(data[pos:], uint32(n))
-		pos += 4
-		n = copy(data[pos:], tmp)
 		pos += n
 	case CLOUD_TYPE_SINGLE_BYTE:
+		if len(v_byte) != 1 {
+			return pos, errConvert(arg, tp)
+		}
 		data[pos] = v_byte[0]
 		pos++
 	case CLOUD_TYPE_BINARY, CLOUD_TYPE_VARBINARY:
@@ -702,30 +857,40 @@ func (stmt *cwStmt) writeObject(arg driver.Value, tp byte, scale int, data []byt
 			n := copy(data[pos+4:], v_byte)
 			binary.BigEndian.PutUint32(data[pos:], uint32(n))
 			pos += (4 + n)
+		default:
+			return pos, errConvert(arg, tp)
 		}
 	case CLOUD_TYPE_INTEGER, CLOUD_TYPE_TINY_INTEGER:
 		switch t {
 		case CLOUD_TYPE_LONG:
 			binary.BigEndian.PutUint32(data[pos:], uint32(v_int64))
 			pos += 4
+		default:
+			return pos, errConvert(arg, tp)
 		}
 	case CLOUD_TYPE_LONG, CLOUD_TYPE_SMALL_INTEGER:
 		switch t {
 		case CLOUD_TYPE_LONG:
 			binary.BigEndian.PutUint64(data[pos:], uint64(v_int64))
 			pos += 8
+		default:
+			return pos, errConvert(arg, tp)
 		}
 	case CLOUD_TYPE_FLOAT:
 		switch t {
 		case CLOUD_TYPE_FLOAT:
 			binary.BigEndian.PutUint32(data[pos:], math.Float32bits(float32(v_float64)))
 			pos += 4
+		default:
+			return pos, errConvert(arg, tp)
 		}
 	case CLOUD_TYPE_DOUBLE:
 		switch t {
 		case CLOUD_TYPE_FLOAT:
 			binary.BigEndian.PutUint64(data[pos:], math.Float64bits(v_float64))
 			pos += 8
+		default:
+			return pos, errConvert(arg, tp)
 		}
 	case CLOUD_TYPE_BOOLEAN:
 		switch t {
@@ -741,69 +906,26 @@ func (stmt *cwStmt) writeObject(arg driver.Value, tp byte, scale int, data []byt
 			} else {
 				data[pos] = 1
 			}
+		default:
+			return pos, errConvert(arg, tp)
 		}
 		pos++
-	case CLOUD_TYPE_TINY_DECIMAL:
-		switch t {
-		case CLOUD_TYPE_LONG:
-			v_float64 = float64(v_int64)
-			fallthrough
-		case CLOUD_TYPE_FLOAT:
-			d := v_float64
-			for i := 0; i < scale; i++ {
-				d *= 10
-			}
-			n := int64(d)
-			binary.BigEndian.PutUint32(data[pos:], uint32(n))
-			pos += 4
+	case CLOUD_TYPE_TINY_DECIMAL, CLOUD_TYPE_SMALL_DECIMAL, CLOUD_TYPE_BIG_DECIMAL:
+		d, err := toDecimal(arg, scale)
+		if err != nil {
+			return pos, err
 		}
-	case CLOUD_TYPE_SMALL_DECIMAL:
-		switch t {
-		case CLOUD_TYPE_LONG:
-			v_float64 = float64(v_int64)
-			fallthrough
-		case CLOUD_TYPE_FLOAT:
-			d := v_float64
-			for i := 0; i < scale; i++ {
-				d *= 10
-			}
-			n := int64(d)
-			binary.BigEndian.PutUint64(data[pos:], uint64(n))
-			pos += 8
+		// values too large for a TINY_DECIMAL or SMALL_DECIMAL at scale
+		// are sent as a BIG_DECIMAL, which carries its own scale
+		if tp != CLOUD_TYPE_BIG_DECIMAL && !decimalFits(tp, d, scale) {
+			tp = CLOUD_TYPE_BIG_DECIMAL
+			data[pos-1] = tp
 		}
-	case CLOUD_TYPE_BIG_DECIMAL:
-		var d float64
-		switch t {
-		case CLOUD_TYPE_LONG:
-			v_float64 = float64(v_int64)
-			fallthrough
-		case CLOUD_TYPE_FLOAT:
-			d = v_float64
-			for i := 0; i < scale; i++ {
-				d *= 10
-			}
-			n := int64(d)
-			data[pos] = 0
-			pos++
-			binary.BigEndian.PutUint64(data[pos:], uint64(n))
-			pos += 8
-		case CLOUD_TYPE_CHAR:
-			data[pos] = 1
-			pos++
-			bi, err := string2bigInt(v_string, scale)
-			if err != nil {
-				return pos, err
-			}
-			buf, err := bigInt2bytes(bi)
-			if err != nil {
-				return pos, err
-			}
-			n := copy(data[pos+4:], buf)
-			binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
-			pos += (4 + n)
+		n, err := appendDecimal(data[pos:], tp, d, scale)
+		if err != nil {
+			return pos, err
 		}
-		data[pos] = byte(scale)
-		pos++
+		pos += n
 	case CLOUD_TYPE_BIG_INTEGER:
 		switch t {
 		case CLOUD_TYPE_LONG:
@@ -825,203 +947,148 @@ func (stmt *cwStmt) writeObject(arg driver.Value, tp byte, scale int, data []byt
 			n := copy(data[pos+4:], buf)
 			binary.BigEndian.PutUint32(data[pos:pos+4], uint32(n))
 			pos += (4 + n)
-		}
-	case CLOUD_TYPE_DATE:
-		var tm time.Time
-		switch t {
-		case CLOUD_TYPE_TIME:
-			tm = v_time
-		case CLOUD_TYPE_CHAR:
-			tm, _ = time.ParseInLocation("2006-01-02", v_string, time.Local)
-		case CLOUD_TYPE_BINARY:
-			tm, _ = time.ParseInLocation("2006-01-02", string(v_byte), time.Local)
 		default:
-			//			tm = new(time.Time)
+			return pos, errConvert(arg, tp)
 		}
-		year, month, day := tm.Date()
-		d := year*10000 + (int(month)-1)*100 + day
-		binary.BigEndian.PutUint32(data[pos:], uint32(d))
-		pos += 4
-	case CLOUD_TYPE_TIME,
-		CLOUD_TYPE_TIMESTAMP:
-		t, _ := time.ParseInLocation(timeFormat, v_string, time.Local)
-		d := uint64(t.Unix())*1000 + uint64(t.Nanosecond())/1000000
-		binary.BigEndian.PutUint64(data[pos:], uint64(d))
-		pos += 8
+	case CLOUD_TYPE_DATE, CLOUD_TYPE_TIME, CLOUD_TYPE_TIMESTAMP,
+		CLOUD_TYPE_YEAR_MONTH_DAY, CLOUD_TYPE_COMPACT_DATE:
+		n, err := appendTemporal(data[pos:], tp, arg, stmt.mc.cfg.Loc)
+		if err != nil {
+			return pos, err
+		}
+		pos += n
 
-	case CLOUD_TYPE_BLOB:
-		blob := getBlob(stmt.mc, -1, false)
-		if v_file != nil {
-			blob.resolveBinaryIO_File(v_file)
-		} else {
-			blob.resolveBinaryIO(v_byte)
+	case CLOUD_TYPE_DAY_TIME_INTERVAL, CLOUD_TYPE_TIME_INTERVAL, CLOUD_TYPE_YEAR_MONTH_INTERVAL,
+		CLOUD_TYPE_YEAR_MONTH, CLOUD_TYPE_FISCAL_YEAR, CLOUD_TYPE_FISCAL_QUARTER:
+		n, err := appendInterval(data[pos:], tp, arg)
+		if err != nil {
+			return pos, err
 		}
-		binary.BigEndian.PutUint64(data[pos:], uint64(blob.id))
-		pos += 8
+		pos += n
 
-	case CLOUD_TYPE_CLOB:
-		clob := getClob(stmt.mc, -1, false)
-		if v_file != nil {
-			clob.resolveCharacterIO_File(v_file)
-		} else {
-			byt := []byte(v_string)
-			clob.resolveCharacterIO(byt)
+	case CLOUD_TYPE_BLOB, CLOUD_TYPE_CLOB:
+		// LOB parameters are uploaded by uploadLOBs before the packet is built
+		var id int64
+		switch v := arg.(type) {
+		case *CloudBlob:
+			id = v.id
+		case *CloudClob:
+			id = v.id
+		default:
+			return pos, errConvert(arg, tp)
 		}
-		binary.BigEndian.PutUint64(data[pos:], uint64(clob.id))
+		binary.BigEndian.PutUint64(data[pos:], uint64(id))
 		pos += 8
 
-	case CLOUD_TYPE_JSON_OBJECT: // weip ????? 未经调试
-		var byt []byte
-		switch t {
-		case CLOUD_TYPE_CHAR:
-			byt = []byte(v_string)
-		case CLOUD_TYPE_BINARY:
-			byt = v_byte
-		default:
-			byt = []byte("?????")
+	case CLOUD_TYPE_ARRAY, CLOUD_TYPE_INTS, CLOUD_TYPE_X_BYTES:
+		n, err := stmt.writeArray(arg, tp, scale, data[pos:])
+		if err != nil {
+			return pos, err
 		}
-		n := len(byt)
-		binary.BigEndian.PutUint32(data[pos:], uint32(n))
-		pos += 4
-		n = copy(data[pos:], byt)
 		pos += n
 
-	case CLOUD_TYPE_BFILE:
+	case CLOUD_TYPE_JSON_OBJECT, CLOUD_TYPE_JSON_ARRAY, CLOUD_TYPE_JSON_TEXT,
+		CLOUD_TYPE_JSON_BINARY, CLOUD_TYPE_JSON_KEYWORD, CLOUD_TYPE_JSON_BIGDECIMAL:
+		n, err := appendJSON(data[pos:], arg)
+		if err != nil {
+			return pos, err
+		}
+		pos += n
 
+	default:
+		// nothing is written for types the driver can't send
+		return 0, fmt.Errorf("cloudwave: %w %d", errUnsupportedType, tp)
 	}
 	return pos, nil
 }
 
 func (rows *textRows) readObject(b []byte) (driver.Value, byte, int, int, error) {
-	var err error
 	var dest driver.Value
 
+	r := NewPacketReader(b, "value")
 	scale := 0
-	if b[0] != 0 {
-		return nil, CLOUD_TYPE_VARCHAR, scale, 1, nil
+	if r.Uint8() != 0 {
+		return nil, CLOUD_TYPE_VARCHAR, scale, r.Pos(), r.Err()
 	}
-	tp := b[1]
-	pos := 2
-	n := 0
+	tp := r.Uint8()
+	if r.Err() != nil {
+		return nil, tp, scale, r.Pos(), r.Err()
+	}
+	r.SetContext(getTypeName(tp) + " value")
 	//see JAVA JDBC ObjectConverter.java
 	switch tp {
 	case CLOUD_TYPE_SINGLE_CHAR:
-		dest = b[pos : pos+1]
-		pos += 2
+		c := rune(r.Uint16())
+		if utf16.IsSurrogate(c) {
+			r.fail(fmt.Errorf("%w: unpaired surrogate", errInvalidUTF16))
+			break
+		}
+		dest = utf8.AppendRune(nil, c)
 	case CLOUD_TYPE_CHAR, CLOUD_TYPE_VARCHAR:
-		count := int(binary.BigEndian.Uint32(b[pos : pos+4]))
-		pos += 4
-		dest, n, err = Ucs2ToUtf8(b[pos:], count)
-		pos += n
+		count := r.Count(2)
+		var v []byte
+		var n int
+		var err error
+		if r.Err() == nil {
+			v, n, err = Ucs2ToUtf8(r.Rest(), count)
+		}
+		r.advance(n, err)
+		dest = v
 
 	case CLOUD_TYPE_SINGLE_BYTE:
-		dest = b[pos:pos]
-		pos++
+		dest = []byte{r.Uint8()}
 
 	case CLOUD_TYPE_BINARY, CLOUD_TYPE_VARBINARY:
-		n := int(binary.BigEndian.Uint32(b[pos : pos+4]))
-		pos += 4
-		buf := make([]byte, n)
-		copy(buf, b[pos:pos+n])
-		dest = buf
-		pos += n
+		dest = append([]byte{}, r.Bytes(r.Count(1))...)
 
 	case CLOUD_TYPE_INTEGER, CLOUD_TYPE_TINY_INTEGER:
-		dest = int32(binary.BigEndian.Uint32(b[pos : pos+4]))
-		pos += 4
+		dest = r.Int32()
 
 	case CLOUD_TYPE_LONG, CLOUD_TYPE_SMALL_INTEGER:
-		dest = int64(binary.BigEndian.Uint64(b[pos : pos+8]))
-		pos += 8
+		dest = r.Int64()
 
 	case CLOUD_TYPE_FLOAT:
-		dest = math.Float32frombits(binary.BigEndian.Uint32(b[pos : pos+4]))
-		pos += 4
+		dest = math.Float32frombits(r.Uint32())
 
 	case CLOUD_TYPE_DOUBLE:
-		dest = math.Float64frombits(binary.BigEndian.Uint64(b[pos : pos+8]))
-		pos += 8
-	case CLOUD_TYPE_DATE:
-		t := int(binary.BigEndian.Uint32(b[pos : pos+4]))
-		month := time.Month((t%10000)/100 + 1)
-		dest = time.Date(t/10000, month, t%100, 0, 0, 0, 0, time.Local).Format(dateFormat)
-		pos += 4
-
-	case CLOUD_TYPE_TIME:
-		t := int64(binary.BigEndian.Uint64(b[pos : pos+8]))
-		dest = time.Unix(t/1000, t%1000)
-		pos += 8
-	case CLOUD_TYPE_TIMESTAMP:
-		t := int64(binary.BigEndian.Uint64(b[pos : pos+8]))
-		dest = time.Unix(t/1000, t%1000)
-		pos += 8
+		dest = r.Float64()
+	case CLOUD_TYPE_DATE, CLOUD_TYPE_TIME, CLOUD_TYPE_TIMESTAMP,
+		CLOUD_TYPE_YEAR_MONTH_DAY, CLOUD_TYPE_COMPACT_DATE:
+		mc := rows.stmt.mc
+		v, n, err := readTemporal(r.Rest(), tp, mc.parseTime, mc.cfg.Loc)
+		r.advance(n, err)
+		dest = v
+	case CLOUD_TYPE_DAY_TIME_INTERVAL, CLOUD_TYPE_TIME_INTERVAL, CLOUD_TYPE_YEAR_MONTH_INTERVAL,
+		CLOUD_TYPE_YEAR_MONTH, CLOUD_TYPE_FISCAL_YEAR, CLOUD_TYPE_FISCAL_QUARTER:
+		v, n, err := readInterval(r.Rest(), tp)
+		r.advance(n, err)
+		dest = v
 	case CLOUD_TYPE_BOOLEAN:
-		dest = b[pos]
-		pos++
-	case CLOUD_TYPE_TINY_DECIMAL:
-		dest = binary.BigEndian.Uint64(b[pos : pos+4])
-		pos += 4
-		scale = int(b[pos])
-		pos++
-	case CLOUD_TYPE_SMALL_DECIMAL:
-		dest = int64(binary.BigEndian.Uint64(b[pos : pos+8]))
-		pos += 8
-		scale = int(b[pos])
-		pos++
-	case CLOUD_TYPE_BIG_DECIMAL:
-		var bi big.Int
-		if b[pos] == 0 {
-			bi = *new(big.Int).SetInt64(int64(binary.BigEndian.Uint64(b[pos+1 : pos+9])))
-			pos += (1 + 8)
-			scale = int(b[pos])
-			pos++
-		} else {
-			n := int(b[pos+1])
-			pos += 2
-			bi, err = bytes2bigInt(b[pos : pos+n])
-			if err != nil {
-				break
-			}
-			pos += n
-			scale = int(b[pos])
-			pos++
-		}
-		dest, err = bigInt2string(bi, scale)
-		if err != nil {
+		dest = r.Uint8()
+	case CLOUD_TYPE_TINY_DECIMAL, CLOUD_TYPE_SMALL_DECIMAL, CLOUD_TYPE_BIG_DECIMAL:
+		d, n, err := readDecimal(r.Rest(), tp)
+		r.advance(n, err)
+		scale = int(d.scale)
+		dest = d.String()
+	case CLOUD_TYPE_BIG_INTEGER:
+		if r.Uint8() == 0 {
+			dest = r.Int64()
 			break
 		}
-		pos += (n + 1)
-	case CLOUD_TYPE_BIG_INTEGER:
-		if b[pos] == 0 {
-			dest = int64(binary.BigEndian.Uint64(b[pos+1 : pos+9]))
-			pos += (1 + 8)
-		} else {
-			var bi big.Int
-			n := int(b[pos+1])
-			pos += 2
-			bi, err = bytes2bigInt(b[pos : pos+n])
-			if err != nil {
-				break
-			}
+		bi, err := bytes2bigInt(r.Bytes(int(r.Uint8())))
+		if err == nil && r.Err() == nil {
 			dest, err = bigInt2string(bi, 0)
-			if err != nil {
-				break
-			}
-			pos += n
+		}
+		if err != nil {
+			r.fail(err)
 		}
 		/*
-			case CLOUD_TYPE_YEAR_MONTH:
-				string := strconv.Itoa(int32(binary.BigEndian.Uint32(b[pos:pos+4]))) + "/"
-					strconv.Itoa(int32(binary.BigEndian.Uint32(b[pos+4:pos+8])))
-				pos += (4 + 4)
-
 			case CLOUD_TYPE_LONGVARBINARY,
 				CLOUD_TYPE_CLOB:
 				//clobId := int64(binary.BigEndian.Uint64(b[pos:pos+8]))
 				pos += 8
 
-			case CLOUD_TYPE_LONGVARCHAR,
-				CLOUD_TYPE_ARRAY:
+			case CLOUD_TYPE_LONGVARCHAR:
 				valueLen := int32(binary.BigEndian.Uint32(b[pos:pos+4]))
 				pos += 4
 				//read Comparable (valueLen)
@@ -1041,91 +1108,65 @@ func (rows *textRows) readObject(b []byte) (driver.Value, byte, int, int, error)
 				dest = nil
 		*/
 	case CLOUD_TYPE_CLOB:
-		clobId := int64(binary.BigEndian.Uint64(b[pos : pos+8]))
-		pos += 8
+		clobId := r.Int64()
 		cloudclob := &CloudClob{
 			connection:  rows.stmt.mc,
 			statementId: rows.stmt.id,
 			cursorId:    uint32(rows.cursorId),
 			id:          clobId,
 			owned:       false,
+			chunkSize:   rows.stmt.mc.lobChunkSize(),
 		}
 		dest = cloudclob
 
 	case CLOUD_TYPE_BLOB:
-		blobId := int64(binary.BigEndian.Uint64(b[pos : pos+8]))
-		pos += 8
+		blobId := r.Int64()
 		cloudblob := &CloudBlob{
 			connection:  rows.stmt.mc,
 			statementId: rows.stmt.id,
 			cursorId:    uint32(rows.cursorId),
 			id:          blobId,
 			owned:       false,
+			chunkSize:   rows.stmt.mc.lobChunkSize(),
 		}
 		dest = cloudblob
 
 	case CLOUD_TYPE_ZONE_AUTO_SEQUENCE:
 		//int32(binary.BigEndian.Uint32(b[pos:pos+4]))
-		pos += 4
 		//int64(binary.BigEndian.Uint64(b[pos:pos+8]))
-		pos += 8
+		r.Skip(4 + 8)
 
-	case CLOUD_TYPE_JSON_OBJECT: // weip ????? 未经调试
-		count := int(binary.BigEndian.Uint32(b[pos : pos+4]))
-		pos += 4
-		vs := ""
-		for i := 0; i < count; i++ {
-			n := int(binary.BigEndian.Uint32(b[pos : pos+4]))
-			pos += 4
-			key := make([]byte, n)
-			copy(key, b[pos:pos+n])
-			pos += n
-			d, _, _, n, err := rows.readObject(b[pos:])
-			pos += n
-			if err != nil {
-				break
-			}
-			switch v := d.(type) {
-			case byte:
-			case int:
-				vs += "{ \"" + string(key) + "\" : " + strconv.Itoa(v) + " }"
-			case int32:
-				n := fmt.Sprintf("%d", v)
-				vs += "{ \"" + string(key) + "\" : " + n + " }"
-			case int64:
-				n := fmt.Sprintf("%d", v)
-				vs += "{ \"" + string(key) + "\" : " + n + " }"
-			case float32:
-				f := fmt.Sprintf("%f", v)
-				vs += "{ \"" + string(key) + "\" : " + f + " }"
-			case float64:
-				f := fmt.Sprintf("%f", v)
-				vs += "{ \"" + string(key) + "\" : " + f + " }"
-			case []byte:
-				vs += "{ \"" + string(key) + "\" : " + string(v) + " }"
-			case string:
-				vs += "{ \"" + string(key) + "\" : " + v + " }"
-			}
-		}
-		dest = vs
+	case CLOUD_TYPE_ARRAY, CLOUD_TYPE_INTS, CLOUD_TYPE_X_BYTES:
+		elems, n, err := rows.readArray(r.Rest(), tp)
+		r.advance(n, err)
+		dest = elems
+
+	case CLOUD_TYPE_JSON_OBJECT, CLOUD_TYPE_JSON_ARRAY, CLOUD_TYPE_JSON_TEXT,
+		CLOUD_TYPE_JSON_BINARY, CLOUD_TYPE_JSON_KEYWORD, CLOUD_TYPE_JSON_BIGDECIMAL:
+		v, n, err := rows.readJSON(r.Rest(), tp)
+		r.advance(n, err)
+		dest = v
 
 	//case CLOUD_TYPE_BFILE:
 
 	default:
-		err = errors.New("error readObject field type not be defined")
+		return nil, tp, scale, r.Pos(), fmt.Errorf("cloudwave: unknown field type %d at offset 1", tp)
 	}
-	if err != nil {
-		return nil, tp, scale, pos, err
+	if err := r.Err(); err != nil {
+		return nil, tp, scale, r.Pos(), err
 	}
-	return dest, tp, scale, pos, nil
+	return dest, tp, scale, r.Pos(), nil
 }
 
 // returns the string read as a bytes slice, wheter the value is NULL,
 // the number of bytes read and an error, in case the string is longer than
 // the input slice
 func ReadLengthEncodedString(b []byte) ([]byte, bool, int, error) {
+	if len(b) < 4 {
+		return nil, false, 0, ErrMalformPkt
+	}
 	// Get length
-	length := int(binary.BigEndian.Uint32(b[0:]))
+	length := int(int32(binary.BigEndian.Uint32(b[0:])))
 	if length <= 0 {
 		return b[4:4], true, 4, nil
 	}
@@ -1188,155 +1229,6 @@ func readLengthEncodedInteger(b []byte) (uint64, bool, int) {
 	return uint64(b[0]), false, 1
 }
 
-// reserveBuffer checks cap(buf) and expand buffer to len(buf) + appendSize.
-// If cap(buf) is not enough, reallocate new buffer.
-func reserveBuffer(buf []byte, appendSize int) []byte {
-	newSize := len(buf) + appendSize
-	if cap(buf) < newSize {
-		// Grow buffer exponentially
-		newBuf := make([]byte, len(buf)*2+appendSize)
-		copy(newBuf, buf)
-		buf = newBuf
-	}
-	return buf[:newSize]
-}
-
-// escapeBytesBackslash escapes []byte with backslashes (\)
-// This escapes the contents of a string (provided as []byte) by adding backslashes before special
-// characters, and turning others into specific escape sequences, such as
-// turning newlines into \n and null bytes into \0.
-// https://github.com/cloudwave/cloudwave-server/blob/cloudwave-5.7.5/mysys/charset.c#L823-L932
-func escapeBytesBackslash(buf, v []byte) []byte {
-	pos := len(buf)
-	buf = reserveBuffer(buf, len(v)*2)
-
-	for _, c := range v {
-		switch c {
-		case '\x00':
-			buf[pos] = '\\'
-			buf[pos+1] = '0'
-			pos += 2
-		case '\n':
-			buf[pos] = '\\'
-			buf[pos+1] = 'n'
-			pos += 2
-		case '\r':
-			buf[pos] = '\\'
-			buf[pos+1] = 'r'
-			pos += 2
-		case '\x1a':
-			buf[pos] = '\\'
-			buf[pos+1] = 'Z'
-			pos += 2
-		case '\'':
-			buf[pos] = '\\'
-			buf[pos+1] = '\''
-			pos += 2
-		case '"':
-			buf[pos] = '\\'
-			buf[pos+1] = '"'
-			pos += 2
-		case '\\':
-			buf[pos] = '\\'
-			buf[pos+1] = '\\'
-			pos += 2
-		default:
-			buf[pos] = c
-			pos++
-		}
-	}
-
-	return buf[:pos]
-}
-
-// escapeStringBackslash is similar to escapeBytesBackslash but for string.
-func escapeStringBackslash(buf []byte, v string) []byte {
-	pos := len(buf)
-	buf = reserveBuffer(buf, len(v)*2)
-
-	for i := 0; i < len(v); i++ {
-		c := v[i]
-		switch c {
-		case '\x00':
-			buf[pos] = '\\'
-			buf[pos+1] = '0'
-			pos += 2
-		case '\n':
-			buf[pos] = '\\'
-			buf[pos+1] = 'n'
-			pos += 2
-		case '\r':
-			buf[pos] = '\\'
-			buf[pos+1] = 'r'
-			pos += 2
-		case '\x1a':
-			buf[pos] = '\\'
-			buf[pos+1] = 'Z'
-			pos += 2
-		case '\'':
-			buf[pos] = '\\'
-			buf[pos+1] = '\''
-			pos += 2
-		case '"':
-			buf[pos] = '\\'
-			buf[pos+1] = '"'
-			pos += 2
-		case '\\':
-			buf[pos] = '\\'
-			buf[pos+1] = '\\'
-			pos += 2
-		default:
-			buf[pos] = c
-			pos++
-		}
-	}
-
-	return buf[:pos]
-}
-
-// escapeBytesQuotes escapes apostrophes in []byte by doubling them up.
-// This escapes the contents of a string by doubling up any apostrophes that
-// it contains. This is used when the NO_BACKSLASH_ESCAPES SQL_MODE is in
-// effect on the server.
-// https://github.com/cloudwave/cloudwave-server/blob/cloudwave-5.7.5/mysys/charset.c#L963-L1038
-func escapeBytesQuotes(buf, v []byte) []byte {
-	pos := len(buf)
-	buf = reserveBuffer(buf, len(v)*2)
-
-	for _, c := range v {
-		if c == '\'' {
-			buf[pos] = '\''
-			buf[pos+1] = '\''
-			pos += 2
-		} else {
-			buf[pos] = c
-			pos++
-		}
-	}
-
-	return buf[:pos]
-}
-
-// escapeStringQuotes is similar to escapeBytesQuotes but for string.
-func escapeStringQuotes(buf []byte, v string) []byte {
-	pos := len(buf)
-	buf = reserveBuffer(buf, len(v)*2)
-
-	for i := 0; i < len(v); i++ {
-		c := v[i]
-		if c == '\'' {
-			buf[pos] = '\''
-			buf[pos+1] = '\''
-			pos += 2
-		} else {
-			buf[pos] = c
-			pos++
-		}
-	}
-
-	return buf[:pos]
-}
-
 /******************************************************************************
 *                               Sync utils                                    *
 ******************************************************************************/
@@ -1433,32 +1325,3 @@ func mapIsolationLevel(level driver.IsolationLevel) (string, error) {
 		return "", fmt.Errorf("cloudwave: unsupported isolation level: %v", level)
 	}
 }
-
-func whichExecute(sql string) byte {
-	var str string
-	str = strings.ToLower(sql)
-	buf := []byte(str)
-	length := len(buf)
-	for i := 0; i < length; i++ {
-		if buf[i] >= 'a' && buf[i] <= 'z' {
-			if (i + 9) <= length {
-				str = string(buf[i : i+9])
-			}
-			if strings.EqualFold(str, "CloudWave") {
-				return CLOUDWAVE_SELFUSEDRIVE
-			}
-			if (i + 6) <= length {
-				str = string(buf[i : i+6])
-			}
-			break
-		}
-	}
-	if strings.EqualFold(str, "select") {
-		return CLOUDWAVE_EXECUTE_QUERY
-	} else {
-		if strings.EqualFold(str, "insert") || strings.EqualFold(str, "update") || strings.EqualFold(str, "delete") {
-			return CLOUDWAVE_EXECUTE_UPDATE
-		}
-	}
-	return CLOUDWAVE_EXECUTE
-}
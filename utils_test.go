@@ -0,0 +1,174 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+var temporalTests = []struct {
+	tp   byte
+	wire []byte
+	t    time.Time
+	text string
+}{
+	// 2023-07-15: 20230000 + 6*100 + 15
+	{CLOUD_TYPE_DATE, []byte{0x01, 0x34, 0xb1, 0xd7}, time.Date(2023, 7, 15, 0, 0, 0, 0, time.UTC), "2023-07-15"},
+	{CLOUD_TYPE_DATE, []byte{0x00, 0x1e, 0x84, 0x81}, time.Date(200, 1, 1, 0, 0, 0, 0, time.UTC), "0200-01-01"},
+	// 1689420005123 ms
+	{CLOUD_TYPE_TIMESTAMP, []byte{0, 0, 0x01, 0x89, 0x59, 0x47, 0x7f, 0x03}, time.Date(2023, 7, 15, 11, 20, 5, 123000000, time.UTC), "2023-07-15 11:20:05.123"},
+	{CLOUD_TYPE_TIMESTAMP, []byte{0, 0, 0x01, 0x89, 0x59, 0x47, 0x7e, 0x88}, time.Date(2023, 7, 15, 11, 20, 5, 0, time.UTC), "2023-07-15 11:20:05"},
+	{CLOUD_TYPE_TIMESTAMP, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, time.Date(1969, 12, 31, 23, 59, 59, 999000000, time.UTC), "1969-12-31 23:59:59.999"},
+	// 40805050 ms
+	{CLOUD_TYPE_TIME, []byte{0, 0, 0, 0, 0x02, 0x6e, 0xa2, 0xba}, time.Date(1970, 1, 1, 11, 20, 5, 50000000, time.UTC), "11:20:05.050"},
+}
+
+func TestReadTemporal(t *testing.T) {
+	for i, tt := range temporalTests {
+		v, n, err := readTemporal(tt.wire, tt.tp, true, time.UTC)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if n != len(tt.wire) {
+			t.Errorf("%d: read %d bytes, want %d", i, n, len(tt.wire))
+		}
+		if got := v.(time.Time); !got.Equal(tt.t) || got.Location() != time.UTC {
+			t.Errorf("%d: got %v, want %v", i, got, tt.t)
+		}
+
+		v, _, err = readTemporal(tt.wire, tt.tp, false, time.UTC)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if got := string(v.([]byte)); got != tt.text {
+			t.Errorf("%d: got %q, want %q", i, got, tt.text)
+		}
+	}
+}
+
+func TestReadTemporalShort(t *testing.T) {
+	if _, _, err := readTemporal([]byte{0, 0, 0}, CLOUD_TYPE_DATE, true, time.UTC); err != ErrMalformPkt {
+		t.Errorf("expected ErrMalformPkt, got %v", err)
+	}
+	if _, _, err := readTemporal(make([]byte, 7), CLOUD_TYPE_TIMESTAMP, true, time.UTC); err != ErrMalformPkt {
+		t.Errorf("expected ErrMalformPkt, got %v", err)
+	}
+}
+
+func TestAppendTemporal(t *testing.T) {
+	nullTime := func(t time.Time) interface{} {
+		v, _ := sql.NullTime{Time: t, Valid: true}.Value()
+		return v
+	}
+	for i, tt := range temporalTests {
+		for _, arg := range []interface{}{tt.t, tt.text, []byte(tt.text), nullTime(tt.t)} {
+			buf := make([]byte, 8)
+			n, err := appendTemporal(buf, tt.tp, arg, time.UTC)
+			if err != nil {
+				t.Fatalf("%d: %T: %v", i, arg, err)
+			}
+			if !bytes.Equal(buf[:n], tt.wire) {
+				t.Errorf("%d: %T: got %x, want %x", i, arg, buf[:n], tt.wire)
+			}
+		}
+	}
+}
+
+func TestAppendTemporalLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	buf := make([]byte, 8)
+
+	// The calendar date is taken in the connection location.
+	in := time.Date(2023, 7, 14, 20, 0, 0, 0, time.UTC)
+	n, err := appendTemporal(buf, CLOUD_TYPE_DATE, in, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, _ := readTemporal(buf[:n], CLOUD_TYPE_DATE, false, loc)
+	if got := string(v.([]byte)); got != "2023-07-15" {
+		t.Errorf("got %q, want 2023-07-15", got)
+	}
+
+	// Strings are parsed in the connection location.
+	n, err = appendTemporal(buf, CLOUD_TYPE_TIMESTAMP, "2023-07-15 19:20:05.123", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := temporalTests[2].wire; !bytes.Equal(buf[:n], want) {
+		t.Errorf("got %x, want %x", buf[:n], want)
+	}
+	v, _, _ = readTemporal(buf[:n], CLOUD_TYPE_TIMESTAMP, true, loc)
+	if got := v.(time.Time); got.Location() != loc || got.Hour() != 19 {
+		t.Errorf("got %v, want 19:20:05 in %v", got, loc)
+	}
+
+	// Sub-millisecond precision is truncated.
+	n, _ = appendTemporal(buf, CLOUD_TYPE_TIMESTAMP, temporalTests[2].t.Add(999*time.Microsecond), time.UTC)
+	if want := temporalTests[2].wire; !bytes.Equal(buf[:n], want) {
+		t.Errorf("got %x, want %x", buf[:n], want)
+	}
+}
+
+func TestAppendTemporalInvalid(t *testing.T) {
+	buf := make([]byte, 8)
+	for _, arg := range []interface{}{"2023/07/15", "25:00:00", "yesterday", int64(1), 1.5} {
+		if _, err := appendTemporal(buf, CLOUD_TYPE_TIMESTAMP, arg, time.UTC); err == nil {
+			t.Errorf("%v: expected error", arg)
+		}
+	}
+}
+
+func TestUcs2RoundTrip(t *testing.T) {
+	for _, s := range []string{"", "abc", "é中", "a\U0001F600b", "\U00020BB7"} {
+		ucs2, count, consumed, err := Utf8ToUcs2([]byte(s), len(s))
+		if err != nil || consumed != len(s) {
+			t.Errorf("%q: encoded %d of %d bytes, %v", s, consumed, len(s), err)
+			continue
+		}
+		if count != int(utf16Len([]byte(s))) || len(ucs2) != 2*count {
+			t.Errorf("%q: unexpected count %d for %d bytes", s, count, len(ucs2))
+		}
+		got, n, err := Ucs2ToUtf8(ucs2, count)
+		if err != nil || n != len(ucs2) || string(got) != s {
+			t.Errorf("%q: decoded %q, %d, %v", s, got, n, err)
+		}
+	}
+
+	// U+1F600 is the surrogate pair D83D DE00
+	ucs2, _, _, _ := Utf8ToUcs2([]byte("\U0001F600"), 0)
+	if !bytes.Equal(ucs2, []byte{0xd8, 0x3d, 0xde, 0x00}) {
+		t.Errorf("unexpected encoding % x", ucs2)
+	}
+}
+
+func TestUcs2Invalid(t *testing.T) {
+	if _, _, _, err := Utf8ToUcs2([]byte{'a', 0xff, 'b'}, 3); err == nil {
+		t.Error("expected error for invalid UTF-8")
+	}
+	// an incomplete character at the end is left to the caller
+	_, count, consumed, err := Utf8ToUcs2([]byte{'a', 0xf0, 0x9f}, 3)
+	if err != nil || count != 1 || consumed != 1 {
+		t.Errorf("unexpected result %d, %d, %v", count, consumed, err)
+	}
+	if _, err := appendUcs2(make([]byte, 16), []byte{'a', 0xf0, 0x9f}); err == nil {
+		t.Error("expected error for incomplete UTF-8")
+	}
+
+	for _, ucs2 := range [][]byte{{0xd8, 0x3d}, {0xde, 0x00, 0x00, 0x61}, {0xd8, 0x3d, 0x00, 0x61}} {
+		if _, _, err := Ucs2ToUtf8(ucs2, len(ucs2)/2); err == nil {
+			t.Errorf("% x: expected error for unpaired surrogate", ucs2)
+		}
+	}
+	if _, _, err := Ucs2ToUtf8([]byte{0x00}, 1); err == nil {
+		t.Error("expected error for short input")
+	}
+}
@@ -0,0 +1,200 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ServerVersion is the version of a CloudWave server. Connections read it
+// from the server when they connect:
+//
+//	var v cloudwave.ServerVersion
+//	err := conn.Raw(func(driverConn interface{}) error {
+//		v = driverConn.(interface{ ServerVersion() cloudwave.ServerVersion }).ServerVersion()
+//		return nil
+//	})
+//
+// The zero ServerVersion is the version of a server whose version string
+// holds no version number; the driver then assumes it supports every
+// feature.
+type ServerVersion struct {
+	Major, Minor, Patch int
+	Raw                 string // version string of the server
+}
+
+// versionPattern finds the version number in the version string.
+var versionPattern = regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// ParseServerVersion parses the version number of a version string, such
+// as "CloudWave 2.4.1, build 20230601".
+func ParseServerVersion(s string) (ServerVersion, error) {
+	v := ServerVersion{Raw: s}
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return v, errors.New("no version number in server version " + strconv.Quote(s))
+	}
+	for i, p := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if m[i+1] == "" {
+			break
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return v, fmt.Errorf("invalid server version %q: %w", s, err)
+		}
+		*p = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or +1 as v is lower than, equal to or higher than
+// w. Raw strings aren't compared.
+func (v ServerVersion) Compare(w ServerVersion) int {
+	for _, d := range [...]int{v.Major - w.Major, v.Minor - w.Minor, v.Patch - w.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// IsZero reports whether the version number is unknown.
+func (v ServerVersion) IsZero() bool {
+	return v.Major == 0 && v.Minor == 0 && v.Patch == 0
+}
+
+// String returns the version number, as in "2.4.1".
+func (v ServerVersion) String() string {
+	return strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+}
+
+// Supports reports whether the server supports the feature f. Servers of
+// unknown version support every feature.
+func (v ServerVersion) Supports(f Feature) bool {
+	min, ok := features[f]
+	return !ok || v.IsZero() || v.Compare(min) >= 0
+}
+
+// Feature is a feature of the driver that only some servers support.
+type Feature string
+
+// Features checked against the version of the server.
+const (
+	FeatureJSON             Feature = "JSON parameters"
+	FeatureCallable         Feature = "CALL statements"
+	FeatureFetchSize        Feature = "fetchSize"
+	FeatureUserPrivileges   Feature = "the user privileges command"
+	FeatureSystemOverview   Feature = "the system overview command"
+	FeatureHealthDiagnostic Feature = "the health diagnostic command"
+)
+
+// features holds the first server version supporting each gated feature.
+// Features without an entry, such as FeatureJSON, FeatureCallable and
+// FeatureFetchSize, are allowed on every server until that version is
+// known.
+var features = map[Feature]ServerVersion{
+	// command/cmdconst.go records servers answering opcodes 114, 109 and
+	// 133 with "Unsupported request type code"
+	FeatureUserPrivileges:   {Major: 2, Minor: 4},
+	FeatureSystemOverview:   {Major: 2, Minor: 4},
+	FeatureHealthDiagnostic: {Major: 2, Minor: 4},
+}
+
+// commandFeatures maps the admin commands older servers reject as
+// "Unsupported request type code" to their feature.
+var commandFeatures = map[int]Feature{
+	DATABASE_META_DATA_GET_USER_PRIVILEGES: FeatureUserPrivileges,
+	GET_SYSTEM_OVERVIEW:                    FeatureSystemOverview,
+	DATABASE_HEALTH_DIAGNOSTIC:             FeatureHealthDiagnostic,
+}
+
+// FeatureError is returned when the server is too old for a feature.
+type FeatureError struct {
+	Feature  Feature
+	Required ServerVersion // first version supporting the feature
+	Server   ServerVersion
+}
+
+func (e *FeatureError) Error() string {
+	return fmt.Sprintf("%s requires server >= %s, server is %s", e.Feature, e.Required, e.Server)
+}
+
+// ServerVersion returns the version of the server the connection is
+// connected to.
+func (mc *cwConn) ServerVersion() ServerVersion {
+	return mc.version
+}
+
+// ConnServerVersion returns the version of the server c is connected to.
+func ConnServerVersion(c *sql.Conn) (v ServerVersion, err error) {
+	err = c.Raw(func(driverConn interface{}) error {
+		mc, ok := driverConn.(*cwConn)
+		if !ok {
+			return errors.New("cloudwave: not a CloudWave connection")
+		}
+		v = mc.version
+		return nil
+	})
+	return
+}
+
+// readServerVersion asks the server for its version. The response holds the
+// version string, possibly followed by build information.
+func (mc *cwConn) readServerVersion() error {
+	if err := mc.writeCommandPacket(GET_SERVER_VERSION); err != nil {
+		return err
+	}
+	data, err := mc.readResultOK()
+	if err != nil {
+		return err
+	}
+	r := NewPacketReader(data[1:], "server version")
+	parts := []string{r.String()}
+	if r.Len() > 0 {
+		parts = append(parts, r.String())
+	}
+	if err = r.Err(); err != nil {
+		return err
+	}
+	// without a version number, the version stays unknown
+	mc.version, _ = ParseServerVersion(strings.Join(parts, ", "))
+	return nil
+}
+
+// require returns a *FeatureError if the server doesn't support f.
+func (mc *cwConn) require(f Feature) error {
+	if mc.version.Supports(f) {
+		return nil
+	}
+	return &FeatureError{Feature: f, Required: features[f], Server: mc.version}
+}
+
+// checkStatement returns a *FeatureError if the server doesn't support the
+// statement query, or the admin command of args.
+func (mc *cwConn) checkStatement(query string, args []driver.Value) error {
+	if cmd, ok := adminCommand(args); ok {
+		if f, gated := commandFeatures[cmd]; gated {
+			return mc.require(f)
+		}
+		return nil
+	}
+	if classifyStatement(query) == stmtCall {
+		return mc.require(FeatureCallable)
+	}
+	return nil
+}
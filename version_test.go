@@ -0,0 +1,160 @@
+// Go CloudWave Driver - A CloudWave-Driver for Go's database/sql package
+//
+// Copyright 2023 The Go-CloudWave-Driver Authors. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cloudwave_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave"
+	"proxy.cloudwave.cn/share/go-sql-driver/cloudwave/cloudwavetest"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  cloudwave.ServerVersion
+		valid bool
+	}{
+		{"CloudWave 2.4.1, build 20230601", cloudwave.ServerVersion{Major: 2, Minor: 4, Patch: 1}, true},
+		{"V3.0", cloudwave.ServerVersion{Major: 3}, true},
+		{"12", cloudwave.ServerVersion{Major: 12}, true},
+		{"cloudwavetest", cloudwave.ServerVersion{}, false},
+	}
+	for _, tt := range tests {
+		v, err := cloudwave.ParseServerVersion(tt.in)
+		tt.want.Raw = tt.in
+		if v != tt.want || (err == nil) != tt.valid {
+			t.Errorf("ParseServerVersion(%q) = %+v, %v", tt.in, v, err)
+		}
+	}
+
+	v := cloudwave.ServerVersion{Major: 2, Minor: 4, Patch: 1}
+	for _, tt := range []struct {
+		w    cloudwave.ServerVersion
+		want int
+	}{
+		{cloudwave.ServerVersion{Major: 2, Minor: 4, Patch: 1, Raw: "other"}, 0},
+		{cloudwave.ServerVersion{Major: 2, Minor: 10}, -1},
+		{cloudwave.ServerVersion{Major: 1, Minor: 9, Patch: 9}, 1},
+	} {
+		if got := v.Compare(tt.w); got != tt.want {
+			t.Errorf("%v.Compare(%v) = %d", v, tt.w, got)
+		}
+	}
+	if !(cloudwave.ServerVersion{}).Supports(cloudwave.FeatureCallable) {
+		t.Error("a server of unknown version doesn't support CALL statements")
+	}
+}
+
+func TestFeatureGating(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.SetVersion("CloudWave 2.1.0")
+	srv.Expect("CALL refresh(1)")
+	srv.Expect("{call refresh(1)}")
+	srv.Expect("UPDATE t SET doc = ?").WithParamTypes(cloudwave.CLOUD_TYPE_JSON_OBJECT).
+		WithArgs(`{"a":1}`).WillReturnResult(1)
+
+	db, err := sql.Open("cloudwave", srv.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	v, err := cloudwave.ConnServerVersion(conn)
+	if err != nil || v != (cloudwave.ServerVersion{Major: 2, Minor: 1, Raw: "CloudWave 2.1.0"}) {
+		t.Fatalf("ConnServerVersion = %+v, %v", v, err)
+	}
+	if err = conn.Raw(func(driverConn interface{}) error {
+		v = driverConn.(interface {
+			ServerVersion() cloudwave.ServerVersion
+		}).ServerVersion()
+		return nil
+	}); err != nil || v.String() != "2.1.0" {
+		t.Fatalf("ServerVersion = %v, %v", v, err)
+	}
+
+	var featureErr *cloudwave.FeatureError
+	_, err = conn.ExecContext(ctx, "", cloudwave.AdminCommand(cloudwave.GET_SYSTEM_OVERVIEW))
+	if !errors.As(err, &featureErr) || featureErr.Feature != cloudwave.FeatureSystemOverview ||
+		err.Error() != "the system overview command requires server >= 2.4.0, server is 2.1.0" {
+		t.Errorf("system overview returned %v", err)
+	}
+
+	// features without a known first version aren't gated
+	for _, query := range []string{"CALL refresh(1)", "{call refresh(1)}"} {
+		if _, err = conn.ExecContext(ctx, query); err != nil {
+			t.Errorf("%s returned %v", query, err)
+		}
+	}
+	if _, err = conn.ExecContext(ctx, "UPDATE t SET doc = ?", cloudwave.JSON{V: map[string]int{"a": 1}}); err != nil {
+		t.Errorf("JSON parameter returned %v", err)
+	}
+	if err = srv.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// newer servers are sent the commands
+	srv.SetVersion("CloudWave 2.4.0")
+	srv.Handle(cloudwave.GET_SYSTEM_OVERVIEW, func([]byte) ([]byte, error) {
+		return nil, nil
+	})
+	conn2, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	if _, err = conn2.ExecContext(ctx, "", cloudwave.AdminCommand(cloudwave.GET_SYSTEM_OVERVIEW)); errors.As(err, &featureErr) {
+		t.Errorf("system overview returned %v", err)
+	}
+}
+
+func TestUnknownServerVersion(t *testing.T) {
+	srv, err := cloudwavetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Handle(cloudwave.GET_SERVER_VERSION, func([]byte) ([]byte, error) {
+		return nil, &cloudwavetest.Error{Brief: "SQLException", Message: "Unsupported request type code: 103"}
+	})
+	srv.Expect("CALL refresh(1)")
+
+	db, err := sql.Open("cloudwave", srv.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the version stays unknown and every feature is allowed
+	if v, err := cloudwave.ConnServerVersion(conn); err != nil || !v.IsZero() {
+		t.Errorf("ConnServerVersion = %+v, %v", v, err)
+	}
+	if _, err = conn.ExecContext(ctx, "CALL refresh(1)"); err != nil {
+		t.Error(err)
+	}
+}